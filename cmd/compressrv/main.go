@@ -3,12 +3,18 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"log"
 	"os"
+	"runtime"
+	"strconv"
+	"strings"
 
+	"github.com/Hargeon/compressrv/pkg/ffmpeg"
 	"github.com/Hargeon/compressrv/pkg/handler"
 	"github.com/Hargeon/compressrv/pkg/service"
 	"github.com/Hargeon/compressrv/pkg/service/broker"
+	"github.com/Hargeon/compressrv/pkg/service/budget"
 	"github.com/Hargeon/compressrv/pkg/service/compressor"
 	"github.com/Hargeon/compressrv/pkg/service/storage"
 
@@ -49,6 +55,32 @@ func main() {
 	}
 	defer consumerConn.Close()
 
+	limits, err := loadLimits()
+	if err != nil {
+		logger.Fatal("ffmpeg limits", zap.String("Error", err.Error()))
+	}
+
+	slots, err := envInt("CPU_SLOTS", runtime.NumCPU())
+	if err != nil {
+		logger.Fatal("cpu slots", zap.String("Error", err.Error()))
+	}
+
+	cpu, err := budget.New(slots)
+	if err != nil {
+		logger.Fatal("cpu slots", zap.String("Error", err.Error()))
+	}
+
+	// a job without thread limit may use every core
+	jobSlots := slots
+	if limits.Threads > 0 && limits.Threads < slots {
+		jobSlots = limits.Threads
+	}
+
+	// rabbit delivers no more messages than jobs which budget can run at once
+	if err = consumer.Prefetch(slots / jobSlots); err != nil {
+		logger.Fatal("rabbit prefetch", zap.String("Error", err.Error()))
+	}
+
 	msgs, err := consumer.Consume()
 	if err != nil {
 		logger.Fatal("rabbit connect consumer", zap.String("Error", err.Error()))
@@ -58,42 +90,77 @@ func main() {
 		os.Getenv("AWS_REGION"), os.Getenv("AWS_ACCESS_KEY"),
 		os.Getenv("AWS_SECRET_KEY"))
 
-	srv := service.NewService(st, os.Getenv("FFMPEG_PATH"), os.Getenv("FFPROBE_PATH"))
+	presets, err := compressor.LoadPresets(os.Getenv("PRESETS_PATH"))
+	if err != nil {
+		logger.Fatal("load presets", zap.String("Error", err.Error()))
+	}
+
+	admission, err := loadAdmission()
+	if err != nil {
+		logger.Fatal("admission limits", zap.String("Error", err.Error()))
+	}
+
+	chunking, err := loadChunking()
+	if err != nil {
+		logger.Fatal("chunking", zap.String("Error", err.Error()))
+	}
+
+	srv := service.NewService(st, os.Getenv("FFMPEG_PATH"), os.Getenv("FFPROBE_PATH"), presets, admission, limits,
+		chunking)
 	h := handler.NewHandler(srv, logger)
 	forever := make(chan bool)
 
-	go func() {
-		for d := range msgs {
-			logger.Info("Received", zap.String("Message", string(d.Body)))
+	work := func(d amqp.Delivery) {
+		defer finishMsg(d)
 
-			req := new(compressor.Request)
+		logger.Info("Received", zap.String("Message", string(d.Body)))
 
-			err = json.Unmarshal(d.Body, req)
-			if err != nil {
-				logger.Error("json Unmarshal", zap.String("Error", err.Error()))
-				finishMsg(d)
+		req := new(compressor.Request)
 
-				continue
-			}
+		if err := json.Unmarshal(d.Body, req); err != nil {
+			logger.Error("json Unmarshal", zap.String("Error", err.Error()))
+
+			return
+		}
 
-			resp := h.Compress(context.Background(), req)
+		resp := h.Compress(context.Background(), req)
+
+		body, err := json.Marshal(resp)
+		if err != nil {
+			logger.Error("marshal", zap.String("Error", err.Error()))
+
+			return
+		}
+
+		if err = publisher.Publish(body); err != nil {
+			logger.Error("publish response", zap.String("Error", err.Error()))
+		}
 
-			body, err := json.Marshal(resp)
+		logger.Info("Worker finish", zap.String("Body", string(body)))
+	}
+
+	go func() {
+		for {
+			// a job is admitted only when budget has free slots for it
+			release, err := cpu.Acquire(context.Background(), jobSlots)
 			if err != nil {
-				logger.Error("marshal", zap.String("Error", err.Error()))
-				finishMsg(d)
+				logger.Error("cpu slots", zap.String("Error", err.Error()))
 
 				continue
 			}
 
-			err = publisher.Publish(body)
-			if err != nil {
-				logger.Error("publish response", zap.String("Error", err.Error()))
+			d, ok := <-msgs
+			if !ok {
+				release()
+
+				return
 			}
 
-			logger.Info("Worker finish", zap.String("Body", string(body)))
+			go func() {
+				defer release()
 
-			finishMsg(d)
+				work(d)
+			}()
 		}
 	}()
 
@@ -106,3 +173,110 @@ func finishMsg(d amqp.Delivery) {
 		logger.Error("Ack", zap.String("Error", err.Error()))
 	}
 }
+
+// loadLimits reads resource limits of ffmpeg processes from env
+func loadLimits() (ffmpeg.Limits, error) {
+	var (
+		limits ffmpeg.Limits
+		err    error
+	)
+
+	if limits.Threads, err = envInt("FFMPEG_THREADS", 0); err != nil {
+		return limits, err
+	}
+
+	if limits.Nice, err = envInt("FFMPEG_NICE", 0); err != nil {
+		return limits, err
+	}
+
+	if memory := os.Getenv("FFMPEG_MEMORY_LIMIT"); memory != "" {
+		if limits.MemoryBytes, err = strconv.ParseInt(memory, 10, 64); err != nil {
+			return limits, fmt.Errorf("FFMPEG_MEMORY_LIMIT: %w", err)
+		}
+	}
+
+	limits.Cgroup = os.Getenv("FFMPEG_CGROUP")
+
+	return limits, limits.Validate()
+}
+
+// loadAdmission reads limits of original videos from env
+func loadAdmission() (compressor.Admission, error) {
+	var (
+		admission compressor.Admission
+		err       error
+	)
+
+	if duration := os.Getenv("MAX_INPUT_DURATION"); duration != "" {
+		if admission.MaxDuration, err = strconv.ParseFloat(duration, 64); err != nil {
+			return admission, fmt.Errorf("MAX_INPUT_DURATION: %w", err)
+		}
+	}
+
+	if admission.MaxWidth, err = envInt("MAX_INPUT_WIDTH", 0); err != nil {
+		return admission, err
+	}
+
+	if admission.MaxHeight, err = envInt("MAX_INPUT_HEIGHT", 0); err != nil {
+		return admission, err
+	}
+
+	if size := os.Getenv("MAX_INPUT_SIZE"); size != "" {
+		if admission.MaxFileSize, err = strconv.ParseInt(size, 10, 64); err != nil {
+			return admission, fmt.Errorf("MAX_INPUT_SIZE: %w", err)
+		}
+	}
+
+	if admission.MaxStreams, err = envInt("MAX_INPUT_STREAMS", 0); err != nil {
+		return admission, err
+	}
+
+	if codecs := os.Getenv("INPUT_CODECS"); codecs != "" {
+		for _, codec := range strings.Split(codecs, ",") {
+			admission.Codecs = append(admission.Codecs, strings.TrimSpace(codec))
+		}
+	}
+
+	return admission, admission.Validate()
+}
+
+// loadChunking reads settings of parallel encoding of long videos from env
+func loadChunking() (compressor.Chunking, error) {
+	var (
+		chunking compressor.Chunking
+		err      error
+	)
+
+	if chunking.Workers, err = envInt("CHUNK_WORKERS", 0); err != nil {
+		return chunking, err
+	}
+
+	if duration := os.Getenv("CHUNK_DURATION"); duration != "" {
+		if chunking.Duration, err = strconv.ParseFloat(duration, 64); err != nil {
+			return chunking, fmt.Errorf("CHUNK_DURATION: %w", err)
+		}
+	}
+
+	if duration := os.Getenv("CHUNK_MIN_DURATION"); duration != "" {
+		if chunking.MinDuration, err = strconv.ParseFloat(duration, 64); err != nil {
+			return chunking, fmt.Errorf("CHUNK_MIN_DURATION: %w", err)
+		}
+	}
+
+	return chunking, chunking.Validate()
+}
+
+// envInt reads integer env variable, def is returned when variable isn't set
+func envInt(name string, def int) (int, error) {
+	value := os.Getenv(name)
+	if value == "" {
+		return def, nil
+	}
+
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", name, err)
+	}
+
+	return n, nil
+}
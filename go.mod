@@ -1,16 +1,19 @@
 module github.com/Hargeon/compressrv
 
-go 1.16
+go 1.20
 
 require (
 	github.com/aws/aws-sdk-go v1.42.3
-	github.com/floostack/transcoder v1.1.1
 	github.com/google/uuid v1.3.0
 	github.com/joho/godotenv v1.3.0
 	github.com/streadway/amqp v1.0.0
+	go.uber.org/zap v1.19.1
+)
+
+require (
+	github.com/jmespath/go-jmespath v0.4.0 // indirect
 	go.uber.org/atomic v1.9.0 // indirect
 	go.uber.org/multierr v1.7.0 // indirect
-	go.uber.org/zap v1.19.1
 	golang.org/x/net v0.0.0-20211111160137-58aab5ef257a // indirect
 	golang.org/x/text v0.3.7 // indirect
 )
@@ -0,0 +1,138 @@
+package ffmpeg
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// safeShellChars don't require quoting of shell word
+const safeShellChars = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789-_./:=+,@%"
+
+// Command is a command line of ffmpeg or ffprobe built by arguments, inputs, options and outputs
+type Command struct {
+	name    string
+	bin     string
+	args    []string
+	limits  Limits
+	threads int
+}
+
+func newCommand(name, bin string, limits Limits, args ...string) *Command {
+	return &Command{name: name, bin: bin, args: args, limits: limits}
+}
+
+// Threads sets thread limit of command, it overrides thread limit of Config
+func (c *Command) Threads(n int) *Command {
+	c.threads = n
+
+	return c
+}
+
+// Args appends args to command
+func (c *Command) Args(args ...string) *Command {
+	c.args = append(c.args, args...)
+
+	return c
+}
+
+// Input appends input at path, input options precede it
+func (c *Command) Input(path string, options ...string) *Command {
+	return c.Args(options...).Args("-i", path)
+}
+
+// Options appends arguments of opts
+func (c *Command) Options(opts *Options) *Command {
+	return c.Args(opts.Args()...)
+}
+
+// Output appends output at path, output options precede it
+func (c *Command) Output(path string, options ...string) *Command {
+	return c.Args(options...).Args(path)
+}
+
+// Arguments returns arguments of command with thread limits
+func (c *Command) Arguments() []string {
+	return threadArgs(c.args, c.threads)
+}
+
+// String returns command line which can be pasted to shell, arguments are quoted when it's required
+func (c *Command) String() string {
+	var words []string
+
+	bin := c.bin
+	if bin == "" {
+		bin = c.name
+	}
+
+	for _, word := range append([]string{bin}, c.Arguments()...) {
+		words = append(words, quote(word))
+	}
+
+	return strings.Join(words, " ")
+}
+
+// Run runs command and returns its stderr output, only the last stderrLimit bytes are returned
+func (c *Command) Run(ctx context.Context) (string, error) {
+	return c.run(ctx, nil)
+}
+
+// Stdout runs command and returns its stdout output, it's used for ffprobe
+func (c *Command) Stdout(ctx context.Context) ([]byte, error) {
+	var stdout bytes.Buffer
+
+	_, err := c.run(ctx, &stdout)
+
+	return stdout.Bytes(), err
+}
+
+func (c *Command) run(ctx context.Context, stdout *bytes.Buffer) (string, error) {
+	if c.bin == "" {
+		return "", fmt.Errorf("%s binary path not found", c.name)
+	}
+
+	lm, err := c.limits.prepare()
+	if err != nil {
+		return "", fmt.Errorf("%s limits: %w", c.name, err)
+	}
+	defer lm.release()
+
+	// progress and filter logs of long encodes are unbounded, only their end is kept
+	stderr := newRingBuffer(stderrLimit)
+
+	cmd := exec.CommandContext(ctx, c.bin, c.Arguments()...)
+	cmd.Stderr = stderr
+
+	if stdout != nil {
+		cmd.Stdout = stdout
+	}
+
+	lm.apply(cmd)
+
+	if err = cmd.Start(); err != nil {
+		return "", newError(ctx, c, "", err)
+	}
+
+	if err = cmd.Wait(); err != nil {
+		return stderr.String(), newError(ctx, c, stderr.String(), err)
+	}
+
+	return stderr.String(), nil
+}
+
+// quote quotes word for POSIX shell
+func quote(word string) string {
+	if word == "" {
+		return "''"
+	}
+
+	if strings.IndexFunc(word, func(r rune) bool {
+		return !strings.ContainsRune(safeShellChars, r)
+	}) == -1 {
+		return word
+	}
+
+	return "'" + strings.ReplaceAll(word, "'", `'\''`) + "'"
+}
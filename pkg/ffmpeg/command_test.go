@@ -0,0 +1,130 @@
+package ffmpeg
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestCommandArguments(t *testing.T) {
+	bitrate := "64000"
+	cnf := &Config{FfmpegBinPath: "/usr/bin/ffmpeg"}
+
+	cmd := cnf.Ffmpeg().
+		Input("/tmp/in put.mkv", "-ss", "10").
+		Options(&Options{VideoBitRate: &bitrate}).
+		Args("-sc_threshold", "0").
+		Output("/tmp/it's.mp4", "-movflags", "+faststart")
+
+	expected := []string{"-hide_banner", "-nostats", "-nostdin", "-y", "-ss", "10", "-i", "/tmp/in put.mkv",
+		"-b:v", "64000", "-sc_threshold", "0", "-movflags", "+faststart", "/tmp/it's.mp4"}
+	if args := cmd.Arguments(); !reflect.DeepEqual(args, expected) {
+		t.Errorf("Invalid args, expected: %v, got: %v\n", expected, args)
+	}
+
+	line := `/usr/bin/ffmpeg -hide_banner -nostats -nostdin -y -ss 10 -i '/tmp/in put.mkv' -b:v 64000 -sc_threshold 0 ` +
+		`-movflags +faststart '/tmp/it'\''s.mp4'`
+	if cmd.String() != line {
+		t.Errorf("Invalid command line, expected: %s, got: %s\n", line, cmd.String())
+	}
+}
+
+func TestCommandRun(t *testing.T) {
+	dir := t.TempDir()
+
+	script := func(name, body string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte("#!/bin/sh\n"+body+"\n"), 0700); err != nil {
+			t.Fatalf("Unexpected error: %s\n", err)
+		}
+
+		return path
+	}
+
+	cases := []struct {
+		name     string
+		bin      string
+		timeout  time.Duration
+		stderr   string
+		category Category
+		failed   bool
+	}{
+		{
+			name:   "Success",
+			bin:    script("success", "echo 'frame=1' >&2"),
+			stderr: "frame=1\n",
+		},
+		{
+			name:     "Codec error",
+			bin:      script("codec", "echo 'Unknown encoder '\\''libx999'\\''' >&2; exit 1"),
+			stderr:   "Unknown encoder 'libx999'\n",
+			category: CategoryCodec,
+			failed:   true,
+		},
+		{
+			name:     "Canceled",
+			bin:      script("canceled", "exec sleep 5"),
+			timeout:  50 * time.Millisecond,
+			category: CategoryCanceled,
+			failed:   true,
+		},
+		{
+			name:   "Without binary",
+			bin:    "",
+			failed: true,
+		},
+	}
+
+	for _, testCase := range cases {
+		t.Run(testCase.name, func(t *testing.T) {
+			ctx := context.Background()
+
+			if testCase.timeout != 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, testCase.timeout)
+
+				defer cancel()
+			}
+
+			cmd := (&Config{FfmpegBinPath: testCase.bin}).Ffmpeg("-i", "in.mkv", "out.mp4")
+
+			stderr, err := cmd.Run(ctx)
+			if err != nil && !testCase.failed {
+				t.Fatalf("Unexpected error: %s\n", err)
+			}
+
+			if err == nil && testCase.failed {
+				t.Fatalf("Should be error\n")
+			}
+
+			if stderr != testCase.stderr {
+				t.Errorf("Invalid stderr, expected: %q, got: %q\n", testCase.stderr, stderr)
+			}
+
+			var ffmpegErr *Error
+			if testCase.category == "" {
+				if errors.As(err, &ffmpegErr) {
+					t.Errorf("Invalid error, expected: not ffmpeg error, got: %s\n", err)
+				}
+
+				return
+			}
+
+			if !errors.As(err, &ffmpegErr) {
+				t.Fatalf("Invalid error, expected: ffmpeg error, got: %s\n", err)
+			}
+
+			if ffmpegErr.Category != testCase.category {
+				t.Errorf("Invalid category, expected: %s, got: %s\n", testCase.category, ffmpegErr.Category)
+			}
+
+			if ffmpegErr.Command != cmd.String() {
+				t.Errorf("Invalid command, expected: %s, got: %s\n", cmd.String(), ffmpegErr.Command)
+			}
+		})
+	}
+}
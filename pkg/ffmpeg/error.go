@@ -0,0 +1,127 @@
+package ffmpeg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// TailLines is a number of last stderr lines kept by Error
+const TailLines = 5
+
+// Category of ffmpeg error
+type Category string
+
+// Categories of ffmpeg errors
+const (
+	// CategoryInput means input can't be opened, demuxed or mapped
+	CategoryInput Category = "input"
+	// CategoryCodec means encoder or decoder isn't available or rejects options
+	CategoryCodec Category = "codec"
+	// CategoryFilter means filtergraph can't be parsed or configured
+	CategoryFilter Category = "filter"
+	// CategoryOutput means output can't be written
+	CategoryOutput Category = "output"
+	// CategoryMemory means process exceeds memory limit
+	CategoryMemory Category = "memory"
+	// CategoryCanceled means command is canceled by context
+	CategoryCanceled Category = "canceled"
+	// CategoryUnknown is used for other failures
+	CategoryUnknown Category = "unknown"
+)
+
+// categoryMessages are parts of ffmpeg error messages of categories, the first matched category is used
+var categoryMessages = []struct {
+	category Category
+	messages []string
+}{
+	{CategoryMemory, []string{"cannot allocate memory", "out of memory"}},
+	{CategoryFilter, []string{"error initializing filter", "error reinitializing filters", "no such filter",
+		"error parsing filterchain", "error parsing a filter description", "failed to configure",
+		"has an unconnected output", "cannot find a matching stream for unlabeled input pad"}},
+	{CategoryCodec, []string{"unknown encoder", "encoder not found", "decoder not found", "error while opening encoder",
+		"error while opening decoder", "could not find tag for codec", "not currently supported in container",
+		"unsupported codec"}},
+	{CategoryOutput, []string{"no space left on device", "permission denied", "could not write header",
+		"error opening output", "unable to find a suitable output format", "error writing trailer"}},
+	{CategoryInput, []string{"no such file or directory", "invalid data found when processing input",
+		"moov atom not found", "error opening input", "does not contain any stream", "matches no streams"}},
+}
+
+// Error is a failure of ffmpeg or ffprobe command
+type Error struct {
+	Category Category
+	// Command is the exact command line
+	Command string
+	// Stderr is a tail of stderr output
+	Stderr string
+	Err    error
+
+	name string
+}
+
+func newError(ctx context.Context, c *Command, stderr string, err error) *Error {
+	category := Categorize(stderr)
+
+	switch {
+	case ctx.Err() != nil:
+		category = CategoryCanceled
+	case category == CategoryUnknown && c.limits.MemoryBytes != 0 && killedByLimits(err):
+		category = CategoryMemory
+	}
+
+	return &Error{
+		Category: category,
+		Command:  c.String(),
+		Stderr:   Tail(stderr, TailLines),
+		Err:      err,
+		name:     c.name,
+	}
+}
+
+func (e *Error) Error() string {
+	if e.Stderr == "" {
+		return fmt.Sprintf("%s %s error (%s)", e.name, e.Category, e.Err)
+	}
+
+	return fmt.Sprintf("%s %s error (%s): %s", e.name, e.Category, e.Err, e.Stderr)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// IsCategory reports if err is ffmpeg error of category
+func IsCategory(err error, category Category) bool {
+	var e *Error
+
+	return errors.As(err, &e) && e.Category == category
+}
+
+// Categorize returns category of error printed by ffmpeg to stderr
+func Categorize(stderr string) Category {
+	stderr = strings.ToLower(stderr)
+
+	for _, c := range categoryMessages {
+		for _, message := range c.messages {
+			if strings.Contains(stderr, message) {
+				return c.category
+			}
+		}
+	}
+
+	return CategoryUnknown
+}
+
+// Tail returns last n non-empty lines of s, progress lines ended by carriage return are separate lines
+func Tail(s string, n int) string {
+	lines := strings.FieldsFunc(s, func(r rune) bool {
+		return r == '\n' || r == '\r'
+	})
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,83 @@
+package ffmpeg
+
+import "testing"
+
+func TestCategorize(t *testing.T) {
+	cases := []struct {
+		name     string
+		stderr   string
+		expected Category
+	}{
+		{
+			name:     "Missing input",
+			stderr:   "/tmp/original_video/none.mkv: No such file or directory",
+			expected: CategoryInput,
+		},
+		{
+			name:     "Broken input",
+			stderr:   "[mov,mp4,m4a,3gp,3g2,mj2 @ 0x55] moov atom not found\nin.mp4: Invalid data found when processing input",
+			expected: CategoryInput,
+		},
+		{
+			name: "Invalid filter",
+			stderr: "[AVFilterGraph @ 0x55] No such filter: 'scal'\n" +
+				"Error reinitializing filters!\nFailed to inject frame into filter network: Invalid argument",
+			expected: CategoryFilter,
+		},
+		{
+			name:     "Unsupported codec",
+			stderr:   "[mp4 @ 0x55] Could not find tag for codec vp8 in stream #0, codec not currently supported in container",
+			expected: CategoryCodec,
+		},
+		{
+			name:     "Full disk",
+			stderr:   "av_interleaved_write_frame(): No space left on device\nError writing trailer of out.mp4",
+			expected: CategoryOutput,
+		},
+		{
+			name:     "Other",
+			stderr:   "Conversion failed!",
+			expected: CategoryUnknown,
+		},
+	}
+
+	for _, testCase := range cases {
+		t.Run(testCase.name, func(t *testing.T) {
+			if category := Categorize(testCase.stderr); category != testCase.expected {
+				t.Errorf("Invalid category, expected: %s, got: %s\n", testCase.expected, category)
+			}
+		})
+	}
+}
+
+func TestTail(t *testing.T) {
+	cases := []struct {
+		name     string
+		stderr   string
+		expected string
+	}{
+		{
+			name:     "Lines",
+			stderr:   "1\n2\n3\n4\n",
+			expected: "3\n4",
+		},
+		{
+			name:     "Progress lines",
+			stderr:   "Error while decoding\nframe=1 fps=0\rframe=2 fps=0\rframe=3 fps=0\r\n",
+			expected: "frame=2 fps=0\nframe=3 fps=0",
+		},
+		{
+			name:     "Windows line endings",
+			stderr:   "1\r\n2\r\n3\r\n",
+			expected: "2\n3",
+		},
+	}
+
+	for _, testCase := range cases {
+		t.Run(testCase.name, func(t *testing.T) {
+			if tail := Tail(testCase.stderr, 2); tail != testCase.expected {
+				t.Errorf("Invalid tail, expected: %q, got: %q\n", testCase.expected, tail)
+			}
+		})
+	}
+}
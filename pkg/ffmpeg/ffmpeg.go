@@ -0,0 +1,24 @@
+// Package ffmpeg builds and runs ffmpeg and ffprobe commands.
+// Failed commands return *Error with category, exact command line and tail of stderr
+package ffmpeg
+
+// Config has paths of ffmpeg and ffprobe binaries
+type Config struct {
+	FfmpegBinPath  string
+	FfprobeBinPath string
+	Limits         Limits
+}
+
+// Ffmpeg returns ffmpeg command with args and limits of Config.
+// Banner, progress stats and interactive input are disabled, outputs are overwritten
+func (c *Config) Ffmpeg(args ...string) *Command {
+	return newCommand("ffmpeg", c.FfmpegBinPath, c.Limits, "-hide_banner", "-nostats", "-nostdin", "-y").
+		Threads(c.Limits.Threads).
+		Args(args...)
+}
+
+// Ffprobe returns ffprobe command with args, ffprobe prints errors only.
+// Thread limit isn't applied to ffprobe, it doesn't decode video
+func (c *Config) Ffprobe(args ...string) *Command {
+	return newCommand("ffprobe", c.FfprobeBinPath, c.Limits, "-v", "error").Args(args...)
+}
@@ -0,0 +1,55 @@
+package ffmpeg
+
+import "strings"
+
+// Filter returns description of filter with options, options are values or key=value pairs
+func Filter(name string, options ...string) string {
+	if len(options) == 0 {
+		return name
+	}
+
+	return name + "=" + strings.Join(options, ":")
+}
+
+// Chain joins filters to filterchain, empty filters are skipped
+func Chain(filters ...string) string {
+	chain := make([]string, 0, len(filters))
+
+	for _, f := range filters {
+		if f != "" {
+			chain = append(chain, f)
+		}
+	}
+
+	return strings.Join(chain, ",")
+}
+
+// Link labels input and output pads of filterchain, labels are given without brackets
+func Link(inputs []string, chain string, outputs ...string) string {
+	var b strings.Builder
+
+	for _, label := range inputs {
+		b.WriteString("[" + label + "]")
+	}
+
+	b.WriteString(chain)
+
+	for _, label := range outputs {
+		b.WriteString("[" + label + "]")
+	}
+
+	return b.String()
+}
+
+// Graph joins filterchains to filtergraph
+func Graph(chains ...string) string {
+	return strings.Join(chains, ";")
+}
+
+// EscapeValue escapes option value of filter for using it in filtergraph.
+// The first level escapes option separators, the second one escapes filtergraph separators
+func EscapeValue(value string) string {
+	value = strings.NewReplacer(`\`, `\\`, `'`, `\'`, `:`, `\:`).Replace(value)
+
+	return strings.NewReplacer(`\`, `\\`, `'`, `\'`, `[`, `\[`, `]`, `\]`, `,`, `\,`, `;`, `\;`).Replace(value)
+}
@@ -0,0 +1,22 @@
+package ffmpeg
+
+import "testing"
+
+func TestEscapeValue(t *testing.T) {
+	value := EscapeValue(`/tmp/it's: a, [logo].png`)
+	expected := `/tmp/it\\\'s\\: a\, \[logo\].png`
+
+	if value != expected {
+		t.Errorf("Invalid escaped value, expected: %s, got: %s\n", expected, value)
+	}
+}
+
+func TestGraph(t *testing.T) {
+	chain := Chain(Filter("scale", "w=640", "h=-2"), "", Filter("setsar", "1"))
+	graph := Graph(Link([]string{"0:v"}, chain, "main"), Link([]string{"main", "1:v"}, Filter("overlay"), "out"))
+	expected := "[0:v]scale=w=640:h=-2,setsar=1[main];[main][1:v]overlay[out]"
+
+	if graph != expected {
+		t.Errorf("Invalid filtergraph, expected: %s, got: %s\n", expected, graph)
+	}
+}
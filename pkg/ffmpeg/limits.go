@@ -0,0 +1,69 @@
+package ffmpeg
+
+import (
+	"errors"
+	"strconv"
+)
+
+// maxNice is the lowest priority of process, raising priority by negative niceness isn't allowed
+const maxNice = 19
+
+// Limits restrict resources used by ffmpeg and ffprobe processes, zero values don't restrict resources
+type Limits struct {
+	// Threads is a number of threads of decoders, filters and encoders of ffmpeg
+	Threads int
+	// Nice is a niceness of processes from 0 to 19, it lowers their priority
+	Nice int
+	// MemoryBytes caps memory of each process by memory.max of its cgroup, it requires Cgroup
+	MemoryBytes int64
+	// Cgroup is a path of cgroup v2 directory delegated to service, each process is started in own child cgroup
+	Cgroup string
+}
+
+// Validate checks limits
+func (l Limits) Validate() error {
+	if l.Threads < 0 {
+		return errors.New("threads can't be negative")
+	}
+
+	if l.Nice < 0 || l.Nice > maxNice {
+		return errors.New("nice should be in range from 0 to 19")
+	}
+
+	if l.MemoryBytes < 0 {
+		return errors.New("memory limit can't be negative")
+	}
+
+	if l.MemoryBytes != 0 && l.Cgroup == "" {
+		return errors.New("memory limit requires cgroup")
+	}
+
+	if l.Cgroup != "" && l.MemoryBytes == 0 {
+		return errors.New("cgroup requires memory limit")
+	}
+
+	return nil
+}
+
+// threadArgs adds thread limits to ffmpeg args. Decoder threads are set before each input,
+// encoder threads are set before output, commands have single output which is the last argument
+func threadArgs(args []string, threads int) []string {
+	if threads <= 0 || len(args) == 0 {
+		return append([]string(nil), args...)
+	}
+
+	n := strconv.Itoa(threads)
+	limited := make([]string, 0, len(args)+8)
+	limited = append(limited, "-filter_threads", n, "-filter_complex_threads", n)
+
+	last := len(args) - 1
+	for i, arg := range args {
+		if arg == "-i" || i == last {
+			limited = append(limited, "-threads", n)
+		}
+
+		limited = append(limited, arg)
+	}
+
+	return limited
+}
@@ -0,0 +1,93 @@
+//go:build linux
+// +build linux
+
+package ffmpeg
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"syscall"
+)
+
+// limiter applies niceness and memory caps of limits to process before it executes
+type limiter struct {
+	limits Limits
+	nice   string
+	cgroup *os.File
+}
+
+// prepare finds nice binary and creates child cgroup of process when memory is capped
+func (l Limits) prepare() (*limiter, error) {
+	lm := &limiter{limits: l}
+
+	if l.Nice != 0 {
+		nice, err := exec.LookPath("nice")
+		if err != nil {
+			return nil, err
+		}
+
+		lm.nice = nice
+	}
+
+	if l.Cgroup == "" || l.MemoryBytes == 0 {
+		return lm, nil
+	}
+
+	dir, err := os.MkdirTemp(l.Cgroup, "ffmpeg-")
+	if err != nil {
+		return nil, err
+	}
+
+	if err = writeCgroupFile(dir, "memory.max", strconv.FormatInt(l.MemoryBytes, 10)); err != nil {
+		os.Remove(dir)
+
+		return nil, err
+	}
+
+	if lm.cgroup, err = os.Open(dir); err != nil {
+		os.Remove(dir)
+
+		return nil, err
+	}
+
+	return lm, nil
+}
+
+// apply makes cmd run by nice and start in child cgroup, so limits are in effect from its first instruction
+func (lm *limiter) apply(cmd *exec.Cmd) {
+	if lm.nice != "" {
+		cmd.Args = append([]string{"nice", "-n", strconv.Itoa(lm.limits.Nice), cmd.Path}, cmd.Args[1:]...)
+		cmd.Path = lm.nice
+	}
+
+	if lm.cgroup != nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{UseCgroupFD: true, CgroupFD: int(lm.cgroup.Fd())}
+	}
+}
+
+// release removes cgroup of exited process
+func (lm *limiter) release() {
+	if lm.cgroup != nil {
+		lm.cgroup.Close()
+		os.Remove(lm.cgroup.Name())
+	}
+}
+
+// killedByLimits reports if process is killed by kernel, cgroup kills processes which exceed memory.max
+func killedByLimits(err error) bool {
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) {
+		return false
+	}
+
+	status, ok := exitErr.Sys().(syscall.WaitStatus)
+
+	return ok && status.Signaled() && status.Signal() == syscall.SIGKILL
+}
+
+func writeCgroupFile(dir, name, value string) error {
+	return os.WriteFile(filepath.Join(dir, name), []byte(value), 0)
+}
@@ -0,0 +1,30 @@
+package ffmpeg
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLimitsLinux(t *testing.T) {
+	bin := filepath.Join(t.TempDir(), "ffmpeg")
+	// niceness is applied before process executes
+	script := "#!/bin/sh\necho \"$(nice)\" >&2\n"
+
+	if err := os.WriteFile(bin, []byte(script), 0700); err != nil {
+		t.Fatalf("Unexpected error: %s\n", err)
+	}
+
+	cnf := &Config{FfmpegBinPath: bin, Limits: Limits{Nice: 19}}
+
+	stderr, err := cnf.Ffmpeg("-i", "in.mkv", "out.mp4").Run(context.Background())
+	if err != nil {
+		t.Fatalf("Unexpected error: %s\n", err)
+	}
+
+	expected := "19\n"
+	if stderr != expected {
+		t.Errorf("Invalid limits, expected: %q, got: %q\n", expected, stderr)
+	}
+}
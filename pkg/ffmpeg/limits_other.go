@@ -0,0 +1,28 @@
+//go:build !linux
+// +build !linux
+
+package ffmpeg
+
+import (
+	"errors"
+	"os/exec"
+)
+
+// limiter applies limits to started process, only thread limits are supported on this platform
+type limiter struct{}
+
+func (l Limits) prepare() (*limiter, error) {
+	if l.Nice != 0 || l.MemoryBytes != 0 {
+		return nil, errors.New("niceness and memory limits are supported on linux only")
+	}
+
+	return &limiter{}, nil
+}
+
+func (lm *limiter) apply(*exec.Cmd) {}
+
+func (lm *limiter) release() {}
+
+func killedByLimits(error) bool {
+	return false
+}
@@ -0,0 +1,67 @@
+package ffmpeg
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestThreadArgs(t *testing.T) {
+	cases := []struct {
+		name     string
+		args     []string
+		threads  int
+		expected []string
+	}{
+		{
+			name:     "Without limit",
+			args:     []string{"-i", "in.mkv", "out.mp4"},
+			expected: []string{"-i", "in.mkv", "out.mp4"},
+		},
+		{
+			name:    "Two inputs",
+			args:    []string{"-i", "in.mkv", "-i", "logo.png", "-c:v", "libx264", "out.mp4"},
+			threads: 2,
+			expected: []string{"-filter_threads", "2", "-filter_complex_threads", "2",
+				"-threads", "2", "-i", "in.mkv", "-threads", "2", "-i", "logo.png", "-c:v", "libx264",
+				"-threads", "2", "out.mp4"},
+		},
+	}
+
+	for _, testCase := range cases {
+		t.Run(testCase.name, func(t *testing.T) {
+			if args := threadArgs(testCase.args, testCase.threads); !reflect.DeepEqual(args, testCase.expected) {
+				t.Errorf("Invalid args, expected: %v, got: %v\n", testCase.expected, args)
+			}
+		})
+	}
+}
+
+func TestLimitsValidate(t *testing.T) {
+	cases := []struct {
+		name         string
+		limits       Limits
+		errorPresent bool
+	}{
+		{name: "Without limits", limits: Limits{}},
+		{name: "All limits", limits: Limits{Threads: 4, Nice: 10, MemoryBytes: 1 << 30, Cgroup: "/sys/fs/cgroup/compressrv"}},
+		{name: "Negative threads", limits: Limits{Threads: -1}, errorPresent: true},
+		{name: "Invalid nice", limits: Limits{Nice: 20}, errorPresent: true},
+		{name: "Negative nice", limits: Limits{Nice: -5}, errorPresent: true},
+		{name: "Negative memory", limits: Limits{MemoryBytes: -1}, errorPresent: true},
+		{name: "Memory without cgroup", limits: Limits{MemoryBytes: 1 << 30}, errorPresent: true},
+		{name: "Cgroup without memory", limits: Limits{Cgroup: "/sys/fs/cgroup/compressrv"}, errorPresent: true},
+	}
+
+	for _, testCase := range cases {
+		t.Run(testCase.name, func(t *testing.T) {
+			err := testCase.limits.Validate()
+			if err != nil && !testCase.errorPresent {
+				t.Errorf("Unexpected error: %s\n", err)
+			}
+
+			if err == nil && testCase.errorPresent {
+				t.Errorf("Should be error\n")
+			}
+		})
+	}
+}
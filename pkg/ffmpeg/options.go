@@ -0,0 +1,61 @@
+package ffmpeg
+
+import "fmt"
+
+// Options are output options of converted video, nil options aren't set
+type Options struct {
+	Aspect           *string
+	Resolution       *string
+	VideoBitRate     *string
+	VideoMaxBitRate  *int
+	AudioRate        *int
+	KeyframeInterval *int
+	AudioCodec       *string
+	AudioBitrate     *string
+	AudioChannels    *int
+	BufferSize       *int
+	Duration         *string
+	SeekTime         *string
+	MovFlags         *string
+	VideoFilter      *string
+	AudioFilter      *string
+}
+
+// Args returns ffmpeg arguments of options, order of options is fixed
+func (o *Options) Args() []string {
+	if o == nil {
+		return nil
+	}
+
+	var args []string
+
+	str := func(flag string, value *string) {
+		if value != nil {
+			args = append(args, flag, *value)
+		}
+	}
+
+	num := func(flag string, value *int) {
+		if value != nil {
+			args = append(args, flag, fmt.Sprintf("%d", *value))
+		}
+	}
+
+	str("-aspect", o.Aspect)
+	str("-s", o.Resolution)
+	str("-b:v", o.VideoBitRate)
+	num("-maxrate", o.VideoMaxBitRate)
+	num("-ar", o.AudioRate)
+	num("-g", o.KeyframeInterval)
+	str("-c:a", o.AudioCodec)
+	str("-ab", o.AudioBitrate)
+	num("-ac", o.AudioChannels)
+	num("-bufsize", o.BufferSize)
+	str("-t", o.Duration)
+	str("-ss", o.SeekTime)
+	str("-movflags", o.MovFlags)
+	str("-vf", o.VideoFilter)
+	str("-af", o.AudioFilter)
+
+	return args
+}
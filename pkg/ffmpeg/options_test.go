@@ -0,0 +1,35 @@
+package ffmpeg
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestOptionsArgs(t *testing.T) {
+	resolution, bitrate, filter, flags := "800x600", "64000", "fps=30", "+faststart"
+	bufSize, rate := 64000, 48000
+
+	cases := []struct {
+		name     string
+		opts     *Options
+		expected []string
+	}{
+		{name: "Nil options", opts: nil, expected: nil},
+		{name: "Empty options", opts: &Options{}, expected: nil},
+		{
+			name: "Video and audio options",
+			opts: &Options{VideoFilter: &filter, MovFlags: &flags, AudioRate: &rate, BufferSize: &bufSize,
+				VideoBitRate: &bitrate, Resolution: &resolution},
+			expected: []string{"-s", "800x600", "-b:v", "64000", "-ar", "48000", "-bufsize", "64000",
+				"-movflags", "+faststart", "-vf", "fps=30"},
+		},
+	}
+
+	for _, testCase := range cases {
+		t.Run(testCase.name, func(t *testing.T) {
+			if args := testCase.opts.Args(); !reflect.DeepEqual(args, testCase.expected) {
+				t.Errorf("Invalid args, expected: %v, got: %v\n", testCase.expected, args)
+			}
+		})
+	}
+}
@@ -0,0 +1,71 @@
+package ffmpeg
+
+import "bytes"
+
+// stderrLimit is a number of last stderr bytes kept while command runs,
+// summaries of filters and errors are printed at the end of output
+const stderrLimit = 1 << 20
+
+// ringBuffer keeps last size bytes written to it
+type ringBuffer struct {
+	buf []byte
+	// start is a position of the oldest byte when buffer is full
+	start int
+	full  bool
+	// truncated is set when older bytes are overwritten
+	truncated bool
+}
+
+func newRingBuffer(size int) *ringBuffer {
+	return &ringBuffer{buf: make([]byte, 0, size)}
+}
+
+func (r *ringBuffer) Write(p []byte) (int, error) {
+	n := len(p)
+	size := cap(r.buf)
+
+	if len(p) >= size {
+		r.truncated = r.truncated || len(r.buf) != 0 || len(p) > size
+		r.buf = append(r.buf[:0], p[len(p)-size:]...)
+		r.start, r.full = 0, true
+
+		return n, nil
+	}
+
+	if !r.full {
+		free := size - len(r.buf)
+		if len(p) <= free {
+			r.buf = append(r.buf, p...)
+
+			return n, nil
+		}
+
+		r.buf = append(r.buf, p[:free]...)
+		p = p[free:]
+		r.full = true
+	}
+
+	r.truncated = r.truncated || len(p) > 0
+
+	for len(p) > 0 {
+		copied := copy(r.buf[r.start:], p)
+		p = p[copied:]
+		r.start = (r.start + copied) % size
+	}
+
+	return n, nil
+}
+
+// String returns kept output, the oldest line is dropped when it's cut
+func (r *ringBuffer) String() string {
+	if !r.full {
+		return string(r.buf)
+	}
+
+	out := append(append(make([]byte, 0, len(r.buf)), r.buf[r.start:]...), r.buf[:r.start]...)
+	if i := bytes.IndexAny(out, "\r\n"); r.truncated && i != -1 {
+		out = out[i+1:]
+	}
+
+	return string(out)
+}
@@ -0,0 +1,53 @@
+package ffmpeg
+
+import "testing"
+
+func TestRingBuffer(t *testing.T) {
+	cases := []struct {
+		name     string
+		writes   []string
+		expected string
+	}{
+		{
+			name:     "Within size",
+			writes:   []string{"ab\n", "cd\n"},
+			expected: "ab\ncd\n",
+		},
+		{
+			name:     "Exactly size",
+			writes:   []string{"abc\n", "def\n"},
+			expected: "abc\ndef\n",
+		},
+		{
+			name:     "Overwritten lines",
+			writes:   []string{"abc\n", "def\n", "gh\n", "ij\n"},
+			expected: "gh\nij\n",
+		},
+		{
+			name:     "Cut progress line",
+			writes:   []string{"frame=1\rframe=2\rframe=3\r", "done\n"},
+			expected: "done\n",
+		},
+		{
+			name:     "Large write",
+			writes:   []string{"a\n", "0123456789\nlast\n"},
+			expected: "last\n",
+		},
+	}
+
+	for _, testCase := range cases {
+		t.Run(testCase.name, func(t *testing.T) {
+			buf := newRingBuffer(8)
+
+			for _, w := range testCase.writes {
+				if n, err := buf.Write([]byte(w)); err != nil || n != len(w) {
+					t.Fatalf("Unexpected write of %d bytes: %v\n", n, err)
+				}
+			}
+
+			if got := buf.String(); got != testCase.expected {
+				t.Errorf("Invalid output, expected: %q, got: %q\n", testCase.expected, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,95 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/Hargeon/compressrv/pkg/ffmpeg"
+	"github.com/Hargeon/compressrv/pkg/response"
+	"github.com/Hargeon/compressrv/pkg/service/compressor"
+
+	"go.uber.org/zap"
+)
+
+// admit probes original video and checks it by admission limits of service,
+// resp is filled by original video or by rejection when video can't be converted
+func (h *CompressorHandler) admit(req *compressor.Request, videoName string, resp *response.Response) {
+	fileInfo, err := h.srv.VideoInfo(videoName)
+	if err != nil {
+		h.logger.Error("original video video info",
+			zap.String("Error", err.Error()),
+			zap.Int64("VideoID", req.VideoID),
+			ffmpegCommand(err))
+
+		if !notVideo(err) {
+			resp.Error = "Error occurred when probing original video"
+
+			return
+		}
+
+		h.reject(req, resp, &compressor.AdmissionError{Reason: compressor.RejectNotVideo, Value: "no video stream"})
+
+		return
+	}
+
+	resp.OriginalVideo = &response.OriginalVideo{
+		ID:    req.VideoID,
+		Video: *fileInfo,
+	}
+
+	stat, err := os.Stat(videoName)
+	if err != nil {
+		h.logger.Error("original video size",
+			zap.String("Error", err.Error()),
+			zap.Int64("VideoID", req.VideoID))
+
+		resp.Error = "error occurred when getting size of original video"
+
+		return
+	}
+
+	err = h.srv.Admission.Admit(fileInfo, stat.Size())
+
+	var admissionErr *compressor.AdmissionError
+	if errors.As(err, &admissionErr) {
+		h.reject(req, resp, admissionErr)
+	}
+}
+
+// invalidInputMessages are parts of ffmpeg messages about original video which isn't a media file
+var invalidInputMessages = []string{"invalid data found when processing input", "moov atom not found",
+	"ebml header parsing failed", "does not contain any stream"}
+
+// notVideo reports if probe err is caused by original video which isn't a media file or has no video stream.
+// Missing or inaccessible file and other failures of input aren't caused by video
+func notVideo(err error) bool {
+	if errors.Is(err, compressor.ErrNoVideoStream) {
+		return true
+	}
+
+	var ffmpegErr *ffmpeg.Error
+	if !errors.As(err, &ffmpegErr) || ffmpegErr.Category != ffmpeg.CategoryInput {
+		return false
+	}
+
+	stderr := strings.ToLower(ffmpegErr.Stderr)
+	for _, message := range invalidInputMessages {
+		if strings.Contains(stderr, message) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// reject fills resp by rejection of original video
+func (h *CompressorHandler) reject(req *compressor.Request, resp *response.Response, err *compressor.AdmissionError) {
+	h.logger.Info("Reject original video",
+		zap.String("Reason", err.Error()),
+		zap.Int64("VideoID", req.VideoID))
+
+	resp.Rejection = err.Response()
+	resp.Error = fmt.Sprintf("Original video rejected: %s", err)
+}
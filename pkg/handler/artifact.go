@@ -0,0 +1,46 @@
+package handler
+
+import (
+	"context"
+	"os"
+
+	"github.com/Hargeon/compressrv/pkg/response"
+	"github.com/Hargeon/compressrv/pkg/service/compressor"
+
+	"go.uber.org/zap"
+)
+
+// artifact is a directory of files made from original video besides converted video.
+// Artifacts are uploaded after converted video is made and verified, so failed conversion leaves nothing in cloud
+type artifact struct {
+	name string
+	dir  string
+	// fill fills response by storage keys of files uploaded under prefix
+	fill func(prefix string)
+}
+
+// uploadArtifacts uploads artifacts and fills resp by their keys
+func (h *CompressorHandler) uploadArtifacts(ctx context.Context, req *compressor.Request,
+	artifacts []*artifact, resp *response.Response) {
+	for _, a := range artifacts {
+		prefix, err := h.srv.UploadDir(ctx, req.VideoServiceID, a.dir)
+		if err != nil {
+			h.logger.Error("upload "+a.name,
+				zap.String("Error", err.Error()),
+				zap.Int64("VideoID", req.VideoID))
+
+			resp.Error = "error occurred when uploading " + a.name
+
+			return
+		}
+
+		a.fill(prefix)
+	}
+}
+
+// removeArtifacts removes local files of artifacts
+func removeArtifacts(artifacts []*artifact) {
+	for _, a := range artifacts {
+		os.RemoveAll(a.dir)
+	}
+}
@@ -0,0 +1,28 @@
+package handler
+
+import (
+	"context"
+
+	"github.com/Hargeon/compressrv/pkg/response"
+	"github.com/Hargeon/compressrv/pkg/service/compressor"
+
+	"go.uber.org/zap"
+)
+
+// selectBitrate selects bitrate by content of original video for conversion, resp is filled by it after conversion
+func (h *CompressorHandler) selectBitrate(ctx context.Context, req *compressor.Request,
+	videoName string, resp *response.Response) {
+	selected, err := h.srv.SelectBitrate(ctx, req, videoName)
+	if err != nil {
+		h.logger.Error("Select bitrate of original video",
+			zap.String("Error", err.Error()),
+			zap.Int64("VideoID", req.VideoID),
+			ffmpegCommand(err))
+
+		resp.Error = "Error occurred when selecting bitrate"
+
+		return
+	}
+
+	req.AutoBitrate.Selected = selected
+}
@@ -3,7 +3,9 @@ package handler
 
 import (
 	"context"
+	"fmt"
 	"os"
+	"path/filepath"
 
 	"github.com/Hargeon/compressrv/pkg/response"
 	"github.com/Hargeon/compressrv/pkg/service"
@@ -12,6 +14,12 @@ import (
 	"go.uber.org/zap"
 )
 
+// Working directories of jobs are created in ROOT/tmp
+const (
+	workDirParent  = "tmp"
+	workDirPattern = "job_"
+)
+
 // CompressorHandler uses for compressing video file
 type CompressorHandler struct {
 	srv    *service.Service
@@ -26,49 +34,154 @@ func NewHandler(s *service.Service, logger *zap.Logger) *CompressorHandler {
 // Compress video file and build response
 func (h *CompressorHandler) Compress(ctx context.Context, req *compressor.Request) *response.Response {
 	resp := &response.Response{RequestID: req.RequestID}
-	videoName, err := h.srv.Download(ctx, req.VideoServiceID)
+
+	err := h.srv.Presets.Apply(req)
+	if err == nil {
+		err = req.Validate()
+	}
 
 	if err != nil {
-		h.logger.Error("Download original video",
+		h.logger.Error("Validate request",
 			zap.String("Error", err.Error()),
 			zap.Int64("VideoID", req.VideoID))
 
-		resp.Error = "Can't download original video from cloud"
+		resp.Error = fmt.Sprintf("Invalid request: %s", err)
 
 		return resp
 	}
 
-	defer func() {
-		os.Remove(videoName)
-	}()
-
-	fileInfo, err := h.srv.VideoInfo(videoName)
-	if err == nil {
-		resp.OriginalVideo = &response.OriginalVideo{
-			ID:    req.VideoID,
-			Video: *fileInfo,
-		}
-	} else {
-		h.logger.Error("original video video info",
+	// jobs run concurrently, so each of them downloads and converts files in own directory
+	dir, err := os.MkdirTemp(filepath.Join(os.Getenv("ROOT"), workDirParent), workDirPattern)
+	if err != nil {
+		h.logger.Error("Create working directory",
 			zap.String("Error", err.Error()),
 			zap.Int64("VideoID", req.VideoID))
+
+		resp.Error = "error occurred when creating working directory"
+
+		return resp
 	}
 
-	convertedVideoPath, err := h.srv.Convert(ctx, req, videoName)
+	defer func() {
+		os.RemoveAll(dir)
+	}()
+
+	req.WorkDir = dir
+
+	videoName, err := h.srv.Download(ctx, req.VideoServiceID, dir)
+
 	if err != nil {
-		h.logger.Error("Convert original video",
+		h.logger.Error("Download original video",
 			zap.String("Error", err.Error()),
 			zap.Int64("VideoID", req.VideoID))
 
-		resp.Error = "Error occurred when converting video"
+		resp.Error = "Can't download original video from cloud"
+
+		return resp
+	}
+
+	h.admit(req, videoName, resp)
 
+	if resp.Error != "" {
 		return resp
 	}
 
+	// artifacts are made before conversion, but uploaded only after converted video succeeds
+	var artifacts []*artifact
+
 	defer func() {
-		os.Remove(convertedVideoPath)
+		removeArtifacts(artifacts)
 	}()
 
+	if req.WithThumbnails() {
+		thumbs := h.thumbnails(ctx, req, videoName, resp)
+		if resp.Error != "" {
+			return resp
+		}
+
+		artifacts = append(artifacts, thumbs)
+	}
+
+	if req.Preview != nil {
+		preview := h.preview(ctx, req, videoName, resp)
+		if resp.Error != "" {
+			return resp
+		}
+
+		artifacts = append(artifacts, preview)
+	}
+
+	if req.WithSubtitleFiles() {
+		subtitles := h.subtitles(ctx, req, videoName, resp)
+		if resp.Error != "" {
+			return resp
+		}
+
+		if subtitles != nil {
+			artifacts = append(artifacts, subtitles)
+		}
+	}
+
+	if req.AutoBitrate != nil {
+		h.selectBitrate(ctx, req, videoName, resp)
+
+		if resp.Error != "" {
+			return resp
+		}
+	}
+
+	if req.Adaptive() {
+		h.compressPackage(ctx, req, videoName, artifacts, resp)
+
+		return resp
+	}
+
+	if req.Watermark != nil {
+		imagePath, err := h.srv.Download(ctx, req.Watermark.ImageServiceID, dir)
+		if err != nil {
+			h.logger.Error("Download watermark image",
+				zap.String("Error", err.Error()),
+				zap.Int64("VideoID", req.VideoID))
+
+			resp.Error = "Can't download watermark image from cloud"
+
+			return resp
+		}
+
+		req.Watermark.ImagePath = imagePath
+	}
+
+	if req.Loudness != nil {
+		h.measureOriginalLoudness(ctx, req, videoName, resp)
+
+		if resp.Error != "" {
+			return resp
+		}
+	}
+
+	convertedVideoPath := h.convert(ctx, req, videoName, resp)
+	if resp.Error != "" {
+		return resp
+	}
+
+	if req.Verify {
+		if err = h.srv.Verify(ctx, req, videoName, convertedVideoPath); err != nil {
+			h.logger.Error("Verify converted video",
+				zap.String("Error", err.Error()),
+				zap.Int64("VideoID", req.VideoID),
+				ffmpegCommand(err))
+
+			resp.Error = "Converted video failed verification"
+
+			return resp
+		}
+	}
+
+	h.uploadArtifacts(ctx, req, artifacts, resp)
+	if resp.Error != "" {
+		return resp
+	}
+
 	convertedVideo, err := os.Open(convertedVideoPath)
 	if err != nil {
 		h.logger.Error("open converted video",
@@ -80,6 +193,8 @@ func (h *CompressorHandler) Compress(ctx context.Context, req *compressor.Reques
 		return resp
 	}
 
+	defer convertedVideo.Close()
+
 	id, err := h.srv.Upload(ctx, req.VideoServiceID, convertedVideo)
 	if err != nil {
 		h.logger.Error("upload converted video",
@@ -91,11 +206,12 @@ func (h *CompressorHandler) Compress(ctx context.Context, req *compressor.Reques
 		return resp
 	}
 
-	fileInfo, err = h.srv.VideoInfo(convertedVideoPath)
+	fileInfo, err := h.srv.VideoInfo(convertedVideoPath)
 	if err != nil {
 		h.logger.Error("converted video video info",
 			zap.String("Error", err.Error()),
-			zap.Int64("VideoID", req.VideoID))
+			zap.Int64("VideoID", req.VideoID),
+			ffmpegCommand(err))
 
 		resp.Error = "error occurred when getting stats converted video"
 
@@ -109,6 +225,14 @@ func (h *CompressorHandler) Compress(ctx context.Context, req *compressor.Reques
 		UserID:    req.UserID,
 	}
 
+	if req.AutoBitrate != nil {
+		resp.AutoBitrate = req.AutoBitrate.Selected
+	}
+
+	if resp.Loudness != nil {
+		h.measureConvertedLoudness(ctx, req, convertedVideoPath, resp)
+	}
+
 	stat, err := convertedVideo.Stat()
 	if err == nil {
 		resp.ConvertedVideo.Size = stat.Size()
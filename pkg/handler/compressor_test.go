@@ -7,9 +7,12 @@ import (
 	"io"
 	"log"
 	"os"
+	"path/filepath"
 	"reflect"
+	"strings"
 	"testing"
 
+	"github.com/Hargeon/compressrv/pkg/ffmpeg"
 	"github.com/Hargeon/compressrv/pkg/response"
 	"github.com/Hargeon/compressrv/pkg/service"
 	"github.com/Hargeon/compressrv/pkg/service/compressor"
@@ -36,7 +39,7 @@ func init() {
 
 type errorCloud struct{}
 
-func (s *errorCloud) Download(ctx context.Context, id string) (string, error) {
+func (s *errorCloud) Download(ctx context.Context, id, dir string) (string, error) {
 	return "", errors.New("mock failed")
 }
 
@@ -44,11 +47,15 @@ func (s *errorCloud) Upload(ctx context.Context, fileName string, file io.Reader
 	return "", errors.New("mock failed")
 }
 
+func (s *errorCloud) UploadDir(ctx context.Context, name, dir string) (string, error) {
+	return "", errors.New("mock failed")
+}
+
 type successCloud struct{}
 
-func (s *successCloud) Download(ctx context.Context, id string) (string, error) {
+func (s *successCloud) Download(ctx context.Context, id, dir string) (string, error) {
 	src := fmt.Sprintf("%s/tmp/original_video/test_video.mkv", os.Getenv("ROOT"))
-	dst := fmt.Sprintf("%s/tmp/converted_video/temp_original_file.mkv", os.Getenv("ROOT"))
+	dst := filepath.Join(dir, "temp_original_file.mkv")
 	sourceFileStat, err := os.Stat(src)
 
 	if err != nil {
@@ -84,6 +91,19 @@ func (s *successCloud) Upload(ctx context.Context, fileName string, file io.Read
 	return "temp_converted_file.mkv", nil
 }
 
+func (s *successCloud) UploadDir(ctx context.Context, name, dir string) (string, error) {
+	return "temp_converted_package", nil
+}
+
+// noDirUploadCloud fails to upload directories, artifacts of failed conversion mustn't be uploaded
+type noDirUploadCloud struct {
+	successCloud
+}
+
+func (n *noDirUploadCloud) UploadDir(ctx context.Context, name, dir string) (string, error) {
+	return "", errors.New("failed mock dir upload")
+}
+
 type errorCompressService struct{}
 
 func (e *errorCompressService) Convert(ctx context.Context, opt *compressor.Request, originalVideo string) (string, error) {
@@ -94,11 +114,60 @@ func (e *errorCompressService) VideoInfo(path string) (*response.Video, error) {
 	return nil, errors.New("failed mock file info")
 }
 
+func (e *errorCompressService) SelectBitrate(ctx context.Context, opt *compressor.Request, originalVideo string) (*response.AutoBitrate, error) {
+	return nil, errors.New("failed mock file bitrate")
+}
+
+func (e *errorCompressService) Decide(opt *compressor.Request, originalVideo string) (string, error) {
+	return "", errors.New("failed mock file decide")
+}
+
+func (e *errorCompressService) Remux(ctx context.Context, opt *compressor.Request, originalVideo string) (string, error) {
+	return "", errors.New("failed mock file remux")
+}
+
+func (e *errorCompressService) Verify(ctx context.Context, opt *compressor.Request, originalVideo, convertedVideo string) error {
+	return errors.New("failed mock file verify")
+}
+
+// brokenOutputService converts video which fails verification
+type brokenOutputService struct {
+	successCompressService
+}
+
+func (b *brokenOutputService) Verify(ctx context.Context, opt *compressor.Request, originalVideo, convertedVideo string) error {
+	return errors.New("failed mock file verify")
+}
+
+// notVideoService probes original video without video stream
+type notVideoService struct {
+	errorCompressService
+}
+
+func (n *notVideoService) VideoInfo(path string) (*response.Video, error) {
+	return nil, compressor.ErrNoVideoStream
+}
+
+// admittedErrorService probes original video and fails other steps
+type admittedErrorService struct {
+	errorCompressService
+}
+
+func (a *admittedErrorService) VideoInfo(path string) (*response.Video, error) {
+	video := testOriginalVideo.Video
+
+	return &video, nil
+}
+
+func (e *errorCompressService) Package(ctx context.Context, opt *compressor.Request, originalVideo string) (string, *response.Package, error) {
+	return "", nil, errors.New("failed mock file package")
+}
+
 type successCompressService struct{}
 
 func (s *successCompressService) Convert(ctx context.Context, opt *compressor.Request, originalVideo string) (string, error) {
 	src := fmt.Sprintf("%s/tmp/original_video/bitrate.mkv", os.Getenv("ROOT"))
-	dst := fmt.Sprintf("%s/tmp/converted_video/temp_converted_file.mkv", os.Getenv("ROOT"))
+	dst := filepath.Join(opt.WorkDir, "temp_converted_file.mkv")
 	sourceFileStat, err := os.Stat(src)
 
 	if err != nil {
@@ -129,6 +198,32 @@ func (s *successCompressService) Convert(ctx context.Context, opt *compressor.Re
 	return dst, nil
 }
 
+func (s *successCompressService) SelectBitrate(ctx context.Context, opt *compressor.Request, originalVideo string) (*response.AutoBitrate, error) {
+	return &response.AutoBitrate{Quality: 23, Samples: 5, Bitrate: 1200000}, nil
+}
+
+func (s *successCompressService) Decide(opt *compressor.Request, originalVideo string) (string, error) {
+	return compressor.DecisionConvert, nil
+}
+
+func (s *successCompressService) Remux(ctx context.Context, opt *compressor.Request, originalVideo string) (string, error) {
+	return s.Convert(ctx, opt, originalVideo)
+}
+
+// decidedService remuxes or reuses original video by decision
+type decidedService struct {
+	successCompressService
+	decision string
+}
+
+func (d *decidedService) Decide(opt *compressor.Request, originalVideo string) (string, error) {
+	return d.decision, nil
+}
+
+func (s *successCompressService) Verify(ctx context.Context, opt *compressor.Request, originalVideo, convertedVideo string) error {
+	return nil
+}
+
 func (s *successCompressService) VideoInfo(path string) (*response.Video, error) {
 	resp := &response.Video{
 		Bitrate:     64000,
@@ -141,6 +236,95 @@ func (s *successCompressService) VideoInfo(path string) (*response.Video, error)
 	return resp, nil
 }
 
+func (e *errorCompressService) Thumbnails(ctx context.Context, opt *compressor.Request, originalVideo string) (string, *response.Thumbnails, error) {
+	return "", nil, errors.New("failed mock file thumbnails")
+}
+
+func (e *errorCompressService) Preview(ctx context.Context, opt *compressor.Request, originalVideo string) (string, *response.Preview, error) {
+	return "", nil, errors.New("failed mock file preview")
+}
+
+func (e *errorCompressService) MeasureLoudness(ctx context.Context, opt *compressor.Request, path string) (*response.LoudnessLevel, error) {
+	return nil, errors.New("failed mock file loudness")
+}
+
+func (e *errorCompressService) Subtitles(ctx context.Context, opt *compressor.Request, originalVideo string) (string, []response.SubtitleFile, error) {
+	return "", nil, errors.New("failed mock file subtitles")
+}
+
+func (s *successCompressService) Package(ctx context.Context, opt *compressor.Request, originalVideo string) (string, *response.Package, error) {
+	dir := filepath.Join(opt.WorkDir, "temp_package")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", nil, err
+	}
+
+	err := os.WriteFile(fmt.Sprintf("%s/master.m3u8", dir), []byte("#EXTM3U\n"), 0o600)
+	if err != nil {
+		return "", nil, err
+	}
+
+	pkg := &response.Package{
+		Format:   opt.Output,
+		Manifest: "master.m3u8",
+		Variants: []response.Variant{
+			{Playlist: "720p.m3u8", Bitrate: 2800000, Bandwidth: 3124000, ResolutionX: 1280, ResolutionY: 720},
+		},
+	}
+
+	return dir, pkg, nil
+}
+
+func (s *successCompressService) Thumbnails(ctx context.Context, opt *compressor.Request, originalVideo string) (string, *response.Thumbnails, error) {
+	dir := filepath.Join(opt.WorkDir, "temp_thumbnails")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", nil, err
+	}
+
+	thumbs := &response.Thumbnails{
+		Poster:     "poster.jpg",
+		Thumbnails: []string{"thumbnail_000.jpg"},
+	}
+
+	return dir, thumbs, nil
+}
+
+func (s *successCompressService) Preview(ctx context.Context, opt *compressor.Request, originalVideo string) (string, *response.Preview, error) {
+	dir := filepath.Join(opt.WorkDir, "temp_preview")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", nil, err
+	}
+
+	return dir, &response.Preview{GIF: "preview.gif"}, nil
+}
+
+func (s *successCompressService) MeasureLoudness(ctx context.Context, opt *compressor.Request, path string) (*response.LoudnessLevel, error) {
+	if strings.Contains(path, "temp_converted_file") {
+		return &response.LoudnessLevel{Integrated: -23, TruePeak: -2, LRA: 5, Threshold: -33}, nil
+	}
+
+	return &response.LoudnessLevel{Integrated: -27.5, TruePeak: -4, LRA: 6, Threshold: -38}, nil
+}
+
+func (s *successCompressService) Subtitles(ctx context.Context, opt *compressor.Request, originalVideo string) (string, []response.SubtitleFile, error) {
+	dir := filepath.Join(opt.WorkDir, "temp_subtitles")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", nil, err
+	}
+
+	return dir, []response.SubtitleFile{{Track: 0, Language: "eng", Key: "subtitle_0.vtt"}}, nil
+}
+
+var testOriginalVideo = &response.OriginalVideo{
+	ID: 1,
+	Video: response.Video{
+		Bitrate:     64000,
+		ResolutionX: 800,
+		ResolutionY: 600,
+		RatioX:      4,
+		RatioY:      3,
+	},
+}
+
 func TestCompress(t *testing.T) {
 	logger := zap.NewExample()
 
@@ -173,7 +357,7 @@ func TestCompress(t *testing.T) {
 			name: "Invalid converting video",
 			srv: &service.Service{
 				VideoStorage: &successCloud{},
-				Compressor:   &errorCompressService{},
+				Compressor:   &admittedErrorService{},
 			},
 			req: &compressor.Request{
 				UserID:         1,
@@ -185,8 +369,10 @@ func TestCompress(t *testing.T) {
 				VideoServiceID: "mock_service",
 			},
 			expectedResponse: &response.Response{
-				RequestID: 1,
-				Error:     "Error occurred when converting video",
+				RequestID:     1,
+				Decision:      compressor.DecisionConvert,
+				OriginalVideo: testOriginalVideo,
+				Error:         "Error occurred when converting video",
 			},
 		},
 		{
@@ -206,6 +392,161 @@ func TestCompress(t *testing.T) {
 			},
 			expectedResponse: &response.Response{
 				RequestID: 1,
+				Decision:  compressor.DecisionConvert,
+				OriginalVideo: &response.OriginalVideo{
+					ID: 1,
+					Video: response.Video{
+						Bitrate:     64000,
+						ResolutionX: 800,
+						ResolutionY: 600,
+						RatioX:      4,
+						RatioY:      3,
+					},
+				},
+				ConvertedVideo: &response.ConvertedVideo{
+					ServiceID: "temp_converted_file.mkv",
+					Size:      3595197,
+					Name:      "temp_converted_file.mkv",
+					UserID:    1,
+					Video: response.Video{
+						Bitrate:     64000,
+						ResolutionX: 800,
+						ResolutionY: 600,
+						RatioX:      4,
+						RatioY:      3,
+					},
+				},
+			},
+		},
+		{
+			name: "Invalid verifying converted video",
+			srv: &service.Service{
+				VideoStorage: &successCloud{},
+				Compressor:   &brokenOutputService{},
+			},
+			req: &compressor.Request{
+				UserID:         1,
+				RequestID:      1,
+				VideoID:        1,
+				VideoServiceID: "mock_service",
+				Verify:         true,
+			},
+			expectedResponse: &response.Response{
+				RequestID:     1,
+				Decision:      compressor.DecisionConvert,
+				OriginalVideo: testOriginalVideo,
+				Error:         "Converted video failed verification",
+			},
+		},
+		{
+			name: "Invalid verifying converted video with thumbnails and preview",
+			srv: &service.Service{
+				VideoStorage: &noDirUploadCloud{},
+				Compressor:   &brokenOutputService{},
+			},
+			req: &compressor.Request{
+				UserID:         1,
+				RequestID:      1,
+				VideoID:        1,
+				VideoServiceID: "mock_service",
+				Verify:         true,
+				Thumbnails:     &compressor.ThumbnailOptions{Poster: true},
+				Preview:        &compressor.PreviewOptions{},
+			},
+			expectedResponse: &response.Response{
+				RequestID:     1,
+				Decision:      compressor.DecisionConvert,
+				OriginalVideo: testOriginalVideo,
+				Error:         "Converted video failed verification",
+			},
+		},
+		{
+			name: "Invalid selecting bitrate",
+			srv: &service.Service{
+				VideoStorage: &successCloud{},
+				Compressor:   &admittedErrorService{},
+			},
+			req: &compressor.Request{
+				UserID:         1,
+				RequestID:      1,
+				VideoID:        1,
+				VideoServiceID: "mock_service",
+				AutoBitrate:    &compressor.AutoBitrateOptions{},
+			},
+			expectedResponse: &response.Response{
+				RequestID:     1,
+				OriginalVideo: testOriginalVideo,
+				Error:         "Error occurred when selecting bitrate",
+			},
+		},
+		{
+			name: "Valid converting video with auto bitrate",
+			srv: &service.Service{
+				VideoStorage: &successCloud{},
+				Compressor:   &successCompressService{},
+			},
+			req: &compressor.Request{
+				UserID:         1,
+				RequestID:      1,
+				VideoID:        1,
+				VideoServiceID: "mock_service",
+				AutoBitrate:    &compressor.AutoBitrateOptions{},
+			},
+			expectedResponse: &response.Response{
+				RequestID:     1,
+				Decision:      compressor.DecisionConvert,
+				OriginalVideo: testOriginalVideo,
+				ConvertedVideo: &response.ConvertedVideo{
+					ServiceID: "temp_converted_file.mkv",
+					Size:      3595197,
+					Name:      "temp_converted_file.mkv",
+					UserID:    1,
+					Video: response.Video{
+						Bitrate:     64000,
+						ResolutionX: 800,
+						ResolutionY: 600,
+						RatioX:      4,
+						RatioY:      3,
+					},
+				},
+				AutoBitrate: &response.AutoBitrate{Quality: 23, Samples: 5, Bitrate: 1200000},
+			},
+		},
+		{
+			name: "Invalid measuring loudness",
+			srv: &service.Service{
+				VideoStorage: &successCloud{},
+				Compressor:   &admittedErrorService{},
+			},
+			req: &compressor.Request{
+				UserID:         1,
+				RequestID:      1,
+				VideoID:        1,
+				VideoServiceID: "mock_service",
+				Loudness:       &compressor.LoudnessOptions{},
+			},
+			expectedResponse: &response.Response{
+				RequestID:     1,
+				OriginalVideo: testOriginalVideo,
+				Error:         "Error occurred when measuring loudness",
+			},
+		},
+		{
+			name: "Valid converting video with loudness normalization",
+			srv: &service.Service{
+				VideoStorage: &successCloud{},
+				Compressor:   &successCompressService{},
+			},
+			req: &compressor.Request{
+				UserID:         1,
+				RequestID:      1,
+				VideoID:        1,
+				VideoServiceID: "mock_service",
+				Loudness:       &compressor.LoudnessOptions{Integrated: -23},
+			},
+			expectedResponse: &response.Response{
+				RequestID: 1,
+				Decision:  compressor.DecisionConvert,
 				OriginalVideo: &response.OriginalVideo{
 					ID: 1,
 					Video: response.Video{
@@ -229,6 +570,290 @@ func TestCompress(t *testing.T) {
 						RatioY:      3,
 					},
 				},
+				Loudness: &response.Loudness{
+					Before: response.LoudnessLevel{Integrated: -27.5, TruePeak: -4, LRA: 6, Threshold: -38},
+					After:  &response.LoudnessLevel{Integrated: -23, TruePeak: -2, LRA: 5, Threshold: -33},
+				},
+			},
+		},
+		{
+			name: "Not a video",
+			srv: &service.Service{
+				VideoStorage: &successCloud{},
+				Compressor:   &notVideoService{},
+			},
+			req: &compressor.Request{
+				UserID:         1,
+				RequestID:      1,
+				VideoID:        1,
+				VideoServiceID: "mock_service",
+			},
+			expectedResponse: &response.Response{
+				RequestID: 1,
+				Rejection: &response.Rejection{Reason: compressor.RejectNotVideo, Value: "no video stream"},
+				Error:     "Original video rejected: not video: no video stream",
+			},
+		},
+		{
+			name: "Probe failure",
+			srv: &service.Service{
+				VideoStorage: &successCloud{},
+				Compressor:   &errorCompressService{},
+			},
+			req: &compressor.Request{
+				UserID:         1,
+				RequestID:      1,
+				VideoID:        1,
+				VideoServiceID: "mock_service",
+			},
+			expectedResponse: &response.Response{
+				RequestID: 1,
+				Error:     "Error occurred when probing original video",
+			},
+		},
+		{
+			name: "Original video exceeds limits",
+			srv: &service.Service{
+				VideoStorage: &successCloud{},
+				Compressor:   &successCompressService{},
+				Admission:    compressor.Admission{MaxWidth: 640, MaxHeight: 480},
+			},
+			req: &compressor.Request{
+				UserID:         1,
+				RequestID:      1,
+				VideoID:        1,
+				VideoServiceID: "mock_service",
+			},
+			expectedResponse: &response.Response{
+				RequestID:     1,
+				OriginalVideo: testOriginalVideo,
+				Rejection:     &response.Rejection{Reason: compressor.RejectResolution, Value: "800x600", Limit: "640x480"},
+				Error:         "Original video rejected: resolution 800x600 exceeds limit 640x480",
+			},
+		},
+		{
+			name: "Reused original video",
+			srv: &service.Service{
+				VideoStorage: &successCloud{},
+				Compressor:   &decidedService{decision: compressor.DecisionReuse},
+			},
+			req: &compressor.Request{
+				UserID:         1,
+				RequestID:      1,
+				Bitrate:        64000,
+				VideoID:        1,
+				VideoServiceID: "mock_service",
+			},
+			expectedResponse: &response.Response{
+				RequestID:     1,
+				OriginalVideo: testOriginalVideo,
+				ConvertedVideo: &response.ConvertedVideo{
+					ServiceID: "temp_converted_file.mkv",
+					Size:      1441786,
+					Name:      "temp_converted_file.mkv",
+					UserID:    1,
+					Video:     testOriginalVideo.Video,
+				},
+				Decision: compressor.DecisionReuse,
+			},
+		},
+		{
+			name: "Remuxed original video",
+			srv: &service.Service{
+				VideoStorage: &successCloud{},
+				Compressor:   &decidedService{decision: compressor.DecisionRemux},
+			},
+			req: &compressor.Request{
+				UserID:         1,
+				RequestID:      1,
+				VideoID:        1,
+				VideoServiceID: "mock_service",
+			},
+			expectedResponse: &response.Response{
+				RequestID:     1,
+				OriginalVideo: testOriginalVideo,
+				ConvertedVideo: &response.ConvertedVideo{
+					ServiceID: "temp_converted_file.mkv",
+					Size:      3595197,
+					Name:      "temp_converted_file.mkv",
+					UserID:    1,
+					Video:     testOriginalVideo.Video,
+				},
+				Decision: compressor.DecisionRemux,
+			},
+		},
+		{
+			name: "Invalid request",
+			srv:  &service.Service{},
+			req: &compressor.Request{
+				UserID:         1,
+				RequestID:      1,
+				Output:         "avi",
+				VideoID:        1,
+				VideoServiceID: "mock_service",
+			},
+			expectedResponse: &response.Response{
+				RequestID: 1,
+				Error:     "Invalid request: unknown output \"avi\"",
+			},
+		},
+		{
+			name: "Unknown preset",
+			srv: &service.Service{
+				Presets: compressor.Presets{"mobile": []byte(`{"bitrate": 800000}`)},
+			},
+			req: &compressor.Request{
+				UserID:         1,
+				RequestID:      1,
+				Preset:         "archive",
+				VideoID:        1,
+				VideoServiceID: "mock_service",
+			},
+			expectedResponse: &response.Response{
+				RequestID: 1,
+				Error:     "Invalid request: unknown preset \"archive\"",
+			},
+		},
+		{
+			name: "Invalid packaging video",
+			srv: &service.Service{
+				VideoStorage: &successCloud{},
+				Compressor:   &admittedErrorService{},
+			},
+			req: &compressor.Request{
+				UserID:         1,
+				RequestID:      1,
+				Output:         compressor.OutputHLS,
+				VideoID:        1,
+				VideoServiceID: "mock_service",
+			},
+			expectedResponse: &response.Response{
+				RequestID:     1,
+				OriginalVideo: testOriginalVideo,
+				Error:         "Error occurred when packaging video",
+			},
+		},
+		{
+			name: "Invalid extracting thumbnails",
+			srv: &service.Service{
+				VideoStorage: &successCloud{},
+				Compressor:   &admittedErrorService{},
+			},
+			req: &compressor.Request{
+				UserID:         1,
+				RequestID:      1,
+				VideoID:        1,
+				VideoServiceID: "mock_service",
+				Thumbnails:     &compressor.ThumbnailOptions{Poster: true},
+			},
+			expectedResponse: &response.Response{
+				RequestID:     1,
+				OriginalVideo: testOriginalVideo,
+				Error:         "Error occurred when extracting thumbnails",
+			},
+		},
+		{
+			name: "Invalid making preview",
+			srv: &service.Service{
+				VideoStorage: &successCloud{},
+				Compressor:   &admittedErrorService{},
+			},
+			req: &compressor.Request{
+				UserID:         1,
+				RequestID:      1,
+				VideoID:        1,
+				VideoServiceID: "mock_service",
+				Preview:        &compressor.PreviewOptions{},
+			},
+			expectedResponse: &response.Response{
+				RequestID:     1,
+				OriginalVideo: testOriginalVideo,
+				Error:         "Error occurred when making preview",
+			},
+		},
+		{
+			name: "Invalid extracting subtitles",
+			srv: &service.Service{
+				VideoStorage: &successCloud{},
+				Compressor:   &admittedErrorService{},
+			},
+			req: &compressor.Request{
+				UserID:         1,
+				RequestID:      1,
+				VideoID:        1,
+				VideoServiceID: "mock_service",
+				Subtitles:      &compressor.SubtitleOptions{Extract: true},
+			},
+			expectedResponse: &response.Response{
+				RequestID:     1,
+				OriginalVideo: testOriginalVideo,
+				Error:         "Error occurred when extracting subtitles",
+			},
+		},
+		{
+			name: "Valid HLS packaging with thumbnails, preview and subtitles",
+			srv: &service.Service{
+				VideoStorage: &successCloud{},
+				Compressor:   &successCompressService{},
+			},
+			req: &compressor.Request{
+				UserID:         1,
+				RequestID:      1,
+				Output:         compressor.OutputHLS,
+				VideoID:        1,
+				VideoServiceID: "mock_service",
+				Thumbnails:     &compressor.ThumbnailOptions{Poster: true, Count: 1},
+				Preview:        &compressor.PreviewOptions{Formats: []string{compressor.PreviewGIF}},
+				Subtitles:      &compressor.SubtitleOptions{Extract: true},
+			},
+			expectedResponse: &response.Response{
+				RequestID: 1,
+				OriginalVideo: &response.OriginalVideo{
+					ID: 1,
+					Video: response.Video{
+						Bitrate:     64000,
+						ResolutionX: 800,
+						ResolutionY: 600,
+						RatioX:      4,
+						RatioY:      3,
+					},
+				},
+				ConvertedVideo: &response.ConvertedVideo{
+					ServiceID: "temp_converted_package/master.m3u8",
+					Size:      8,
+					Name:      "temp_converted_package/master.m3u8",
+					UserID:    1,
+					Video: response.Video{
+						Bitrate:     2800000,
+						ResolutionX: 1280,
+						ResolutionY: 720,
+						RatioX:      4,
+						RatioY:      3,
+					},
+				},
+				Package: &response.Package{
+					Format:   compressor.OutputHLS,
+					Manifest: "temp_converted_package/master.m3u8",
+					Variants: []response.Variant{
+						{
+							Playlist:    "temp_converted_package/720p.m3u8",
+							Bitrate:     2800000,
+							Bandwidth:   3124000,
+							ResolutionX: 1280,
+							ResolutionY: 720,
+						},
+					},
+				},
+				Thumbnails: &response.Thumbnails{
+					Poster:     "temp_converted_package/poster.jpg",
+					Thumbnails: []string{"temp_converted_package/thumbnail_000.jpg"},
+				},
+				Preview: &response.Preview{
+					GIF: "temp_converted_package/preview.gif",
+				},
+				Subtitles: []response.SubtitleFile{
+					{Track: 0, Language: "eng", Key: "temp_converted_package/subtitle_0.vtt"},
+				},
 			},
 		},
 	}
@@ -256,6 +881,55 @@ func TestCompress(t *testing.T) {
 						testCase.expectedResponse.Error, resp.Error)
 				}
 			}
+
+			if _, err := os.Stat(testCase.req.WorkDir); testCase.req.WorkDir != "" && !os.IsNotExist(err) {
+				t.Errorf("Working directory %s isn't removed\n", testCase.req.WorkDir)
+			}
+		})
+	}
+}
+
+func TestNotVideo(t *testing.T) {
+	cases := []struct {
+		name     string
+		err      error
+		expected bool
+	}{
+		{
+			name:     "No video stream",
+			err:      fmt.Errorf("probe: %w", compressor.ErrNoVideoStream),
+			expected: true,
+		},
+		{
+			name: "Invalid data",
+			err: &ffmpeg.Error{Category: ffmpeg.CategoryInput,
+				Stderr: "in.mp4: Invalid data found when processing input"},
+			expected: true,
+		},
+		{
+			name: "Missing file",
+			err: &ffmpeg.Error{Category: ffmpeg.CategoryInput,
+				Stderr: "in.mp4: No such file or directory"},
+			expected: false,
+		},
+		{
+			name: "Inaccessible file",
+			err: &ffmpeg.Error{Category: ffmpeg.CategoryOutput,
+				Stderr: "in.mp4: Permission denied"},
+			expected: false,
+		},
+		{
+			name:     "Other error",
+			err:      errors.New("failed mock file info"),
+			expected: false,
+		},
+	}
+
+	for _, testCase := range cases {
+		t.Run(testCase.name, func(t *testing.T) {
+			if got := notVideo(testCase.err); got != testCase.expected {
+				t.Errorf("Invalid not video, expected: %v, got: %v\n", testCase.expected, got)
+			}
 		})
 	}
 }
@@ -0,0 +1,62 @@
+package handler
+
+import (
+	"context"
+
+	"github.com/Hargeon/compressrv/pkg/response"
+	"github.com/Hargeon/compressrv/pkg/service/compressor"
+
+	"go.uber.org/zap"
+)
+
+// convert decides if original video is converted, remuxed or reused as it is, fills resp by the decision
+// and returns path of converted video, path is empty when conversion failed
+func (h *CompressorHandler) convert(ctx context.Context, req *compressor.Request,
+	videoName string, resp *response.Response) string {
+	decision, err := h.srv.Decide(req, videoName)
+	if err != nil {
+		// video which can't be compared with request is converted
+		h.logger.Warn("Decide conversion of original video",
+			zap.String("Error", err.Error()),
+			zap.Int64("VideoID", req.VideoID),
+			ffmpegCommand(err))
+
+		decision = compressor.DecisionConvert
+	}
+
+	switch decision {
+	case compressor.DecisionReuse:
+		resp.Decision = decision
+
+		return videoName
+	case compressor.DecisionRemux:
+		path, err := h.srv.Remux(ctx, req, videoName)
+		if err == nil {
+			resp.Decision = decision
+
+			return path
+		}
+
+		// streams which can't be copied are converted
+		h.logger.Warn("Remux original video",
+			zap.String("Error", err.Error()),
+			zap.Int64("VideoID", req.VideoID),
+			ffmpegCommand(err))
+	}
+
+	resp.Decision = compressor.DecisionConvert
+
+	path, err := h.srv.Convert(ctx, req, videoName)
+	if err != nil {
+		h.logger.Error("Convert original video",
+			zap.String("Error", err.Error()),
+			zap.Int64("VideoID", req.VideoID),
+			ffmpegCommand(err))
+
+		resp.Error = "Error occurred when converting video"
+
+		return ""
+	}
+
+	return path
+}
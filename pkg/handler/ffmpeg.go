@@ -0,0 +1,20 @@
+package handler
+
+import (
+	"errors"
+
+	"github.com/Hargeon/compressrv/pkg/ffmpeg"
+
+	"go.uber.org/zap"
+)
+
+// ffmpegCommand returns log field with the exact command line of failed ffmpeg or ffprobe command,
+// the field is skipped for other errors
+func ffmpegCommand(err error) zap.Field {
+	var ffmpegErr *ffmpeg.Error
+	if errors.As(err, &ffmpegErr) {
+		return zap.String("Command", ffmpegErr.Command)
+	}
+
+	return zap.Skip()
+}
@@ -0,0 +1,51 @@
+package handler
+
+import (
+	"context"
+
+	"github.com/Hargeon/compressrv/pkg/response"
+	"github.com/Hargeon/compressrv/pkg/service/compressor"
+
+	"go.uber.org/zap"
+)
+
+// measureOriginalLoudness measures loudness of original video for normalization and fills resp
+func (h *CompressorHandler) measureOriginalLoudness(ctx context.Context, req *compressor.Request,
+	videoName string, resp *response.Response) {
+	measured, err := h.srv.MeasureLoudness(ctx, req, videoName)
+	if err != nil {
+		h.logger.Error("Measure loudness of original video",
+			zap.String("Error", err.Error()),
+			zap.Int64("VideoID", req.VideoID),
+			ffmpegCommand(err))
+
+		resp.Error = "Error occurred when measuring loudness"
+
+		return
+	}
+
+	// video without audio isn't normalized
+	if measured == nil {
+		return
+	}
+
+	req.Loudness.Measured = measured
+	resp.Loudness = &response.Loudness{Before: *measured}
+}
+
+// measureConvertedLoudness measures loudness of normalized video and fills resp
+func (h *CompressorHandler) measureConvertedLoudness(ctx context.Context, req *compressor.Request,
+	convertedVideoPath string, resp *response.Response) {
+	// converted video is already trimmed, so it's measured from the beginning
+	measured, err := h.srv.MeasureLoudness(ctx, &compressor.Request{}, convertedVideoPath)
+	if err != nil {
+		h.logger.Error("measure loudness of converted video",
+			zap.String("Error", err.Error()),
+			zap.Int64("VideoID", req.VideoID),
+			ffmpegCommand(err))
+
+		return
+	}
+
+	resp.Loudness.After = measured
+}
@@ -0,0 +1,102 @@
+package handler
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+
+	"github.com/Hargeon/compressrv/pkg/response"
+	"github.com/Hargeon/compressrv/pkg/service/compressor"
+
+	"go.uber.org/zap"
+)
+
+// compressPackage encodes original video to adaptive streaming package, uploads it with artifacts and fills resp
+func (h *CompressorHandler) compressPackage(ctx context.Context, req *compressor.Request,
+	videoName string, artifacts []*artifact, resp *response.Response) {
+	dir, pkg, err := h.srv.Package(ctx, req, videoName)
+	if err != nil {
+		h.logger.Error("Package original video",
+			zap.String("Error", err.Error()),
+			zap.Int64("VideoID", req.VideoID),
+			ffmpegCommand(err))
+
+		resp.Error = "Error occurred when packaging video"
+
+		return
+	}
+
+	defer func() {
+		os.RemoveAll(dir)
+	}()
+
+	h.uploadArtifacts(ctx, req, artifacts, resp)
+	if resp.Error != "" {
+		return
+	}
+
+	prefix, err := h.srv.UploadDir(ctx, req.VideoServiceID, dir)
+	if err != nil {
+		h.logger.Error("upload package",
+			zap.String("Error", err.Error()),
+			zap.Int64("VideoID", req.VideoID))
+
+		resp.Error = "error occurred when uploading package"
+
+		return
+	}
+
+	pkg.Manifest = prefixKey(prefix, pkg.Manifest)
+	for i := range pkg.Variants {
+		pkg.Variants[i].Playlist = prefixKey(prefix, pkg.Variants[i].Playlist)
+	}
+
+	resp.Package = pkg
+
+	if req.AutoBitrate != nil {
+		resp.AutoBitrate = req.AutoBitrate.Selected
+	}
+
+	resp.ConvertedVideo = &response.ConvertedVideo{
+		ServiceID: pkg.Manifest,
+		Name:      pkg.Manifest,
+		UserID:    req.UserID,
+		Size:      dirSize(dir),
+	}
+
+	if len(pkg.Variants) != 0 {
+		top := pkg.Variants[0]
+		resp.ConvertedVideo.Bitrate = top.Bitrate
+		resp.ConvertedVideo.ResolutionX = top.ResolutionX
+		resp.ConvertedVideo.ResolutionY = top.ResolutionY
+	}
+
+	if resp.OriginalVideo != nil {
+		resp.ConvertedVideo.RatioX = resp.OriginalVideo.RatioX
+		resp.ConvertedVideo.RatioY = resp.OriginalVideo.RatioY
+	}
+}
+
+// prefixKey returns storage key of file uploaded by UploadDir, empty name stays empty
+func prefixKey(prefix, name string) string {
+	if name == "" {
+		return ""
+	}
+
+	return prefix + "/" + name
+}
+
+// dirSize returns size of all files in dir
+func dirSize(dir string) int64 {
+	var size int64
+
+	filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() {
+			size += info.Size()
+		}
+
+		return nil
+	})
+
+	return size
+}
@@ -0,0 +1,34 @@
+package handler
+
+import (
+	"context"
+
+	"github.com/Hargeon/compressrv/pkg/response"
+	"github.com/Hargeon/compressrv/pkg/service/compressor"
+
+	"go.uber.org/zap"
+)
+
+// preview makes animated previews of original video, resp is filled by them after upload
+func (h *CompressorHandler) preview(ctx context.Context, req *compressor.Request,
+	videoName string, resp *response.Response) *artifact {
+	dir, preview, err := h.srv.Preview(ctx, req, videoName)
+	if err != nil {
+		h.logger.Error("Preview original video",
+			zap.String("Error", err.Error()),
+			zap.Int64("VideoID", req.VideoID),
+			ffmpegCommand(err))
+
+		resp.Error = "Error occurred when making preview"
+
+		return nil
+	}
+
+	return &artifact{name: "preview", dir: dir, fill: func(prefix string) {
+		preview.MP4 = prefixKey(prefix, preview.MP4)
+		preview.GIF = prefixKey(prefix, preview.GIF)
+		preview.WebP = prefixKey(prefix, preview.WebP)
+
+		resp.Preview = preview
+	}}
+}
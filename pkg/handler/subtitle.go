@@ -0,0 +1,42 @@
+package handler
+
+import (
+	"context"
+	"os"
+
+	"github.com/Hargeon/compressrv/pkg/response"
+	"github.com/Hargeon/compressrv/pkg/service/compressor"
+
+	"go.uber.org/zap"
+)
+
+// subtitles extracts subtitle tracks of original video to WebVTT files, resp is filled by them after upload.
+// It returns nil when original video has no text subtitles
+func (h *CompressorHandler) subtitles(ctx context.Context, req *compressor.Request,
+	videoName string, resp *response.Response) *artifact {
+	dir, files, err := h.srv.Subtitles(ctx, req, videoName)
+	if err != nil {
+		h.logger.Error("Subtitles original video",
+			zap.String("Error", err.Error()),
+			zap.Int64("VideoID", req.VideoID),
+			ffmpegCommand(err))
+
+		resp.Error = "Error occurred when extracting subtitles"
+
+		return nil
+	}
+
+	if len(files) == 0 {
+		os.RemoveAll(dir)
+
+		return nil
+	}
+
+	return &artifact{name: "subtitles", dir: dir, fill: func(prefix string) {
+		for i := range files {
+			files[i].Key = prefixKey(prefix, files[i].Key)
+		}
+
+		resp.Subtitles = files
+	}}
+}
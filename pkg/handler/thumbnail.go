@@ -0,0 +1,38 @@
+package handler
+
+import (
+	"context"
+
+	"github.com/Hargeon/compressrv/pkg/response"
+	"github.com/Hargeon/compressrv/pkg/service/compressor"
+
+	"go.uber.org/zap"
+)
+
+// thumbnails extracts preview images from original video, resp is filled by them after upload
+func (h *CompressorHandler) thumbnails(ctx context.Context, req *compressor.Request,
+	videoName string, resp *response.Response) *artifact {
+	dir, thumbs, err := h.srv.Thumbnails(ctx, req, videoName)
+	if err != nil {
+		h.logger.Error("Thumbnails original video",
+			zap.String("Error", err.Error()),
+			zap.Int64("VideoID", req.VideoID),
+			ffmpegCommand(err))
+
+		resp.Error = "Error occurred when extracting thumbnails"
+
+		return nil
+	}
+
+	return &artifact{name: "thumbnails", dir: dir, fill: func(prefix string) {
+		thumbs.Poster = prefixKey(prefix, thumbs.Poster)
+		thumbs.Sprite = prefixKey(prefix, thumbs.Sprite)
+		thumbs.SpriteVTT = prefixKey(prefix, thumbs.SpriteVTT)
+
+		for i := range thumbs.Thumbnails {
+			thumbs.Thumbnails[i] = prefixKey(prefix, thumbs.Thumbnails[i])
+		}
+
+		resp.Thumbnails = thumbs
+	}}
+}
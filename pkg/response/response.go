@@ -3,11 +3,76 @@ package response
 
 // Video consists meta data for video
 type Video struct {
-	Bitrate     int64 `json:"bitrate"`
-	ResolutionX int   `json:"resolution_x"`
-	ResolutionY int   `json:"resolution_y"`
-	RatioX      int   `json:"ratio_x"`
-	RatioY      int   `json:"ratio_y"`
+	Bitrate     int64   `json:"bitrate"`
+	ResolutionX int     `json:"resolution_x"`
+	ResolutionY int     `json:"resolution_y"`
+	RatioX      int     `json:"ratio_x"`
+	RatioY      int     `json:"ratio_y"`
+	Duration    float64 `json:"duration"`
+	FPS         float64 `json:"fps"`
+	Codec       string  `json:"codec,omitempty"`
+	Profile     string  `json:"profile,omitempty"`
+	PixelFormat string  `json:"pixel_format,omitempty"`
+	// Rotation in degrees clockwise from stream metadata, resolution and ratio are reported as displayed
+	Rotation  int    `json:"rotation,omitempty"`
+	Container string `json:"container,omitempty"`
+	// VideoBitrate is a bitrate of video stream, Bitrate is an overall bitrate of file
+	VideoBitrate int64 `json:"video_bitrate,omitempty"`
+	// Streams is a number of all streams of file including data and attached pictures
+	Streams   int        `json:"streams,omitempty"`
+	Audio     []Audio    `json:"audio,omitempty"`
+	Subtitles []Subtitle `json:"subtitles,omitempty"`
+}
+
+// Audio consists meta data for audio stream
+type Audio struct {
+	Index      int    `json:"index"`
+	Codec      string `json:"codec"`
+	Channels   int    `json:"channels"`
+	SampleRate int    `json:"sample_rate"`
+	Bitrate    int64  `json:"bitrate"`
+	Language   string `json:"language,omitempty"`
+}
+
+// Subtitle consists meta data for subtitle stream
+type Subtitle struct {
+	Index    int    `json:"index"`
+	Codec    string `json:"codec"`
+	Language string `json:"language,omitempty"`
+	Title    string `json:"title,omitempty"`
+	Default  bool   `json:"default,omitempty"`
+	Forced   bool   `json:"forced,omitempty"`
+}
+
+// SubtitleFile consists fields for subtitle track extracted to WebVTT file
+type SubtitleFile struct {
+	Track    int    `json:"track"`
+	Language string `json:"language,omitempty"`
+	Key      string `json:"key"`
+}
+
+// LoudnessLevel consists EBU R128 loudness values
+type LoudnessLevel struct {
+	Integrated float64 `json:"integrated"`
+	TruePeak   float64 `json:"true_peak"`
+	LRA        float64 `json:"lra"`
+	Threshold  float64 `json:"threshold"`
+}
+
+// Loudness consists loudness of original and converted video
+type Loudness struct {
+	Before LoudnessLevel  `json:"before"`
+	After  *LoudnessLevel `json:"after,omitempty"`
+}
+
+// AutoBitrate consists bitrate selected by constant quality test encodes of sampled segments
+type AutoBitrate struct {
+	Quality int `json:"quality"`
+	Samples int `json:"samples"`
+	// Bitrate of video stream of converted video or the highest variant of adaptive streaming package
+	Bitrate int64 `json:"bitrate"`
+	// Scale of ladder bitrates of adaptive streaming package
+	Scale float64 `json:"scale,omitempty"`
 }
 
 // OriginalVideo consists fields for original video
@@ -25,10 +90,60 @@ type ConvertedVideo struct {
 	Video
 }
 
+// Variant consists fields for one rendition of adaptive streaming package
+type Variant struct {
+	ID          string `json:"id,omitempty"`
+	Playlist    string `json:"playlist,omitempty"`
+	Bitrate     int64  `json:"bitrate"`
+	Bandwidth   int64  `json:"bandwidth"`
+	ResolutionX int    `json:"resolution_x"`
+	ResolutionY int    `json:"resolution_y"`
+	// Codecs of video and audio of variant in RFC 6381 format (e.g. avc1.64001F,mp4a.40.2)
+	Codecs string `json:"codecs,omitempty"`
+}
+
+// Package consists fields for adaptive streaming package
+type Package struct {
+	Format   string    `json:"format"`
+	Manifest string    `json:"manifest"`
+	Variants []Variant `json:"variants"`
+}
+
+// Thumbnails consists keys of preview images
+type Thumbnails struct {
+	Poster     string   `json:"poster,omitempty"`
+	Thumbnails []string `json:"thumbnails,omitempty"`
+	Sprite     string   `json:"sprite,omitempty"`
+	SpriteVTT  string   `json:"sprite_vtt,omitempty"`
+}
+
+// Preview consists keys of animated previews
+type Preview struct {
+	MP4  string `json:"mp4,omitempty"`
+	GIF  string `json:"gif,omitempty"`
+	WebP string `json:"webp,omitempty"`
+}
+
+// Rejection describes why original video wasn't converted, value and limit are set for exceeded limits
+type Rejection struct {
+	Reason string `json:"reason"`
+	Value  string `json:"value,omitempty"`
+	Limit  string `json:"limit,omitempty"`
+}
+
 // Response represent full response after compressing
 type Response struct {
 	RequestID      int64           `json:"request_id"`
 	OriginalVideo  *OriginalVideo  `json:"original_video,omitempty"`
 	ConvertedVideo *ConvertedVideo `json:"converted_video,omitempty"`
-	Error          string          `json:"error,omitempty"`
+	Package        *Package        `json:"package,omitempty"`
+	Thumbnails     *Thumbnails     `json:"thumbnails,omitempty"`
+	Preview        *Preview        `json:"preview,omitempty"`
+	Subtitles      []SubtitleFile  `json:"subtitles,omitempty"`
+	Loudness       *Loudness       `json:"loudness,omitempty"`
+	AutoBitrate    *AutoBitrate    `json:"auto_bitrate,omitempty"`
+	// Decision tells if original video was converted, remuxed or reused as it is
+	Decision  string     `json:"decision,omitempty"`
+	Rejection *Rejection `json:"rejection,omitempty"`
+	Error     string     `json:"error,omitempty"`
 }
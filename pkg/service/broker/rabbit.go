@@ -68,6 +68,11 @@ func (r *Rabbit) Publish(body []byte) error {
 		})
 }
 
+// Prefetch limits number of unacknowledged messages delivered to consumer
+func (r *Rabbit) Prefetch(count int) error {
+	return r.ch.Qos(count, 0, false)
+}
+
 func (r *Rabbit) Consume() (<-chan amqp.Delivery, error) {
 	msgs, err := r.ch.Consume(
 		r.q.Name,
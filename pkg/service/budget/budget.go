@@ -0,0 +1,64 @@
+// Package budget limits CPU slots used by concurrent jobs
+package budget
+
+import (
+	"context"
+	"errors"
+)
+
+// Budget is a pool of CPU slots, a job is admitted when there are enough free slots for it
+type Budget struct {
+	slots chan struct{}
+	// admit serializes acquisitions, so jobs holding a part of slots don't block each other
+	admit chan struct{}
+}
+
+// New initialize Budget of slots
+func New(slots int) (*Budget, error) {
+	if slots <= 0 {
+		return nil, errors.New("budget must have at least one slot")
+	}
+
+	return &Budget{
+		slots: make(chan struct{}, slots),
+		admit: make(chan struct{}, 1),
+	}, nil
+}
+
+// Slots returns number of slots of Budget
+func (b *Budget) Slots() int {
+	return cap(b.slots)
+}
+
+// Acquire waits for n free slots and takes them, n is limited by number of slots of Budget.
+// Returned func releases taken slots, it must be called once
+func (b *Budget) Acquire(ctx context.Context, n int) (func(), error) {
+	if n > b.Slots() {
+		n = b.Slots()
+	}
+
+	select {
+	case b.admit <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	defer func() { <-b.admit }()
+
+	for taken := 0; taken < n; taken++ {
+		select {
+		case b.slots <- struct{}{}:
+		case <-ctx.Done():
+			b.release(taken)
+
+			return nil, ctx.Err()
+		}
+	}
+
+	return func() { b.release(n) }, nil
+}
+
+func (b *Budget) release(n int) {
+	for i := 0; i < n; i++ {
+		<-b.slots
+	}
+}
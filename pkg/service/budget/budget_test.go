@@ -0,0 +1,124 @@
+package budget
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestNew(t *testing.T) {
+	cases := []struct {
+		name         string
+		slots        int
+		errorPresent bool
+	}{
+		{name: "With slots", slots: 4},
+		{name: "Without slots", slots: 0, errorPresent: true},
+		{name: "Negative slots", slots: -1, errorPresent: true},
+	}
+
+	for _, testCase := range cases {
+		t.Run(testCase.name, func(t *testing.T) {
+			b, err := New(testCase.slots)
+			if err != nil && !testCase.errorPresent {
+				t.Fatalf("Unexpected error: %s\n", err)
+			}
+
+			if err == nil && testCase.errorPresent {
+				t.Fatalf("Should be error\n")
+			}
+
+			if err == nil && b.Slots() != testCase.slots {
+				t.Errorf("Invalid slots, expected: %d, got: %d\n", testCase.slots, b.Slots())
+			}
+		})
+	}
+}
+
+func TestAcquire(t *testing.T) {
+	cases := []struct {
+		name     string
+		slots    int
+		held     int
+		n        int
+		admitted bool
+	}{
+		{name: "Free budget", slots: 4, n: 2, admitted: true},
+		{name: "Enough free slots", slots: 4, held: 2, n: 2, admitted: true},
+		{name: "Not enough free slots", slots: 4, held: 3, n: 2, admitted: false},
+		{name: "Job larger than budget", slots: 4, n: 8, admitted: true},
+		{name: "Job larger than busy budget", slots: 4, held: 1, n: 8, admitted: false},
+	}
+
+	for _, testCase := range cases {
+		t.Run(testCase.name, func(t *testing.T) {
+			b, err := New(testCase.slots)
+			if err != nil {
+				t.Fatalf("Unexpected error: %s\n", err)
+			}
+
+			if testCase.held > 0 {
+				if _, err = b.Acquire(context.Background(), testCase.held); err != nil {
+					t.Fatalf("Unexpected error: %s\n", err)
+				}
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+			defer cancel()
+
+			release, err := b.Acquire(ctx, testCase.n)
+			if admitted := err == nil; admitted != testCase.admitted {
+				t.Fatalf("Invalid admission, expected: %v, got: %v\n", testCase.admitted, admitted)
+			}
+
+			if err != nil {
+				// slots taken by canceled acquisition are returned
+				if len(b.slots) != testCase.held {
+					t.Errorf("Invalid used slots, expected: %d, got: %d\n", testCase.held, len(b.slots))
+				}
+
+				return
+			}
+
+			release()
+
+			if len(b.slots) != testCase.held {
+				t.Errorf("Invalid used slots, expected: %d, got: %d\n", testCase.held, len(b.slots))
+			}
+		})
+	}
+}
+
+func TestAcquireWaitsRelease(t *testing.T) {
+	b, err := New(2)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s\n", err)
+	}
+
+	release, err := b.Acquire(context.Background(), 2)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s\n", err)
+	}
+
+	admitted := make(chan struct{})
+
+	go func() {
+		if _, err := b.Acquire(context.Background(), 1); err == nil {
+			close(admitted)
+		}
+	}()
+
+	select {
+	case <-admitted:
+		t.Fatalf("Job shouldn't be admitted while budget is busy\n")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	release()
+
+	select {
+	case <-admitted:
+	case <-time.After(time.Second):
+		t.Errorf("Job should be admitted after release\n")
+	}
+}
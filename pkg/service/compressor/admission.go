@@ -0,0 +1,127 @@
+package compressor
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/Hargeon/compressrv/pkg/response"
+)
+
+// Reasons of rejection of original video
+const (
+	RejectNotVideo         = "not_video"
+	RejectUnsupportedCodec = "unsupported_codec"
+	RejectDuration         = "duration"
+	RejectResolution       = "resolution"
+	RejectFileSize         = "file_size"
+	RejectStreams          = "streams"
+)
+
+// Admission limits original videos which are converted, zero limit isn't checked
+type Admission struct {
+	// MaxDuration in seconds
+	MaxDuration float64
+	// MaxWidth and MaxHeight limit frame size in any orientation, 1920x1080 admits 1080x1920 too.
+	// They are set together
+	MaxWidth  int
+	MaxHeight int
+	// MaxFileSize in bytes
+	MaxFileSize int64
+	// MaxStreams limits number of all streams including data streams and attached pictures
+	MaxStreams int
+	// Codecs are supported video codecs, any codec is supported when it's empty
+	Codecs []string
+}
+
+// AdmissionError describes why original video is rejected
+type AdmissionError struct {
+	Reason string
+	Value  string
+	Limit  string
+}
+
+func (e *AdmissionError) Error() string {
+	if e.Limit == "" {
+		return fmt.Sprintf("%s: %s", strings.ReplaceAll(e.Reason, "_", " "), e.Value)
+	}
+
+	return fmt.Sprintf("%s %s exceeds limit %s", strings.ReplaceAll(e.Reason, "_", " "), e.Value, e.Limit)
+}
+
+// Response returns rejection of response
+func (e *AdmissionError) Response() *response.Rejection {
+	return &response.Rejection{Reason: e.Reason, Value: e.Value, Limit: e.Limit}
+}
+
+// Validate checks that limits aren't negative and frame size is limited by both width and height
+func (a *Admission) Validate() error {
+	if a.MaxDuration < 0 || a.MaxWidth < 0 || a.MaxHeight < 0 || a.MaxFileSize < 0 || a.MaxStreams < 0 {
+		return errors.New("admission limits can't be negative")
+	}
+
+	if (a.MaxWidth == 0) != (a.MaxHeight == 0) {
+		return errors.New("admission max width and max height should be set together")
+	}
+
+	return nil
+}
+
+// Admit checks video of size bytes, it returns *AdmissionError when video exceeds limits
+func (a *Admission) Admit(video *response.Video, size int64) error {
+	if len(a.Codecs) > 0 && !contains(a.Codecs, video.Codec) {
+		codec := video.Codec
+		if codec == "" {
+			codec = "unknown"
+		}
+
+		return &AdmissionError{Reason: RejectUnsupportedCodec, Value: codec}
+	}
+
+	if a.MaxDuration > 0 && video.Duration > a.MaxDuration {
+		return &AdmissionError{Reason: RejectDuration,
+			Value: formatSeconds(video.Duration), Limit: formatSeconds(a.MaxDuration)}
+	}
+
+	if a.MaxWidth > 0 && a.MaxHeight > 0 && !a.fits(video.ResolutionX, video.ResolutionY) {
+		return &AdmissionError{Reason: RejectResolution,
+			Value: fmt.Sprintf("%dx%d", video.ResolutionX, video.ResolutionY),
+			Limit: fmt.Sprintf("%dx%d", a.MaxWidth, a.MaxHeight)}
+	}
+
+	if a.MaxFileSize > 0 && size > a.MaxFileSize {
+		return &AdmissionError{Reason: RejectFileSize,
+			Value: fmt.Sprintf("%d", size), Limit: fmt.Sprintf("%d", a.MaxFileSize)}
+	}
+
+	if streams := streamCount(video); a.MaxStreams > 0 && streams > a.MaxStreams {
+		return &AdmissionError{Reason: RejectStreams,
+			Value: fmt.Sprintf("%d", streams), Limit: fmt.Sprintf("%d", a.MaxStreams)}
+	}
+
+	return nil
+}
+
+// fits checks frame size in both orientations
+func (a *Admission) fits(w, h int) bool {
+	return (w <= a.MaxWidth && h <= a.MaxHeight) || (w <= a.MaxHeight && h <= a.MaxWidth)
+}
+
+// streamCount returns number of all streams of video, it's counted from known streams when it isn't probed
+func streamCount(video *response.Video) int {
+	if video.Streams != 0 {
+		return video.Streams
+	}
+
+	return 1 + len(video.Audio) + len(video.Subtitles)
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,173 @@
+package compressor
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/Hargeon/compressrv/pkg/response"
+)
+
+func TestAdmit(t *testing.T) {
+	video := &response.Video{
+		ResolutionX: 1080,
+		ResolutionY: 1920,
+		Duration:    90,
+		Codec:       "h264",
+		Audio:       []response.Audio{{Index: 1}, {Index: 2}},
+		Subtitles:   []response.Subtitle{{Index: 3}},
+	}
+
+	cases := []struct {
+		name      string
+		admission Admission
+		video     *response.Video
+		size      int64
+		expected  *AdmissionError
+	}{
+		{
+			name:      "Without limits",
+			admission: Admission{},
+			video:     video,
+			size:      1 << 30,
+		},
+		{
+			name: "Within limits",
+			admission: Admission{MaxDuration: 90, MaxWidth: 1920, MaxHeight: 1080, MaxFileSize: 1000,
+				MaxStreams: 4, Codecs: []string{"h264", "hevc"}},
+			video: video,
+			size:  1000,
+		},
+		{
+			name:      "Unsupported codec",
+			admission: Admission{Codecs: []string{"hevc"}},
+			video:     video,
+			expected:  &AdmissionError{Reason: RejectUnsupportedCodec, Value: "h264"},
+		},
+		{
+			name:      "Unknown codec",
+			admission: Admission{Codecs: []string{"hevc"}},
+			video:     &response.Video{ResolutionX: 640, ResolutionY: 360},
+			expected:  &AdmissionError{Reason: RejectUnsupportedCodec, Value: "unknown"},
+		},
+		{
+			name:      "Long video",
+			admission: Admission{MaxDuration: 60},
+			video:     video,
+			expected:  &AdmissionError{Reason: RejectDuration, Value: "90.000000", Limit: "60.000000"},
+		},
+		{
+			name:      "Large frame",
+			admission: Admission{MaxWidth: 1280, MaxHeight: 720},
+			video:     video,
+			expected:  &AdmissionError{Reason: RejectResolution, Value: "1080x1920", Limit: "1280x720"},
+		},
+		{
+			name:      "Large file",
+			admission: Admission{MaxFileSize: 1000},
+			video:     video,
+			size:      1001,
+			expected:  &AdmissionError{Reason: RejectFileSize, Value: "1001", Limit: "1000"},
+		},
+		{
+			name:      "Many streams",
+			admission: Admission{MaxStreams: 3},
+			video:     video,
+			expected:  &AdmissionError{Reason: RejectStreams, Value: "4", Limit: "3"},
+		},
+		{
+			name:      "Many probed streams",
+			admission: Admission{MaxStreams: 4},
+			video:     &response.Video{ResolutionX: 640, ResolutionY: 360, Streams: 6},
+			expected:  &AdmissionError{Reason: RejectStreams, Value: "6", Limit: "4"},
+		},
+	}
+
+	for _, testCase := range cases {
+		t.Run(testCase.name, func(t *testing.T) {
+			err := testCase.admission.Admit(testCase.video, testCase.size)
+			if testCase.expected == nil {
+				if err != nil {
+					t.Fatalf("Unexpected error: %s\n", err)
+				}
+
+				return
+			}
+
+			if !reflect.DeepEqual(err, testCase.expected) {
+				t.Errorf("Invalid rejection, expected: %v, got: %v\n", testCase.expected, err)
+			}
+		})
+	}
+}
+
+func TestAdmissionValidate(t *testing.T) {
+	cases := []struct {
+		name         string
+		admission    Admission
+		errorPresent bool
+	}{
+		{
+			name:      "Without limits",
+			admission: Admission{},
+		},
+		{
+			name:      "Frame size",
+			admission: Admission{MaxWidth: 1920, MaxHeight: 1080},
+		},
+		{
+			name:         "Negative duration",
+			admission:    Admission{MaxDuration: -1},
+			errorPresent: true,
+		},
+		{
+			name:         "Only max width",
+			admission:    Admission{MaxWidth: 1920},
+			errorPresent: true,
+		},
+		{
+			name:         "Only max height",
+			admission:    Admission{MaxHeight: 1080},
+			errorPresent: true,
+		},
+	}
+
+	for _, testCase := range cases {
+		t.Run(testCase.name, func(t *testing.T) {
+			err := testCase.admission.Validate()
+			if err != nil && !testCase.errorPresent {
+				t.Errorf("Unexpected error: %s\n", err)
+			}
+
+			if err == nil && testCase.errorPresent {
+				t.Errorf("Should be error\n")
+			}
+		})
+	}
+}
+
+func TestAdmissionError(t *testing.T) {
+	cases := []struct {
+		name     string
+		err      *AdmissionError
+		expected string
+	}{
+		{
+			name:     "Exceeded limit",
+			err:      &AdmissionError{Reason: RejectFileSize, Value: "1001", Limit: "1000"},
+			expected: "file size 1001 exceeds limit 1000",
+		},
+		{
+			name:     "Without limit",
+			err:      &AdmissionError{Reason: RejectUnsupportedCodec, Value: "h264"},
+			expected: "unsupported codec: h264",
+		},
+	}
+
+	for _, testCase := range cases {
+		t.Run(testCase.name, func(t *testing.T) {
+			if got := testCase.err.Error(); got != testCase.expected {
+				t.Errorf("Invalid error, expected: %s, got: %s\n", testCase.expected, got)
+			}
+		})
+	}
+}
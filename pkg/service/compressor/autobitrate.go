@@ -0,0 +1,254 @@
+package compressor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/Hargeon/compressrv/pkg/ffmpeg"
+	"github.com/Hargeon/compressrv/pkg/response"
+)
+
+const (
+	// defaultAutoQuality is a CRF of test encodes, it's visually transparent for most of videos
+	defaultAutoQuality    = 23
+	defaultAutoSamples    = 5
+	defaultSampleDuration = 4
+	defaultAutoMinBitrate = 100000
+	maxAutoQuality        = 51
+	// sampleEncoderPreset makes test encodes fast, final encode with slower preset needs a bit less bitrate
+	sampleEncoderPreset = "veryfast"
+)
+
+// AutoBitrateOptions describes content-aware bitrate selection. Segments sampled across video are encoded
+// with constant quality and their bitrate is used for the whole video, ladder of adaptive output is scaled by it
+type AutoBitrateOptions struct {
+	// Quality is a CRF of test encodes from 0 to 51, defaultAutoQuality is used when zero
+	Quality int `json:"quality"`
+	// Samples is a number of sampled segments
+	Samples int `json:"samples"`
+	// SampleDuration of each segment in seconds
+	SampleDuration float64 `json:"sample_duration"`
+	// MinBitrate and MaxBitrate clamp selected bitrate, MaxBitrate isn't checked when zero
+	MinBitrate int64 `json:"min_bitrate"`
+	MaxBitrate int64 `json:"max_bitrate"`
+	// Selected is a bitrate selected before conversion, it's selected by Convert or Package when nil
+	Selected *response.AutoBitrate `json:"-"`
+}
+
+// validate checks options of bitrate selection
+func (a *AutoBitrateOptions) validate() error {
+	if a.Quality < 0 || a.Quality > maxAutoQuality {
+		return fmt.Errorf("auto bitrate quality should be from 0 to %d", maxAutoQuality)
+	}
+
+	if a.Samples < 0 || a.SampleDuration < 0 || a.MinBitrate < 0 || a.MaxBitrate < 0 {
+		return errors.New("auto bitrate options can't be negative")
+	}
+
+	if a.MaxBitrate != 0 && a.MaxBitrate < a.MinBitrate {
+		return errors.New("auto bitrate max bitrate should be greater than min bitrate")
+	}
+
+	return nil
+}
+
+// sample is a segment of video used for test encode
+type sample struct {
+	start    float64
+	duration float64
+}
+
+// SelectBitrate encodes segments of originalVideo with constant quality and selects bitrate of converted video
+// or scale of ladder of adaptive output by their bitrate
+func (c *Compressor) SelectBitrate(ctx context.Context, opt *Request, originalVideo string) (*response.AutoBitrate, error) {
+	if opt.AutoBitrate == nil {
+		return nil, errors.New("auto bitrate isn't requested")
+	}
+
+	src, err := c.VideoInfo(originalVideo)
+	if err != nil {
+		return nil, err
+	}
+
+	w, h, err := sampleFrame(opt, src)
+	if err != nil {
+		return nil, err
+	}
+
+	auto := opt.AutoBitrate
+	samples := samplePoints(opt.Start, outputDuration(opt, src), auto.Samples, auto.SampleDuration)
+	quality := auto.Quality
+
+	if quality == 0 {
+		quality = defaultAutoQuality
+	}
+
+	bitrate, err := c.encodeSamples(ctx, packageDir(opt, originalVideo, "samples"), originalVideo, samples, w, h, quality)
+	if err != nil {
+		return nil, err
+	}
+
+	selected := &response.AutoBitrate{
+		Quality: quality,
+		Samples: len(samples),
+		Bitrate: clampBitrate(bitrate, auto.MinBitrate, auto.MaxBitrate),
+	}
+
+	if opt.Adaptive() {
+		top := buildRenditions(ladder(opt), src.ResolutionX, src.ResolutionY)[0]
+		selected.Scale = float64(selected.Bitrate) / float64(top.Bitrate)
+	}
+
+	return selected, nil
+}
+
+// selectedBitrate returns bitrate selected before conversion or selects it
+func (c *Compressor) selectedBitrate(ctx context.Context, opt *Request, originalVideo string) (*response.AutoBitrate, error) {
+	if opt.AutoBitrate.Selected != nil {
+		return opt.AutoBitrate.Selected, nil
+	}
+
+	return c.SelectBitrate(ctx, opt, originalVideo)
+}
+
+// encodeSamples encodes samples of originalVideo to width x height frame with quality in dir
+// and returns average bitrate of encoded video
+func (c *Compressor) encodeSamples(ctx context.Context, dir, originalVideo string, samples []sample,
+	width, height, quality int) (int64, error) {
+	if err := os.MkdirAll(dir, dirPerm); err != nil {
+		return 0, err
+	}
+
+	defer os.RemoveAll(dir)
+
+	var size, duration float64
+
+	for i, s := range samples {
+		output := filepath.Join(dir, fmt.Sprintf("sample_%03d.mkv", i))
+
+		_, err := c.ffmpegCnf.Ffmpeg().
+			Input(originalVideo, "-ss", formatNumber(s.start)).
+			Args("-t", formatNumber(s.duration), "-map", "0:V:0", "-an", "-sn",
+				"-vf", fmt.Sprintf("scale=%d:%d", width, height),
+				"-c:v", "libx264", "-preset", sampleEncoderPreset,
+				"-crf", strconv.Itoa(quality), "-pix_fmt", "yuv420p").
+			Output(output).
+			Run(ctx)
+		if err != nil {
+			return 0, err
+		}
+
+		stat, err := os.Stat(output)
+		if err != nil {
+			return 0, err
+		}
+
+		// the last segment may be shorter than requested
+		d, err := c.videoDuration(output)
+		if err != nil {
+			return 0, err
+		}
+
+		size += float64(stat.Size())
+		duration += d
+	}
+
+	if duration == 0 {
+		return 0, errors.New("sampled segments are empty")
+	}
+
+	return int64(size * 8 / duration), nil
+}
+
+// samplePoints spreads count segments of sampleDuration evenly across clip of duration seconds from start,
+// short clip is sampled by one segment
+func samplePoints(start, duration float64, count int, sampleDuration float64) []sample {
+	if count == 0 {
+		count = defaultAutoSamples
+	}
+
+	if sampleDuration == 0 {
+		sampleDuration = defaultSampleDuration
+	}
+
+	if duration <= float64(count)*sampleDuration {
+		return []sample{{start: start, duration: duration}}
+	}
+
+	samples := make([]sample, count)
+	step := duration / float64(count)
+
+	for i := range samples {
+		// segments are centered in equal parts of clip
+		samples[i] = sample{start: start + step*float64(i) + (step-sampleDuration)/2, duration: sampleDuration}
+	}
+
+	return samples
+}
+
+// sampleFrame returns frame size of test encodes, it's the frame of converted video
+// or the frame of the highest variant of adaptive output
+func sampleFrame(opt *Request, src *response.Video) (int, int, error) {
+	if opt.Adaptive() {
+		renditions := buildRenditions(ladder(opt), src.ResolutionX, src.ResolutionY)
+		if len(renditions) == 0 {
+			return 0, 0, errors.New("ladder is empty")
+		}
+
+		return renditions[0].width, renditions[0].height, nil
+	}
+
+	w, h, known, err := outputFrame(opt, src)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	if !known {
+		w, h = displaySize(src)
+	}
+
+	return w, h, nil
+}
+
+// clampBitrate limits bitrate by min and max, defaultAutoMinBitrate is used when min is zero
+func clampBitrate(bitrate, min, max int64) int64 {
+	if min == 0 {
+		min = defaultAutoMinBitrate
+	}
+
+	if bitrate < min {
+		return min
+	}
+
+	if max != 0 && bitrate > max {
+		return max
+	}
+
+	return bitrate
+}
+
+// scaleRenditions multiplies bitrates of renditions by scale
+func scaleRenditions(renditions []rendition, scale float64) []rendition {
+	scaled := make([]rendition, len(renditions))
+
+	for i, r := range renditions {
+		r.Bitrate = int64(math.Round(float64(r.Bitrate) * scale))
+		scaled[i] = r
+	}
+
+	return scaled
+}
+
+// setVideoBitrate sets average, peak bitrate and buffer size of video stream
+func setVideoBitrate(opts *ffmpeg.Options, bitrate int64) {
+	b := strconv.FormatInt(bitrate, decimal)
+	maxRate := int(float64(bitrate) * peakBitrateRatio)
+	bufSize := int(float64(bitrate) * bufferBitrateRatio)
+
+	opts.VideoBitRate, opts.VideoMaxBitRate, opts.BufferSize = &b, &maxRate, &bufSize
+}
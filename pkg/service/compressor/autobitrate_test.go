@@ -0,0 +1,117 @@
+package compressor
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/Hargeon/compressrv/pkg/response"
+)
+
+func TestSamplePoints(t *testing.T) {
+	cases := []struct {
+		name           string
+		start          float64
+		duration       float64
+		count          int
+		sampleDuration float64
+		expected       []sample
+	}{
+		{
+			name:     "Default samples",
+			duration: 100,
+			expected: []sample{{8, 4}, {28, 4}, {48, 4}, {68, 4}, {88, 4}},
+		},
+		{
+			name:           "Clip",
+			start:          30,
+			duration:       60,
+			count:          3,
+			sampleDuration: 2,
+			expected:       []sample{{39, 2}, {59, 2}, {79, 2}},
+		},
+		{
+			name:     "Short video",
+			duration: 15,
+			expected: []sample{{0, 15}},
+		},
+	}
+
+	for _, testCase := range cases {
+		t.Run(testCase.name, func(t *testing.T) {
+			got := samplePoints(testCase.start, testCase.duration, testCase.count, testCase.sampleDuration)
+			if !reflect.DeepEqual(got, testCase.expected) {
+				t.Errorf("Invalid samples, expected: %v, got: %v\n", testCase.expected, got)
+			}
+		})
+	}
+}
+
+func TestClampBitrate(t *testing.T) {
+	cases := []struct {
+		name     string
+		bitrate  int64
+		min      int64
+		max      int64
+		expected int64
+	}{
+		{name: "Within limits", bitrate: 1500000, min: 500000, max: 4000000, expected: 1500000},
+		{name: "Below min", bitrate: 300000, min: 500000, max: 4000000, expected: 500000},
+		{name: "Above max", bitrate: 6000000, min: 500000, max: 4000000, expected: 4000000},
+		{name: "Default min", bitrate: 40000, expected: defaultAutoMinBitrate},
+		{name: "Without max", bitrate: 60000000, expected: 60000000},
+	}
+
+	for _, testCase := range cases {
+		t.Run(testCase.name, func(t *testing.T) {
+			if got := clampBitrate(testCase.bitrate, testCase.min, testCase.max); got != testCase.expected {
+				t.Errorf("Invalid bitrate, expected: %d, got: %d\n", testCase.expected, got)
+			}
+		})
+	}
+}
+
+func TestSampleFrame(t *testing.T) {
+	src := &response.Video{ResolutionX: 1920, ResolutionY: 1080, RatioX: 16, RatioY: 9}
+
+	cases := []struct {
+		name           string
+		opt            *Request
+		expectedWidth  int
+		expectedHeight int
+	}{
+		{name: "Original frame", opt: &Request{}, expectedWidth: 1920, expectedHeight: 1080},
+		{name: "Resolution", opt: &Request{Resolution: "720p"}, expectedWidth: 1280, expectedHeight: 720},
+		{name: "Cropped frame", opt: &Request{AutoCrop: true}, expectedWidth: 1920, expectedHeight: 1080},
+		{
+			name:           "The highest variant",
+			opt:            &Request{Output: OutputHLS, Ladder: []Variant{{Height: 480}, {Height: 720}}},
+			expectedWidth:  1280,
+			expectedHeight: 720,
+		},
+	}
+
+	for _, testCase := range cases {
+		t.Run(testCase.name, func(t *testing.T) {
+			w, h, err := sampleFrame(testCase.opt, src)
+			if err != nil {
+				t.Fatalf("Unexpected error: %s\n", err)
+			}
+
+			if w != testCase.expectedWidth || h != testCase.expectedHeight {
+				t.Errorf("Invalid frame, expected: %dx%d, got: %dx%d\n",
+					testCase.expectedWidth, testCase.expectedHeight, w, h)
+			}
+		})
+	}
+}
+
+func TestScaleRenditions(t *testing.T) {
+	renditions := buildRenditions(defaultLadder, 1920, 1080)
+	scaled := scaleRenditions(renditions, 0.5)
+
+	for i, r := range scaled {
+		if expected := renditions[i].Bitrate / 2; r.Bitrate != expected {
+			t.Errorf("Invalid bitrate of %s, expected: %d, got: %d\n", r.name, expected, r.Bitrate)
+		}
+	}
+}
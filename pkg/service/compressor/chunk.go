@@ -0,0 +1,333 @@
+package compressor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/Hargeon/compressrv/pkg/ffmpeg"
+	"github.com/Hargeon/compressrv/pkg/response"
+)
+
+const (
+	// defaultChunkDuration in seconds, chunks are split on keyframes, so they are a bit longer
+	defaultChunkDuration = 300
+	// defaultMinChunkedDuration in seconds, shorter videos are encoded by one ffmpeg process
+	defaultMinChunkedDuration = 1200
+	// chunkedDurationTolerance in seconds between original and converted video
+	chunkedDurationTolerance = 0.5
+)
+
+// Chunking configures parallel encoding of chunks of long videos
+type Chunking struct {
+	// Workers is a max number of chunks encoded at the same time, video isn't split when it's less than 2
+	Workers int
+	// Duration of chunk in seconds, defaultChunkDuration is used when it's zero
+	Duration float64
+	// MinDuration in seconds of video which is split to chunks, defaultMinChunkedDuration is used when it's zero
+	MinDuration float64
+}
+
+// Validate checks that chunking settings aren't negative
+func (c Chunking) Validate() error {
+	if c.Workers < 0 || c.Duration < 0 || c.MinDuration < 0 {
+		return errors.New("chunking settings can't be negative")
+	}
+
+	return nil
+}
+
+// withDefaults returns c with default durations instead of zero ones
+func (c Chunking) withDefaults() Chunking {
+	if c.Duration == 0 {
+		c.Duration = defaultChunkDuration
+	}
+
+	if c.MinDuration == 0 {
+		c.MinDuration = defaultMinChunkedDuration
+	}
+
+	return c
+}
+
+// chunkable reports if video of src can be converted with opt by parallel encoding of chunks.
+// Options which depend on timeline of the whole video (trimming, time limited watermark,
+// burned or kept subtitles, target size) need one ffmpeg process
+func (c *Compressor) chunkable(opt *Request, src *response.Video) bool {
+	if c.chunking.Workers < 2 || src == nil || src.Duration < c.chunking.MinDuration || src.Rotation != 0 {
+		return false
+	}
+
+	if opt.trimmed() || opt.Bitrate != 0 || opt.MaxSizeBytes != 0 || opt.subtitleMode() != SubtitleDrop {
+		return false
+	}
+
+	if w := opt.Watermark; w != nil && (w.Start != 0 || w.End != 0) {
+		return false
+	}
+
+	return true
+}
+
+// chunked reports if originalVideo is converted with opt by parallel encoding of chunks. Chunks are split
+// with reset timestamps and muxed with audio of the original video, so video stream which doesn't start
+// at zero (e.g. shifted by edit list) would lose its offset against audio, such video is encoded by one process
+func (c *Compressor) chunked(opt *Request, originalVideo string, src *response.Video) (bool, error) {
+	if !c.chunkable(opt, src) {
+		return false, nil
+	}
+
+	// start time isn't described by parsed headers
+	p, err := c.probe(originalVideo)
+	if err != nil {
+		return false, err
+	}
+
+	s := p.videoStream()
+	if s == nil {
+		return false, nil
+	}
+
+	start, err := strconv.ParseFloat(s.StartTime, bitrateBitSize)
+
+	return err == nil && start == 0, nil
+}
+
+// convertChunked converts originalVideo to newPath like convertVideo does, but video stream is split
+// to chunks on keyframes, chunks are encoded in parallel and concatenated without re-encoding.
+// Audio is encoded from the original video as a whole, so there are no gaps on borders of chunks
+func (c *Compressor) convertChunked(ctx context.Context, opt *Request, originalVideo, newPath string,
+	src *response.Video, opts *ffmpeg.Options, extra ...string) error {
+	dir := packageDir(opt, originalVideo, "chunks")
+	if err := os.RemoveAll(dir); err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(dir, dirPerm); err != nil {
+		return err
+	}
+
+	defer os.RemoveAll(dir)
+
+	_, err := c.run(ctx, "-i", originalVideo, "-map", "0:V:0", "-c", "copy",
+		"-f", "segment", "-segment_time", formatNumber(c.chunking.Duration), "-reset_timestamps", "1",
+		filepath.Join(dir, "part_%04d.mkv"))
+	if err != nil {
+		return err
+	}
+
+	parts, err := filepath.Glob(filepath.Join(dir, "part_*.mkv"))
+	if err != nil {
+		return err
+	}
+
+	sort.Strings(parts)
+
+	video, audio := splitOptions(opts)
+
+	encoded, err := c.encodeChunks(ctx, parts, video, extra)
+	if err != nil {
+		return err
+	}
+
+	// each frame of chunk is encoded when frame rate isn't changed
+	sameFrames := targetFPS(opt, src) == 0
+
+	var frames int64
+
+	for i, part := range encoded {
+		n, err := c.countFrames(ctx, part)
+		if err != nil {
+			return err
+		}
+
+		if sameFrames {
+			original, err := c.countFrames(ctx, parts[i])
+			if err != nil {
+				return err
+			}
+
+			if original != n {
+				return fmt.Errorf("chunk %d has %d frames instead of %d", i, n, original)
+			}
+		}
+
+		frames += n
+	}
+
+	list := filepath.Join(dir, "chunks.txt")
+	if err = writeConcatList(list, encoded); err != nil {
+		return err
+	}
+
+	args := []string{"-f", "concat", "-safe", "0", "-i", list, "-i", originalVideo,
+		"-map", "0:v:0", "-map", "1:a:0?", "-map_metadata", "1", "-c:v", "copy"}
+	args = append(args, audio.Args()...)
+	args = append(args, clearRotationArgs...)
+
+	if _, err = c.run(ctx, append(args, newPath)...); err != nil {
+		return err
+	}
+
+	return c.verifyChunked(ctx, newPath, src, frames, len(encoded))
+}
+
+// encodeChunks encodes parts with video options by bounded number of workers.
+// It returns paths of encoded chunks in order of parts, the first error cancels other workers
+func (c *Compressor) encodeChunks(ctx context.Context, parts []string, video ffmpeg.Options,
+	extra []string) ([]string, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	encoded := make([]string, len(parts))
+	slots := make(chan struct{}, c.chunking.Workers)
+
+	var (
+		wg       sync.WaitGroup
+		once     sync.Once
+		firstErr error
+	)
+
+	for i, part := range parts {
+		encoded[i] = strings.Replace(part, "part_", "chunk_", 1)
+
+		wg.Add(1)
+
+		go func(part, output string) {
+			defer wg.Done()
+
+			slots <- struct{}{}
+			defer func() { <-slots }()
+
+			if ctx.Err() != nil {
+				return
+			}
+
+			args := append([]string{"-i", part}, video.Args()...)
+			args = append(args, extra...)
+			args = append(args, "-an", "-sn", output)
+
+			if _, err := c.ffmpegCnf.Ffmpeg(args...).Threads(c.chunkThreads()).Run(ctx); err != nil {
+				once.Do(func() {
+					firstErr = err
+					cancel()
+				})
+			}
+		}(part, encoded[i])
+	}
+
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+
+	return encoded, nil
+}
+
+// chunkThreads returns thread limit of each chunk encoder, chunks share thread limit of the job
+func (c *Compressor) chunkThreads() int {
+	threads := c.ffmpegCnf.Limits.Threads
+	if threads == 0 {
+		return 0
+	}
+
+	threads /= c.chunking.Workers
+	if threads < 1 {
+		threads = 1
+	}
+
+	return threads
+}
+
+// verifyChunked checks that concatenated video has all frames of chunks and duration of original video
+func (c *Compressor) verifyChunked(ctx context.Context, path string, src *response.Video, frames int64,
+	chunks int) error {
+	n, err := c.countFrames(ctx, path)
+	if err != nil {
+		return err
+	}
+
+	if n != frames {
+		return fmt.Errorf("converted video has %d frames instead of %d", n, frames)
+	}
+
+	duration, err := c.videoDuration(path)
+	if err != nil {
+		return err
+	}
+
+	// each border of chunks may shift timeline by a frame
+	tolerance := chunkedDurationTolerance
+	if src.FPS != 0 {
+		tolerance += float64(chunks) / src.FPS
+	}
+
+	if math.Abs(duration-src.Duration) > tolerance {
+		return fmt.Errorf("converted video lasts %s seconds instead of %s",
+			formatNumber(duration), formatNumber(src.Duration))
+	}
+
+	return nil
+}
+
+// countFrames returns number of frames of the first video stream, frames are counted by packets without decoding
+func (c *Compressor) countFrames(ctx context.Context, path string) (int64, error) {
+	stdout, err := c.ffmpegCnf.Ffprobe("-select_streams", "v:0", "-count_packets",
+		"-show_entries", "stream=nb_read_packets", "-of", "csv=p=0", path).Stdout(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	return strconv.ParseInt(strings.TrimSpace(string(stdout)), decimal, bitrateBitSize)
+}
+
+// splitOptions splits opts to options of video chunks and options of audio and container of concatenated video
+func splitOptions(opts *ffmpeg.Options) (ffmpeg.Options, ffmpeg.Options) {
+	video := *opts
+	video.AudioCodec, video.AudioBitrate, video.AudioFilter, video.AudioRate, video.AudioChannels = nil, nil, nil, nil, nil
+	video.MovFlags = nil
+
+	audio := ffmpeg.Options{
+		AudioCodec:    opts.AudioCodec,
+		AudioBitrate:  opts.AudioBitrate,
+		AudioFilter:   opts.AudioFilter,
+		AudioRate:     opts.AudioRate,
+		AudioChannels: opts.AudioChannels,
+		MovFlags:      opts.MovFlags,
+	}
+
+	return video, audio
+}
+
+// writeConcatList writes list of files for concat demuxer
+func writeConcatList(path string, files []string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return concatList(f, files)
+}
+
+// concatList writes files in format of concat demuxer, quotes of paths are escaped
+func concatList(w io.Writer, files []string) error {
+	var b strings.Builder
+
+	for _, file := range files {
+		fmt.Fprintf(&b, "file '%s'\n", strings.ReplaceAll(file, `'`, `'\''`))
+	}
+
+	_, err := io.WriteString(w, b.String())
+
+	return err
+}
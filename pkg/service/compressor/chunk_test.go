@@ -0,0 +1,333 @@
+package compressor
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"testing"
+
+	"github.com/Hargeon/compressrv/pkg/ffmpeg"
+	"github.com/Hargeon/compressrv/pkg/response"
+)
+
+func TestChunkable(t *testing.T) {
+	long := &response.Video{Duration: 3600, FPS: 25}
+
+	cases := []struct {
+		name     string
+		workers  int
+		opt      *Request
+		src      *response.Video
+		expected bool
+	}{
+		{name: "Long video", workers: 4, opt: &Request{}, src: long, expected: true},
+		{name: "One worker", workers: 1, opt: &Request{}, src: long, expected: false},
+		{name: "Short video", workers: 4, opt: &Request{}, src: &response.Video{Duration: 600}, expected: false},
+		{name: "Unknown source", workers: 4, opt: &Request{}, src: nil, expected: false},
+		{name: "Rotated video", workers: 4, opt: &Request{},
+			src: &response.Video{Duration: 3600, Rotation: 90}, expected: false},
+		{name: "Trimmed video", workers: 4, opt: &Request{Start: 10}, src: long, expected: false},
+		{name: "Bitrate", workers: 4, opt: &Request{Bitrate: 600000}, src: long, expected: false},
+		{name: "Max size", workers: 4, opt: &Request{MaxSizeBytes: 1 << 20}, src: long, expected: false},
+		{name: "Kept subtitles", workers: 4, opt: &Request{Subtitles: &SubtitleOptions{Mode: SubtitleKeep}},
+			src: long, expected: false},
+		{name: "Dropped subtitles", workers: 4, opt: &Request{Subtitles: &SubtitleOptions{Mode: SubtitleDrop}},
+			src: long, expected: true},
+		{name: "Time limited watermark", workers: 4, opt: &Request{Watermark: &Watermark{End: 10}},
+			src: long, expected: false},
+		{name: "Watermark", workers: 4, opt: &Request{Watermark: &Watermark{}}, src: long, expected: true},
+	}
+
+	for _, testCase := range cases {
+		t.Run(testCase.name, func(t *testing.T) {
+			srv := NewCompressor("", "")
+			srv.SetChunking(Chunking{Workers: testCase.workers})
+
+			if chunkable := srv.chunkable(testCase.opt, testCase.src); chunkable != testCase.expected {
+				t.Errorf("Invalid chunkable, expected: %v, got: %v\n", testCase.expected, chunkable)
+			}
+		})
+	}
+}
+
+func TestChunked(t *testing.T) {
+	dir := t.TempDir()
+	long := &response.Video{Duration: 3600, FPS: 25}
+
+	cases := []struct {
+		name     string
+		opt      *Request
+		start    string
+		expected bool
+	}{
+		{name: "Video starts at zero", opt: &Request{}, start: "0.000000", expected: true},
+		{name: "Shifted video", opt: &Request{}, start: "1.500000", expected: false},
+		{name: "Unknown start", opt: &Request{}, start: "N/A", expected: false},
+		{name: "Trimmed video", opt: &Request{Start: 10}, start: "0.000000", expected: false},
+	}
+
+	for i, testCase := range cases {
+		t.Run(testCase.name, func(t *testing.T) {
+			path := filepath.Join(dir, fmt.Sprintf("video_%d.mkv", i))
+			if err := os.WriteFile(path, []byte("video"), 0600); err != nil {
+				t.Fatalf("Unexpected error: %s\n", err)
+			}
+
+			srv := NewCompressor("", "")
+			srv.SetChunking(Chunking{Workers: 4})
+			// start time is read from ffprobe output
+			srv.meta.put(path, &probeResult{Streams: []probeStream{
+				{CodecType: streamVideo, StartTime: testCase.start},
+				{CodecType: streamAudio, StartTime: "0.000000"},
+			}}, true)
+
+			chunked, err := srv.chunked(testCase.opt, path, long)
+			if err != nil {
+				t.Fatalf("Unexpected error: %s\n", err)
+			}
+
+			if chunked != testCase.expected {
+				t.Errorf("Invalid chunked, expected: %v, got: %v\n", testCase.expected, chunked)
+			}
+		})
+	}
+}
+
+func TestChunking(t *testing.T) {
+	cases := []struct {
+		name         string
+		chunking     Chunking
+		expected     Chunking
+		errorPresent bool
+	}{
+		{
+			name:     "Default durations",
+			chunking: Chunking{Workers: 4},
+			expected: Chunking{Workers: 4, Duration: defaultChunkDuration, MinDuration: defaultMinChunkedDuration},
+		},
+		{
+			name:     "Own durations",
+			chunking: Chunking{Workers: 2, Duration: 60, MinDuration: 600},
+			expected: Chunking{Workers: 2, Duration: 60, MinDuration: 600},
+		},
+		{
+			name:         "Negative duration",
+			chunking:     Chunking{Workers: 2, Duration: -1},
+			errorPresent: true,
+		},
+	}
+
+	for _, testCase := range cases {
+		t.Run(testCase.name, func(t *testing.T) {
+			err := testCase.chunking.Validate()
+			if err != nil && !testCase.errorPresent {
+				t.Fatalf("Unexpected error: %s\n", err)
+			}
+
+			if err == nil && testCase.errorPresent {
+				t.Fatalf("Should be error\n")
+			}
+
+			if err != nil {
+				return
+			}
+
+			srv := NewCompressor("", "")
+			srv.SetChunking(testCase.chunking)
+
+			if srv.chunking != testCase.expected {
+				t.Errorf("Invalid chunking, expected: %+v, got: %+v\n", testCase.expected, srv.chunking)
+			}
+		})
+	}
+}
+
+func TestSplitOptions(t *testing.T) {
+	codec, bitrate, filter, flags, resolution := "aac", "128000", "loudnorm", faststartFlags, "1280x720"
+	rate := 48000
+
+	opts := &ffmpeg.Options{AudioCodec: &codec, AudioBitrate: &bitrate, AudioFilter: &filter, AudioRate: &rate,
+		MovFlags: &flags, Resolution: &resolution}
+
+	video, audio := splitOptions(opts)
+
+	expectedVideo := ffmpeg.Options{Resolution: &resolution}
+	if !reflect.DeepEqual(video, expectedVideo) {
+		t.Errorf("Invalid video options, expected: %v, got: %v\n",
+			expectedVideo.Args(), video.Args())
+	}
+
+	expectedAudio := ffmpeg.Options{AudioCodec: &codec, AudioBitrate: &bitrate, AudioFilter: &filter, AudioRate: &rate,
+		MovFlags: &flags}
+	if !reflect.DeepEqual(audio, expectedAudio) {
+		t.Errorf("Invalid audio options, expected: %v, got: %v\n",
+			expectedAudio.Args(), audio.Args())
+	}
+
+	if opts.AudioCodec == nil || opts.MovFlags == nil {
+		t.Errorf("Invalid options, original options are changed\n")
+	}
+}
+
+func TestConcatList(t *testing.T) {
+	var b bytes.Buffer
+
+	if err := concatList(&b, []string{"/tmp/chunk_0000.mkv", "/tmp/it's/chunk_0001.mkv"}); err != nil {
+		t.Fatalf("Unexpected error: %s\n", err)
+	}
+
+	expected := "file '/tmp/chunk_0000.mkv'\nfile '/tmp/it'\\''s/chunk_0001.mkv'\n"
+	if b.String() != expected {
+		t.Errorf("Invalid concat list, expected: %s, got: %s\n", expected, b.String())
+	}
+}
+
+func TestConvertChunked(t *testing.T) {
+	ctx := context.Background()
+	originalVideo := fmt.Sprintf("%s%stest_video.mkv", os.Getenv("ROOT"), originalVideoPath)
+
+	plainSrv := NewCompressor(os.Getenv("FFMPEG_PATH"), os.Getenv("FFPROBE_PATH"))
+	chunkedSrv := NewCompressor(os.Getenv("FFMPEG_PATH"), os.Getenv("FFPROBE_PATH"))
+	// the fixture lasts 42 seconds, so it's split to several chunks
+	chunkedSrv.SetChunking(Chunking{Workers: 2, Duration: 10, MinDuration: 10})
+
+	src, err := plainSrv.VideoInfo(originalVideo)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s\n", err)
+	}
+
+	if !chunkedSrv.chunkable(&Request{Resolution: "640x360"}, src) {
+		t.Fatalf("Fixture should be chunkable\n")
+	}
+
+	plainPath, err := plainSrv.Convert(ctx, &Request{Resolution: "640x360", WorkDir: t.TempDir()}, originalVideo)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s\n", err)
+	}
+
+	chunkedPath, err := chunkedSrv.Convert(ctx, &Request{Resolution: "640x360", WorkDir: t.TempDir()}, originalVideo)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s\n", err)
+	}
+
+	plain, err := plainSrv.VideoInfo(plainPath)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s\n", err)
+	}
+
+	chunked, err := chunkedSrv.VideoInfo(chunkedPath)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s\n", err)
+	}
+
+	if chunked.Streams != plain.Streams || len(chunked.Audio) != len(plain.Audio) {
+		t.Errorf("Invalid streams, expected: %d with %d audio, got: %d with %d audio\n",
+			plain.Streams, len(plain.Audio), chunked.Streams, len(chunked.Audio))
+	}
+
+	if math.Abs(chunked.Duration-plain.Duration) > chunkedDurationTolerance {
+		t.Errorf("Invalid duration, expected: %v, got: %v\n", plain.Duration, chunked.Duration)
+	}
+
+	// audio is shifted against video by less than a frame
+	frame := 1 / src.FPS
+	if src.FPS == 0 {
+		frame = chunkedDurationTolerance
+	}
+
+	if diff := avOffset(t, chunkedSrv, chunkedPath) - avOffset(t, plainSrv, plainPath); math.Abs(diff) > frame {
+		t.Errorf("Invalid audio sync, audio is shifted by %v seconds against plain encode\n", diff)
+	}
+}
+
+func TestConvertChunkedStartTime(t *testing.T) {
+	ctx := context.Background()
+	originalVideo := fmt.Sprintf("%s%stest_video.mkv", os.Getenv("ROOT"), originalVideoPath)
+
+	plainSrv := NewCompressor(os.Getenv("FFMPEG_PATH"), os.Getenv("FFPROBE_PATH"))
+	chunkedSrv := NewCompressor(os.Getenv("FFMPEG_PATH"), os.Getenv("FFPROBE_PATH"))
+	chunkedSrv.SetChunking(Chunking{Workers: 2, Duration: 10, MinDuration: 10})
+
+	// video stream starts 1.5 seconds after audio stream
+	shifted := filepath.Join(t.TempDir(), "shifted.mkv")
+
+	_, err := plainSrv.run(ctx, "-itsoffset", "1.5", "-i", originalVideo,
+		"-f", "lavfi", "-i", "sine=frequency=440:duration=45",
+		"-map", "0:V:0", "-map", "1:a", "-c:v", "copy", "-c:a", "aac", shifted)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s\n", err)
+	}
+
+	src, err := chunkedSrv.VideoInfo(shifted)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s\n", err)
+	}
+
+	opt := &Request{Resolution: "640x360"}
+
+	chunked, err := chunkedSrv.chunked(opt, shifted, src)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s\n", err)
+	}
+
+	if chunked {
+		t.Errorf("Shifted video shouldn't be encoded by chunks\n")
+	}
+
+	plainPath, err := plainSrv.Convert(ctx, &Request{Resolution: "640x360", WorkDir: t.TempDir()}, shifted)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s\n", err)
+	}
+
+	chunkedPath, err := chunkedSrv.Convert(ctx, &Request{Resolution: "640x360", WorkDir: t.TempDir()}, shifted)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s\n", err)
+	}
+
+	frame := chunkedDurationTolerance
+	if src.FPS != 0 {
+		frame = 1 / src.FPS
+	}
+
+	if diff := avOffset(t, chunkedSrv, chunkedPath) - avOffset(t, plainSrv, plainPath); math.Abs(diff) > frame {
+		t.Errorf("Invalid audio sync, audio is shifted by %v seconds against plain encode\n", diff)
+	}
+}
+
+// avOffset returns difference between start of the first audio stream and start of video stream of path
+func avOffset(t *testing.T, c *Compressor, path string) float64 {
+	t.Helper()
+
+	stdout, err := c.ffmpegCnf.Ffprobe("-show_entries", "stream=codec_type,start_time", "-of", "json", path).
+		Stdout(context.Background())
+	if err != nil {
+		t.Fatalf("Unexpected error: %s\n", err)
+	}
+
+	var probe struct {
+		Streams []struct {
+			CodecType string `json:"codec_type"`
+			StartTime string `json:"start_time"`
+		} `json:"streams"`
+	}
+
+	if err = json.Unmarshal(stdout, &probe); err != nil {
+		t.Fatalf("Unexpected error: %s\n", err)
+	}
+
+	starts := map[string]float64{}
+
+	for _, s := range probe.Streams {
+		if _, ok := starts[s.CodecType]; !ok {
+			starts[s.CodecType], _ = strconv.ParseFloat(s.StartTime, bitrateBitSize)
+		}
+	}
+
+	return starts[streamAudio] - starts[streamVideo]
+}
@@ -0,0 +1,165 @@
+package compressor
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/Hargeon/compressrv/pkg/response"
+)
+
+// Strengths of denoise
+const (
+	DenoiseLight  = "light"
+	DenoiseMedium = "medium"
+	DenoiseStrong = "strong"
+)
+
+// analyzedFrames is a number of frames checked by idet and cropdetect
+const analyzedFrames = "500"
+
+// denoiseFilters are hqdn3d filters of denoise strengths, medium one has hqdn3d defaults
+var denoiseFilters = map[string]string{
+	DenoiseLight:  "hqdn3d=2:1.5:3:2.25",
+	DenoiseMedium: "hqdn3d=4:3:6:4.5",
+	DenoiseStrong: "hqdn3d=8:6:12:9",
+}
+
+var (
+	idetRe       = regexp.MustCompile(`Multi frame detection: TFF:\s*([0-9]+) BFF:\s*([0-9]+) Progressive:\s*([0-9]+)`)
+	cropdetectRe = regexp.MustCompile(`crop=([0-9]+):([0-9]+):([0-9]+):([0-9]+)`)
+)
+
+// detection is a result of analysis of original video for cleanup filters
+type detection struct {
+	// parity of interlaced video (tff, bff), empty for progressive video
+	parity string
+	// crop is a frame without black bars, nil when video has no bars
+	crop *cropArea
+}
+
+// cropArea is a part of frame with width w, height h and top left corner at x, y
+type cropArea struct {
+	w, h, x, y int
+}
+
+// detect analyzes originalVideo with idet and cropdetect filters when opt asks for
+// auto-deinterlace or auto-crop and returns copy of opt with the result
+func (c *Compressor) detect(ctx context.Context, opt *Request, originalVideo string,
+	src *response.Video) (*Request, error) {
+	var filters []string
+
+	if opt.Deinterlace {
+		filters = append(filters, "idet")
+	}
+
+	if opt.AutoCrop {
+		// reset=0 accumulates the largest non-black area over all frames
+		filters = append(filters, "cropdetect=limit=24:round=2:reset=0")
+	}
+
+	if len(filters) == 0 {
+		return opt, nil
+	}
+
+	var args []string
+	if opt.Start != 0 {
+		args = append(args, "-ss", formatNumber(opt.Start))
+	}
+
+	args = append(args, "-i", originalVideo, "-frames:v", analyzedFrames,
+		"-vf", strings.Join(filters, ","), "-an", "-sn", "-f", "null", "-")
+
+	stderr, err := c.run(ctx, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	req := *opt
+	req.detected = parseDetection(stderr, src)
+
+	return &req, nil
+}
+
+// parseDetection parses ffmpeg output of idet and cropdetect filters.
+// src is an original video info, crop of the whole frame is ignored
+func parseDetection(stderr string, src *response.Video) *detection {
+	d := new(detection)
+
+	if m := idetRe.FindStringSubmatch(stderr); m != nil {
+		tff, _ := strconv.Atoi(m[1])
+		bff, _ := strconv.Atoi(m[2])
+		progressive, _ := strconv.Atoi(m[3])
+
+		if tff+bff > progressive {
+			d.parity = "tff"
+			if bff > tff {
+				d.parity = "bff"
+			}
+		}
+	}
+
+	if all := cropdetectRe.FindAllStringSubmatch(stderr, -1); len(all) != 0 {
+		m := all[len(all)-1]
+		area := new(cropArea)
+		area.w, _ = strconv.Atoi(m[1])
+		area.h, _ = strconv.Atoi(m[2])
+		area.x, _ = strconv.Atoi(m[3])
+		area.y, _ = strconv.Atoi(m[4])
+
+		whole := src != nil && area.w >= src.ResolutionX && area.h >= src.ResolutionY
+		if area.w > 0 && area.h > 0 && !whole {
+			d.crop = area
+		}
+	}
+
+	return d
+}
+
+// cleanupFilters returns deinterlace, crop, deshake and denoise filters of opt in the order they are applied
+func cleanupFilters(opt *Request) []string {
+	var filters []string
+
+	if d := opt.detected; d != nil {
+		if d.parity != "" {
+			// deinterlaced frame is made of each frame, so frame rate isn't changed
+			filters = append(filters, "yadif=mode=send_frame:parity="+d.parity)
+		}
+
+		if d.crop != nil {
+			filters = append(filters, fmt.Sprintf("crop=%d:%d:%d:%d", d.crop.w, d.crop.h, d.crop.x, d.crop.y))
+		}
+	}
+
+	if opt.Deshake {
+		filters = append(filters, "deshake")
+	}
+
+	if f, ok := denoiseFilters[opt.Denoise]; ok {
+		filters = append(filters, f)
+	}
+
+	return filters
+}
+
+// croppedVideo returns info of src cropped by opt, src is returned when nothing is cropped.
+// Display aspect ratio of cropped video keeps sample aspect ratio of src
+func croppedVideo(opt *Request, src *response.Video) *response.Video {
+	if src == nil || opt.detected == nil || opt.detected.crop == nil {
+		return src
+	}
+
+	area := opt.detected.crop
+	video := *src
+	video.ResolutionX, video.ResolutionY = area.w, area.h
+
+	if src.RatioX != 0 && src.RatioY != 0 {
+		x, y := area.w*src.RatioX*src.ResolutionY, area.h*src.RatioY*src.ResolutionX
+		d := gcd(x, y)
+		video.RatioX, video.RatioY = x/d, y/d
+	}
+
+	return &video
+}
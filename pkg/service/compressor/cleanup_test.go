@@ -0,0 +1,94 @@
+package compressor
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/Hargeon/compressrv/pkg/response"
+)
+
+func TestParseDetection(t *testing.T) {
+	src := &response.Video{ResolutionX: 1920, ResolutionY: 1080}
+
+	cases := []struct {
+		name     string
+		stderr   string
+		expected *detection
+	}{
+		{
+			name: "Interlaced bottom field first",
+			stderr: "[Parsed_idet_0 @ 0x1] Repeated Fields: Neither: 480 Top: 10 Bottom: 10\n" +
+				"[Parsed_idet_0 @ 0x1] Single frame detection: TFF: 3 BFF: 390 Progressive: 20 Undetermined: 87\n" +
+				"[Parsed_idet_0 @ 0x1] Multi frame detection: TFF: 0 BFF: 470 Progressive: 12 Undetermined: 18\n",
+			expected: &detection{parity: "bff"},
+		},
+		{
+			name:     "Progressive",
+			stderr:   "[Parsed_idet_0 @ 0x1] Multi frame detection: TFF: 5 BFF: 2 Progressive: 490 Undetermined: 3\n",
+			expected: &detection{},
+		},
+		{
+			name: "Letterbox",
+			stderr: "[Parsed_cropdetect_0 @ 0x1] x1:0 x2:1919 y1:140 y2:939 w:1920 h:800 x:0 y:140 crop=1920:800:0:140\n" +
+				"[Parsed_cropdetect_0 @ 0x1] x1:0 x2:1919 y1:138 y2:941 w:1920 h:804 x:0 y:138 crop=1920:804:0:138\n",
+			expected: &detection{crop: &cropArea{w: 1920, h: 804, x: 0, y: 138}},
+		},
+		{
+			name:     "No black bars",
+			stderr:   "[Parsed_cropdetect_0 @ 0x1] x1:0 x2:1919 y1:0 y2:1079 w:1920 h:1080 x:0 y:0 crop=1920:1080:0:0\n",
+			expected: &detection{},
+		},
+	}
+
+	for _, testCase := range cases {
+		t.Run(testCase.name, func(t *testing.T) {
+			if d := parseDetection(testCase.stderr, src); !reflect.DeepEqual(d, testCase.expected) {
+				t.Errorf("Invalid detection, expected: %+v, got: %+v\n", testCase.expected, d)
+			}
+		})
+	}
+}
+
+func TestBuildOptionsCleanup(t *testing.T) {
+	src := &response.Video{ResolutionX: 1920, ResolutionY: 1080, RatioX: 16, RatioY: 9, FPS: 50}
+
+	cases := []struct {
+		name           string
+		opt            *Request
+		expectedFilter string
+	}{
+		{
+			name:           "Denoise and deshake",
+			opt:            &Request{Denoise: DenoiseLight, Deshake: true},
+			expectedFilter: "deshake,hqdn3d=2:1.5:3:2.25",
+		},
+		{
+			name: "Deinterlace, crop and scale",
+			opt: &Request{
+				Resolution: "max:1280x720",
+				MaxFPS:     25,
+				detected:   &detection{parity: "tff", crop: &cropArea{w: 1920, h: 800, x: 0, y: 140}},
+			},
+			expectedFilter: "yadif=mode=send_frame:parity=tff,crop=1920:800:0:140,fps=25,scale=1280:534",
+		},
+	}
+
+	srv := NewCompressor("", "")
+
+	for _, testCase := range cases {
+		t.Run(testCase.name, func(t *testing.T) {
+			opts, err := srv.buildOptions(testCase.opt, src)
+			if err != nil {
+				t.Fatalf("Unexpected error: %s\n", err)
+			}
+
+			if opts.VideoFilter == nil {
+				t.Fatalf("Video filter is missing\n")
+			}
+
+			if *opts.VideoFilter != testCase.expectedFilter {
+				t.Errorf("Invalid video filter, expected: %s, got: %s\n", testCase.expectedFilter, *opts.VideoFilter)
+			}
+		})
+	}
+}
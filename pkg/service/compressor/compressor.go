@@ -2,15 +2,14 @@ package compressor
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
-	"regexp"
+	"path/filepath"
 	"strconv"
-	"strings"
 
+	"github.com/Hargeon/compressrv/pkg/ffmpeg"
 	"github.com/Hargeon/compressrv/pkg/response"
-
-	"github.com/floostack/transcoder/ffmpeg"
 )
 
 const (
@@ -18,7 +17,6 @@ const (
 	bitrateAccuracy     = 1000
 	decreaseBitrate     = 0.6
 	increaseBitrate     = 2
-	ratioNumber         = 2
 	decimal             = 10
 	bitrateBitSize      = 64
 )
@@ -26,6 +24,9 @@ const (
 // Compressor uses for changing bitrate, resolution and ratio for video
 type Compressor struct {
 	ffmpegCnf *ffmpeg.Config
+	// chunking of long videos, they are encoded by one process by default
+	chunking Chunking
+	meta     *metaCache
 }
 
 // NewCompressor initialize Compressor
@@ -35,94 +36,148 @@ func NewCompressor(ffmpegPath, ffprobePath string) *Compressor {
 			FfmpegBinPath:  ffmpegPath,
 			FfprobeBinPath: ffprobePath,
 		},
+		chunking: Chunking{}.withDefaults(),
+		meta:     newMetaCache(),
 	}
 }
 
+// SetChunking enables parallel encoding of chunks of long videos by up to chunking.Workers ffmpeg processes,
+// video is encoded by one process when workers is less than 2
+func (c *Compressor) SetChunking(chunking Chunking) {
+	c.chunking = chunking.withDefaults()
+}
+
+// SetLimits sets resource limits of ffmpeg and ffprobe processes started by Compressor
+func (c *Compressor) SetLimits(limits ffmpeg.Limits) {
+	c.ffmpegCnf.Limits = limits
+}
+
 // Convert function change bitrate, resolution and ratio for video
 func (c *Compressor) Convert(ctx context.Context, opt *Request, originalVideo string) (string, error) {
-	opts := c.buildOptions(opt)
+	if opt.FastCut && opt.trimmed() {
+		if !opt.reencode() {
+			return c.cut(ctx, opt, originalVideo, convertedVideoPath(opt, originalVideo))
+		}
 
-	if opt.Bitrate != 0 {
-		return c.convertWithBitrate(originalVideo, opts)
+		// the clip is converted like a whole video
+		clipPath, err := c.cut(ctx, opt, originalVideo, workPath(opt, "clip_"+filepath.Base(originalVideo)))
+		if err != nil {
+			return "", err
+		}
+
+		defer os.Remove(clipPath)
+
+		clipOpt := *opt
+		clipOpt.Start, clipOpt.End, clipOpt.Duration = 0, 0, 0
+		opt, originalVideo = &clipOpt, clipPath
 	}
 
-	root := os.Getenv("ROOT")
-	iName := strings.LastIndex(originalVideo, "/")
+	var src *response.Video
 
-	newVideoName := originalVideo[iName:]
-	newVideoPath := fmt.Sprintf("%s%s%s", root, convertedVideosPath, newVideoName)
-	err := c.convertVideo(originalVideo, newVideoPath, opts)
+	// duration of video is required to decide if video is encoded by chunks
+	if opt.needsSource() || c.chunking.Workers > 1 {
+		info, err := c.VideoInfo(originalVideo)
+		if err != nil {
+			return "", err
+		}
+
+		src = info
+	}
+
+	opt = withSubtitleSource(opt, originalVideo)
 
+	opt, err := c.detect(ctx, opt, originalVideo, src)
 	if err != nil {
 		return "", err
 	}
 
-	return newVideoPath, nil
-}
+	opt, err = c.withLoudness(ctx, opt, originalVideo)
+	if err != nil {
+		return "", err
+	}
 
-// VideoInfo function calculate bitrate, resolution and ratio for video file
-func (c *Compressor) VideoInfo(path string) (*response.Video, error) {
-	video := new(response.Video)
+	opts, err := c.buildOptions(opt, src)
+	if err != nil {
+		return "", err
+	}
 
-	bitrate, err := c.videoBitrate(path)
+	if opt.AutoBitrate != nil {
+		selected, err := c.selectedBitrate(ctx, opt, originalVideo)
+		if err != nil {
+			return "", err
+		}
 
-	if err != nil {
-		return nil, err
+		setVideoBitrate(opts, selected.Bitrate)
 	}
 
-	video.Bitrate = bitrate
+	if flags := movFlags(opt, filepath.Ext(originalVideo)); flags != "" {
+		opts.MovFlags = &flags
+	}
 
-	metaData, err := ffmpeg.New(c.ffmpegCnf).Input(path).GetMetadata()
+	subtitles, err := subtitleArgs(opt, src, filepath.Ext(originalVideo), opts)
 	if err != nil {
-		return nil, err
+		return "", err
 	}
 
-	streams := metaData.GetStreams()
-	video.ResolutionX = streams[0].GetWidth()
-	video.ResolutionY = streams[0].GetHeight()
+	extra := append(extraArgs(opt), subtitles...)
 
-	re, err := regexp.Compile(`[0-9]+`)
-	if err != nil {
-		return nil, err
+	if opt.MaxSizeBytes != 0 {
+		return c.convertToSize(ctx, opt, originalVideo, src, opts, extra...)
+	}
+
+	if opt.Bitrate != 0 {
+		return c.convertWithBitrate(ctx, opt, originalVideo, opts, extra...)
 	}
 
-	ratioStr := streams[0].GetDisplayAspectRatio() // 4:3
-	ratios := re.FindAllString(ratioStr, ratioNumber)
-	ratioX, err := strconv.Atoi(ratios[0])
+	newVideoPath := convertedVideoPath(opt, originalVideo)
 
+	chunked, err := c.chunked(opt, originalVideo, src)
 	if err != nil {
-		return nil, err
+		return "", err
+	}
+
+	if chunked {
+		err = c.convertChunked(ctx, opt, originalVideo, newVideoPath, src, opts, extra...)
+	} else {
+		err = c.convertVideo(ctx, originalVideo, newVideoPath, opts, extra...)
 	}
 
-	ratioY, err := strconv.Atoi(ratios[1])
 	if err != nil {
-		return nil, err
+		return "", err
 	}
 
-	video.RatioX = ratioX
-	video.RatioY = ratioY
+	return newVideoPath, nil
+}
+
+// VideoInfo function calculate bitrate, resolution, ratio and other params of main video stream for video file.
+// Parsed MP4 and Matroska headers are used when they describe main video stream, other files are probed by ffprobe
+func (c *Compressor) VideoInfo(path string) (*response.Video, error) {
+	p, err := c.header(path)
+	if err != nil || !describesVideo(p) {
+		if p, err = c.probe(path); err != nil {
+			return nil, err
+		}
+	}
 
-	return video, nil
+	return videoInfo(p)
 }
 
 // convertWithBitrate uses for changing bitrate for video file.
-func (c *Compressor) convertWithBitrate(originalVideo string, opts *ffmpeg.Options) (string, error) {
+func (c *Compressor) convertWithBitrate(ctx context.Context, opt *Request, originalVideo string,
+	opts *ffmpeg.Options, extra ...string) (string, error) {
 	expectedBitrate := int64(*opts.BufferSize)
 
-	root := os.Getenv("ROOT")
-	iName := strings.LastIndex(originalVideo, "/")
-
-	newVideoName := originalVideo[iName+1:]
+	newVideoName := filepath.Base(originalVideo)
 
 	var newVideoPath string
 
 	// Bitrate and buffer size needs for changing bitrate on video file.
 	// Buffer size changes on each step and creates new video file.
 	for i := 1; ; i++ {
-		previousVideoPath := fmt.Sprintf("%s%s/v%d_%s", root, convertedVideosPath, i-1, newVideoName)
-		newVideoPath = fmt.Sprintf("%s%s/v%d_%s", root, convertedVideosPath, i, newVideoName)
+		previousVideoPath := workPath(opt, fmt.Sprintf("v%d_%s", i-1, newVideoName))
+		newVideoPath = workPath(opt, fmt.Sprintf("v%d_%s", i, newVideoName))
 
-		err := c.convertVideo(originalVideo, newVideoPath, opts)
+		err := c.convertVideo(ctx, originalVideo, newVideoPath, opts, extra...)
 		if err != nil {
 			return "", err
 		}
@@ -162,31 +217,117 @@ func (c *Compressor) convertWithBitrate(originalVideo string, opts *ffmpeg.Optio
 	return newVideoPath, nil
 }
 
-// convertVideo from originPath to newPath with *ffmpeg.Options
-func (c *Compressor) convertVideo(originPath, newPath string, opts *ffmpeg.Options) error {
-	// don't use transcoder.Transcoder in params.
-	// Duplicate WithOptions raise error: number of options and output files does not match
-	_, err := ffmpeg.
-		New(c.ffmpegCnf).
+// convertVideo from originPath to newPath with *ffmpeg.Options.
+// extra are ffmpeg options which can't be described by ffmpeg.Options (e.g. -sc_threshold)
+func (c *Compressor) convertVideo(ctx context.Context, originPath, newPath string,
+	opts *ffmpeg.Options, extra ...string) error {
+	// invalid input is reported by ffmpeg as error of input category
+	_, err := c.ffmpegCnf.Ffmpeg().
 		Input(originPath).
-		Output(newPath).
-		WithOptions(*opts).
-		Start(*opts)
+		Options(opts).
+		Args(extra...).
+		Output(newPath, clearRotationArgs...).
+		Run(ctx)
 
 	return err
 }
 
-// buildOptions for converting from *Request
-func (c *Compressor) buildOptions(opt *Request) *ffmpeg.Options {
+// convertedVideoPath returns path of converted video for originalVideo
+func convertedVideoPath(opt *Request, originalVideo string) string {
+	return workPath(opt, "converted_"+filepath.Base(originalVideo))
+}
+
+// workPath returns path of file in working directory of job,
+// jobs without own directory use converted videos directory of ROOT
+func workPath(opt *Request, name string) string {
+	dir := opt.WorkDir
+	if dir == "" {
+		dir = os.Getenv("ROOT") + convertedVideosPath
+	}
+
+	return filepath.Join(dir, name)
+}
+
+// buildOptions for converting from *Request.
+// src is an original video info, it's required for real aspect ratio conversion
+func (c *Compressor) buildOptions(opt *Request, src *response.Video) (*ffmpeg.Options, error) {
 	opts := ffmpeg.Options{}
-	if opt.Resolution != "" {
-		opts.Resolution = &opt.Resolution
+
+	// frame is cleaned up before other filters, deinterlace and crop need original frames
+	filters := cleanupFilters(opt)
+	src = croppedVideo(opt, src)
+
+	// frame rate is changed before other filters, so they process less frames
+	if fps := targetFPS(opt, src); fps != 0 {
+		filters = append(filters, fpsFilter(opt, fps))
+	}
+
+	if opt.KeyframeInterval != 0 {
+		if gop := gopSize(opt, src); gop != 0 {
+			opts.KeyframeInterval = &gop
+		}
+	}
+
+	if opt.fitMode() != "" {
+		if src == nil {
+			return nil, errors.New("original video info is required for fit")
+		}
+
+		fit, err := fitFilters(opt, src)
+		if err != nil {
+			return nil, err
+		}
+
+		filters = append(filters, fit...)
+	} else if opt.Resolution != "" {
+		// watermark and subtitles are placed relative to the converted frame,
+		// so frame is scaled by filter before them instead of -s after all filters
+		scale, err := resolutionFilters(opt, src)
+		if err != nil {
+			return nil, err
+		}
+
+		filters = append(filters, scale...)
+	}
+
+	subtitles, err := subtitleFilter(opt, src)
+	if err != nil {
+		return nil, err
+	}
+
+	if subtitles != "" {
+		// subtitles are rendered on converted frame, so their size doesn't depend on original resolution
+		filters = append(filters, subtitles)
+	}
+
+	if opt.Watermark != nil {
+		vf := watermarkGraph(ffmpeg.Chain(filters...), opt.Watermark)
+		opts.VideoFilter = &vf
+	} else if len(filters) != 0 {
+		vf := ffmpeg.Chain(filters...)
+		opts.VideoFilter = &vf
 	}
 
 	if opt.Ratio != "" {
 		opts.Aspect = &opt.Ratio
 	}
 
+	if opt.Loudness != nil && opt.Loudness.Measured != nil {
+		// loudnorm upsamples audio to 192 kHz
+		af, rate := loudnormFilter(opt.Loudness, opt.Loudness.Measured), audioRate(src)
+		opts.AudioFilter, opts.AudioRate = &af, &rate
+	}
+
+	if opt.Start != 0 {
+		start := formatNumber(opt.Start)
+		opts.SeekTime = &start
+	}
+
+	if d := opt.clipDuration(); d != 0 {
+		duration := formatNumber(d)
+		opts.Duration = &duration
+	}
+
 	if opt.Bitrate != 0 {
 		bufSize := int(opt.Bitrate)
 		bStr := fmt.Sprintf("%d", opt.Bitrate)
@@ -194,17 +335,53 @@ func (c *Compressor) buildOptions(opt *Request) *ffmpeg.Options {
 		opts.VideoBitRate = &bStr
 	}
 
-	return &opts
+	return &opts, nil
+}
+
+// extraArgs returns ffmpeg options for opt which can't be described by ffmpeg.Options
+func extraArgs(opt *Request) []string {
+	var args []string
+
+	if opt.SceneCut != nil && !*opt.SceneCut {
+		// keyframes are placed only by keyframe interval
+		args = append(args, "-sc_threshold", "0")
+	}
+
+	return args
 }
 
 // videoBitrate return bitrate of video
 func (c *Compressor) videoBitrate(videoPath string) (int64, error) {
-	metaData, err := ffmpeg.New(c.ffmpegCnf).Input(videoPath).GetMetadata()
+	p, err := c.header(videoPath)
 	if err != nil {
 		return 0, err
 	}
 
-	bStr := metaData.GetFormat().GetBitRate()
+	return strconv.ParseInt(p.Format.BitRate, decimal, bitrateBitSize)
+}
+
+// videoDuration returns duration of video in seconds
+func (c *Compressor) videoDuration(videoPath string) (float64, error) {
+	p, err := c.header(videoPath)
+	if err != nil {
+		return 0, err
+	}
+
+	return strconv.ParseFloat(p.Format.Duration, bitrateBitSize)
+}
+
+// hasAudio reports if video file contains audio stream
+func (c *Compressor) hasAudio(videoPath string) (bool, error) {
+	p, err := c.header(videoPath)
+	if err != nil {
+		return false, err
+	}
+
+	for _, stream := range p.Streams {
+		if stream.CodecType == streamAudio {
+			return true, nil
+		}
+	}
 
-	return strconv.ParseInt(bStr, decimal, bitrateBitSize)
+	return false, nil
 }
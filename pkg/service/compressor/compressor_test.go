@@ -6,7 +6,8 @@ import (
 	"os"
 	"testing"
 
-	"github.com/floostack/transcoder/ffmpeg"
+	"github.com/Hargeon/compressrv/pkg/ffmpeg"
+	"github.com/Hargeon/compressrv/pkg/response"
 	"github.com/joho/godotenv"
 	"go.uber.org/zap"
 )
@@ -85,11 +86,15 @@ func TestVideoBitrate(t *testing.T) {
 }
 
 func TestBuildOptions(t *testing.T) {
+	src := &response.Video{ResolutionX: 1280, ResolutionY: 720, RatioX: 16, RatioY: 9}
+
 	cases := []struct {
 		name string
 		opts *Request
+		src  *response.Video
 
 		resolution   string
+		filter       string
 		ration       string
 		bufferSize   int
 		videoBitrate string
@@ -97,7 +102,9 @@ func TestBuildOptions(t *testing.T) {
 		{
 			name:         "With ratio",
 			opts:         &Request{Ratio: "6:4"},
+			src:          src,
 			resolution:   "",
+			filter:       "scale=1280:720,pad=1280:854:(ow-iw)/2:(oh-ih)/2:color=black,setsar=1",
 			ration:       "6:4",
 			bufferSize:   0,
 			videoBitrate: "",
@@ -105,11 +112,30 @@ func TestBuildOptions(t *testing.T) {
 		{
 			name:         "With resolution",
 			opts:         &Request{Resolution: "700:600"},
-			resolution:   "700:600",
+			resolution:   "",
+			filter:       "scale=700:600",
 			ration:       "",
 			bufferSize:   0,
 			videoBitrate: "",
 		},
+		{
+			name:   "Resolution of source orientation",
+			opts:   &Request{Resolution: "640x360"},
+			src:    src,
+			filter: "scale=640:360",
+		},
+		{
+			name:   "Landscape resolution of portrait video",
+			opts:   &Request{Resolution: "1280x720"},
+			src:    &response.Video{ResolutionX: 1080, ResolutionY: 1920, RatioX: 9, RatioY: 16},
+			filter: "scale=406:720,pad=1280:720:(ow-iw)/2:(oh-ih)/2:color=black,setsar=1",
+		},
+		{
+			name:   "Landscape resolution of rotated video",
+			opts:   &Request{Resolution: "1280x720"},
+			src:    &response.Video{ResolutionX: 720, ResolutionY: 1280, RatioX: 9, RatioY: 16, Rotation: 90},
+			filter: "scale=406:720,pad=1280:720:(ow-iw)/2:(oh-ih)/2:color=black,setsar=1",
+		},
 		{
 			name:         "With bitrate",
 			opts:         &Request{Bitrate: 64000},
@@ -125,7 +151,9 @@ func TestBuildOptions(t *testing.T) {
 				Resolution: "400:300",
 				Ratio:      "9:4",
 			},
-			resolution:   "400:300",
+			src:          src,
+			resolution:   "",
+			filter:       "scale=316:178,pad=400:178:(ow-iw)/2:(oh-ih)/2:color=black,setsar=1",
 			ration:       "9:4",
 			bufferSize:   100000,
 			videoBitrate: "100000",
@@ -136,7 +164,15 @@ func TestBuildOptions(t *testing.T) {
 
 	for _, testCase := range cases {
 		t.Run(testCase.name, func(t *testing.T) {
-			opts := srv.buildOptions(testCase.opts)
+			opts, err := srv.buildOptions(testCase.opts, testCase.src)
+			if err != nil {
+				t.Fatalf("Unexpected error: %s\n", err)
+			}
+
+			if filter := opts.VideoFilter; (filter == nil && testCase.filter != "") ||
+				(filter != nil && *filter != testCase.filter) {
+				t.Errorf("Invalid filter, expected: %s, got: %v\n", testCase.filter, filter)
+			}
 
 			if opts.Resolution == nil {
 				if testCase.resolution != "" {
@@ -232,6 +268,7 @@ func TestConvertVideo(t *testing.T) {
 			errorPresent:       true,
 		},
 		{
+			// headers of Matroska input are parsed without ffprobe
 			name: "Only with ffmpeg path for ffmpeg",
 			ffmpegCnf: &ffmpeg.Config{
 				FfmpegBinPath: os.Getenv("FFMPEG_PATH"),
@@ -240,9 +277,9 @@ func TestConvertVideo(t *testing.T) {
 			inputRation:        "4:3",
 			inputBitrate:       "64000",
 			inputBufferSize:    64000,
-			expectedResolution: "",
-			expectedRation:     "",
-			errorPresent:       true,
+			expectedResolution: "800:600",
+			expectedRation:     "4:3",
+			errorPresent:       false,
 		},
 	}
 
@@ -260,7 +297,7 @@ func TestConvertVideo(t *testing.T) {
 			defer clearConvertedVideosDir()
 
 			srv := &Compressor{ffmpegCnf: testCase.ffmpegCnf}
-			err := srv.convertVideo(originPath, newPath, testCase.opts)
+			err := srv.convertVideo(context.Background(), originPath, newPath, testCase.opts)
 			if err == nil && testCase.errorPresent {
 				t.Errorf("Should be error\n")
 			}
@@ -269,18 +306,18 @@ func TestConvertVideo(t *testing.T) {
 			}
 
 			if testCase.expectedRation != "" || testCase.expectedResolution != "" {
-				metaData, err := ffmpeg.New(ffmpegCnf).Input(newPath).GetMetadata()
+				metaData, err := (&Compressor{ffmpegCnf: ffmpegCnf}).probe(newPath)
 				if err != nil {
 					t.Errorf("Unexpected error while check video metadata, error: %s\n", err)
 
 					return
 				}
-				streams := metaData.GetStreams()
-				resolution := fmt.Sprintf("%d:%d", streams[0].GetWidth(), streams[0].GetHeight())
+				streams := metaData.Streams
+				resolution := fmt.Sprintf("%d:%d", streams[0].Width, streams[0].Height)
 				if resolution != testCase.expectedResolution {
 					t.Errorf("Invalid resolution, expected: %s, got: %s\n", testCase.expectedResolution, resolution)
 				}
-				ratio := streams[0].GetDisplayAspectRatio()
+				ratio := streams[0].DisplayAspectRatio
 				if ratio != testCase.expectedRation {
 					t.Errorf("Invalid ration, expected: %s, got: %s\n", testCase.expectedRation, ratio)
 				}
@@ -362,14 +399,16 @@ func TestConvertWithBitrate(t *testing.T) {
 			errorPresent: true,
 		},
 		{
-			name:          "Convert bitrate.mkv to 45000 bit/s with invalid ffrpobe path",
+			// bitrate of Matroska video is read from its headers without ffprobe
+			name:          "Convert bitrate.mkv to 45000 bit/s without ffprobe path",
 			originalVideo: "bitrate.mkv",
 			ffmpegCnf: &ffmpeg.Config{
 				FfmpegBinPath: os.Getenv("FFMPEG_PATH"),
 			},
-			inputBuffer:  45000,
-			filePresent:  false,
-			errorPresent: true,
+			inputBuffer:     45000,
+			expectedBitrate: 45000,
+			filePresent:     true,
+			errorPresent:    false,
 		},
 	}
 
@@ -391,7 +430,7 @@ func TestConvertWithBitrate(t *testing.T) {
 
 			srv := &Compressor{ffmpegCnf: testCase.ffmpegCnf}
 			originVideoPath := fmt.Sprintf("%s%s%s", root, originalVideoPath, testCase.originalVideo)
-			path, err := srv.convertWithBitrate(originVideoPath, testCase.opts)
+			path, err := srv.convertWithBitrate(context.Background(), &Request{}, originVideoPath, testCase.opts)
 			if err != nil && !testCase.errorPresent {
 				t.Errorf("Unexpected error, error: %s\n", err)
 			}
@@ -493,7 +532,7 @@ func TestConvert(t *testing.T) {
 			errorPresent:       false,
 			expectedBitrate:    60000,
 			expectedRatio:      "3:2",
-			expectedResolution: "600:300",
+			expectedResolution: "450:300",
 		},
 	}
 
@@ -534,23 +573,23 @@ func TestConvert(t *testing.T) {
 					}
 				}
 
-				metaData, err := ffmpeg.New(ffmpegCnf).Input(path).GetMetadata()
+				metaData, err := (&Compressor{ffmpegCnf: ffmpegCnf}).probe(path)
 				if err != nil {
 					t.Errorf("Unexpected error while check video metadata, error: %s\n", err)
 
 					return
 				}
-				streams := metaData.GetStreams()
+				streams := metaData.Streams
 
 				if testCase.expectedResolution != "" {
-					resolution := fmt.Sprintf("%d:%d", streams[0].GetWidth(), streams[0].GetHeight())
+					resolution := fmt.Sprintf("%d:%d", streams[0].Width, streams[0].Height)
 					if resolution != testCase.expectedResolution {
 						t.Errorf("Invalid resolution, expected: %s, got: %s\n", testCase.expectedResolution, resolution)
 					}
 				}
 
 				if testCase.expectedRatio != "" {
-					ratio := streams[0].GetDisplayAspectRatio()
+					ratio := streams[0].DisplayAspectRatio
 					if ratio != testCase.expectedRatio {
 						t.Errorf("Invalid ration, expected: %s, got: %s\n", testCase.expectedRatio, ratio)
 					}
@@ -559,3 +598,39 @@ func TestConvert(t *testing.T) {
 		})
 	}
 }
+
+func TestWorkPath(t *testing.T) {
+	root := os.Getenv("ROOT")
+
+	cases := []struct {
+		name      string
+		opt       *Request
+		converted string
+		pkg       string
+	}{
+		{
+			name:      "Job directory",
+			opt:       &Request{WorkDir: "/tmp/job_1"},
+			converted: "/tmp/job_1/converted_video.mkv",
+			pkg:       "/tmp/job_1/video.mkv_hls",
+		},
+		{
+			name:      "Without job directory",
+			opt:       &Request{},
+			converted: root + convertedVideosPath + "/converted_video.mkv",
+			pkg:       root + convertedVideosPath + "/video.mkv_hls",
+		},
+	}
+
+	for _, testCase := range cases {
+		t.Run(testCase.name, func(t *testing.T) {
+			if path := convertedVideoPath(testCase.opt, "/data/video.mkv"); path != testCase.converted {
+				t.Errorf("Invalid converted video path, expected: %s, got: %s\n", testCase.converted, path)
+			}
+
+			if dir := packageDir(testCase.opt, "/data/video.mkv", OutputHLS); dir != testCase.pkg {
+				t.Errorf("Invalid package dir, expected: %s, got: %s\n", testCase.pkg, dir)
+			}
+		})
+	}
+}
@@ -0,0 +1,80 @@
+package compressor
+
+import (
+	"context"
+	"path/filepath"
+	"strconv"
+
+	"github.com/Hargeon/compressrv/pkg/response"
+)
+
+const dashManifest = "manifest.mpd"
+
+// dash encodes all renditions to one MPEG-DASH manifest with fMP4 segments
+func (c *Compressor) dash(ctx context.Context, originalVideo, dir string,
+	renditions []rendition, segmentDuration int, trim []string) (*response.Package, error) {
+	audio, err := c.hasAudio(originalVideo)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err = c.run(ctx, dashArgs(originalVideo, dir, renditions, segmentDuration, trim, audio)...); err != nil {
+		return nil, err
+	}
+
+	pkg := &response.Package{Format: OutputDASH, Manifest: dashManifest}
+
+	for i, r := range renditions {
+		pkg.Variants = append(pkg.Variants, response.Variant{
+			ID:          strconv.Itoa(i),
+			Bitrate:     r.Bitrate,
+			Bandwidth:   r.peakBitrate(),
+			ResolutionX: r.width,
+			ResolutionY: r.height,
+			Codecs:      r.codecs(audio),
+		})
+	}
+
+	return pkg, nil
+}
+
+// dashArgs returns ffmpeg options for encoding renditions to MPEG-DASH in one pass.
+// Each rendition is a separate representation of video adaptation set,
+// audio is encoded once with the highest audio bitrate of renditions.
+func dashArgs(originalVideo, dir string, renditions []rendition, segmentDuration int,
+	trim []string, audio bool) []string {
+	args := append([]string{"-i", originalVideo}, trim...)
+
+	for range renditions {
+		args = append(args, "-map", "0:v:0")
+	}
+
+	var audioBitrate int64
+
+	for i, r := range renditions {
+		args = append(args, r.videoArgs(":"+strconv.Itoa(i))...)
+
+		if r.AudioBitrate > audioBitrate {
+			audioBitrate = r.AudioBitrate
+		}
+	}
+
+	args = append(args, encodeArgs(segmentDuration)...)
+	adaptationSets := "id=0,streams=v"
+
+	if audio {
+		args = append(args, "-map", "0:a:0")
+		args = append(args, audioArgs(audioBitrate)...)
+		adaptationSets += " id=1,streams=a"
+	}
+
+	return append(args,
+		"-f", "dash",
+		"-seg_duration", strconv.Itoa(segmentDuration),
+		"-use_template", "1",
+		"-use_timeline", "1",
+		"-init_seg_name", "init_$RepresentationID$.m4s",
+		"-media_seg_name", "chunk_$RepresentationID$_$Number%05d$.m4s",
+		"-adaptation_sets", adaptationSets,
+		filepath.Join(dir, dashManifest))
+}
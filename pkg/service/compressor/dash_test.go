@@ -0,0 +1,75 @@
+package compressor
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDashArgs(t *testing.T) {
+	renditions := withLevels(buildRenditions([]Variant{
+		{Height: 720, Bitrate: 2800000, AudioBitrate: 128000},
+		{Height: 360, Bitrate: 800000, AudioBitrate: 96000},
+	}, 1280, 720), 30)
+
+	video := []string{
+		"-i", "/videos/in.mkv",
+		"-ss", "5",
+		"-map", "0:v:0",
+		"-map", "0:v:0",
+		"-filter:v:0", "scale=1280:720",
+		"-b:v:0", "2800000",
+		"-maxrate:v:0", "2996000",
+		"-bufsize:v:0", "4200000",
+		"-level:v:0", "3.1",
+		"-filter:v:1", "scale=640:360",
+		"-b:v:1", "800000",
+		"-maxrate:v:1", "856000",
+		"-bufsize:v:1", "1200000",
+		"-level:v:1", "3.0",
+		"-c:v", "libx264",
+		"-profile:v", "high",
+		"-pix_fmt", "yuv420p",
+		"-force_key_frames", "expr:gte(t,n_forced*4)",
+		"-sc_threshold", "0",
+		"-metadata:s:v", "rotate=0",
+	}
+
+	muxer := func(adaptationSets string) []string {
+		return []string{
+			"-f", "dash",
+			"-seg_duration", "4",
+			"-use_template", "1",
+			"-use_timeline", "1",
+			"-init_seg_name", "init_$RepresentationID$.m4s",
+			"-media_seg_name", "chunk_$RepresentationID$_$Number%05d$.m4s",
+			"-adaptation_sets", adaptationSets,
+			"/out/manifest.mpd",
+		}
+	}
+
+	cases := []struct {
+		name     string
+		audio    bool
+		expected []string
+	}{
+		{
+			name:     "With audio",
+			audio:    true,
+			expected: append(append(append([]string{}, video...), "-map", "0:a:0", "-c:a", "aac", "-b:a", "128000", "-ac", "2"), muxer("id=0,streams=v id=1,streams=a")...),
+		},
+		{
+			name:     "Without audio",
+			audio:    false,
+			expected: append(append([]string{}, video...), muxer("id=0,streams=v")...),
+		},
+	}
+
+	for _, testCase := range cases {
+		t.Run(testCase.name, func(t *testing.T) {
+			args := dashArgs("/videos/in.mkv", "/out", renditions, 4, []string{"-ss", "5"}, testCase.audio)
+			if !reflect.DeepEqual(args, testCase.expected) {
+				t.Errorf("Invalid args, expected: %v, got: %v\n", testCase.expected, args)
+			}
+		})
+	}
+}
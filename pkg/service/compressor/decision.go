@@ -0,0 +1,150 @@
+package compressor
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Hargeon/compressrv/pkg/response"
+)
+
+// Decisions about original video
+const (
+	// DecisionConvert encodes original video
+	DecisionConvert = "convert"
+	// DecisionRemux copies streams of original video to new file, only container is changed
+	DecisionRemux = "remux"
+	// DecisionReuse uses original video as converted one
+	DecisionReuse = "reuse"
+)
+
+// outputCodecs are video codecs which ffmpeg encodes by default to containers with these extensions
+var outputCodecs = map[string]string{
+	".mp4":  "h264",
+	".m4v":  "h264",
+	".mov":  "h264",
+	".mkv":  "h264",
+	".webm": "vp9",
+}
+
+// Decide compares originalVideo with target of opt and decides if it's converted, remuxed or reused as it is.
+// Video is converted when any option changes its content or video doesn't meet bitrate, size, frame and codec
+func (c *Compressor) Decide(opt *Request, originalVideo string) (string, error) {
+	if opt.transforms() {
+		return DecisionConvert, nil
+	}
+
+	src, err := c.VideoInfo(originalVideo)
+	if err != nil {
+		return "", err
+	}
+
+	stat, err := os.Stat(originalVideo)
+	if err != nil {
+		return "", err
+	}
+
+	ext := strings.ToLower(filepath.Ext(originalVideo))
+
+	if !meetsTarget(opt, src, ext, stat.Size()) {
+		return DecisionConvert, nil
+	}
+
+	// converted video has the first audio stream and subtitles kept by request
+	if len(src.Audio) > 1 || (len(src.Subtitles) != 0 && opt.subtitleMode() != SubtitleKeep) {
+		return DecisionRemux, nil
+	}
+
+	if flags := movFlags(opt, ext); flags != "" {
+		if opt.Fragmented {
+			return DecisionRemux, nil
+		}
+
+		faststart, err := c.faststart(originalVideo)
+		if err != nil || !faststart {
+			return DecisionRemux, nil
+		}
+	}
+
+	return DecisionReuse, nil
+}
+
+// Remux copies video, the first audio stream and kept subtitles of originalVideo to new file of the same container
+func (c *Compressor) Remux(ctx context.Context, opt *Request, originalVideo string) (string, error) {
+	newVideoPath := convertedVideoPath(opt, originalVideo)
+
+	args := []string{"-map", "0:V:0", "-map", "0:a:0?"}
+	if opt.subtitleMode() == SubtitleKeep {
+		args = append(args, "-map", "0:s?")
+	} else {
+		args = append(args, "-sn")
+	}
+
+	args = append(args, "-c", "copy")
+
+	if flags := movFlags(opt, filepath.Ext(originalVideo)); flags != "" {
+		args = append(args, "-movflags", flags)
+	}
+
+	_, err := c.ffmpegCnf.Ffmpeg().
+		Input(originalVideo).
+		Args(args...).
+		Output(newVideoPath).
+		Run(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	return newVideoPath, nil
+}
+
+// faststart reports if moov box of MP4 file at path precedes media data
+func (c *Compressor) faststart(path string) (bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	stat, err := f.Stat()
+	if err != nil {
+		return false, err
+	}
+
+	return moovFirst(f, stat.Size())
+}
+
+// transforms reports if request changes content of video, so it can't be met by original video
+func (r *Request) transforms() bool {
+	return r.Adaptive() || r.Fit != "" || r.KeyframeInterval != 0 || r.SceneCut != nil || r.trimmed() ||
+		r.Watermark != nil || r.subtitleMode() == SubtitleBurn || r.Loudness != nil || r.AutoBitrate != nil ||
+		r.Deinterlace || r.Denoise != "" || r.Deshake || r.AutoCrop
+}
+
+// meetsTarget reports if src of size bytes in container with ext extension has codec, bitrate, size,
+// frame, aspect ratio and frame rate requested by opt
+func meetsTarget(opt *Request, src *response.Video, ext string, size int64) bool {
+	if codec, ok := outputCodecs[ext]; !ok || src.Codec != codec || src.Rotation != 0 {
+		return false
+	}
+
+	if (opt.Bitrate != 0 && src.Bitrate > opt.Bitrate) || (opt.MaxSizeBytes != 0 && size > opt.MaxSizeBytes) {
+		return false
+	}
+
+	if fps := targetFPS(opt, src); fps != 0 && fps != src.FPS {
+		return false
+	}
+
+	if opt.Ratio != "" {
+		x, y, err := parseRatio(opt.Ratio)
+		if err != nil || x*src.RatioY != y*src.RatioX {
+			return false
+		}
+	}
+
+	w, h, _, err := outputFrame(opt, src)
+
+	return err == nil && w == src.ResolutionX && h == src.ResolutionY
+}
@@ -0,0 +1,111 @@
+package compressor
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Hargeon/compressrv/pkg/response"
+)
+
+func TestMeetsTarget(t *testing.T) {
+	src := &response.Video{
+		Bitrate:     2000000,
+		ResolutionX: 1280,
+		ResolutionY: 720,
+		RatioX:      16,
+		RatioY:      9,
+		FPS:         30,
+		Codec:       "h264",
+	}
+
+	cases := []struct {
+		name     string
+		opt      *Request
+		src      *response.Video
+		ext      string
+		size     int64
+		expected bool
+	}{
+		{name: "Empty request", opt: &Request{}, src: src, ext: ".mp4", expected: true},
+		{name: "Lower bitrate", opt: &Request{Bitrate: 2500000}, src: src, ext: ".mkv", expected: true},
+		{name: "Higher bitrate", opt: &Request{Bitrate: 1000000}, src: src, ext: ".mp4", expected: false},
+		{name: "Same resolution", opt: &Request{Resolution: "1280:720"}, src: src, ext: ".mp4", expected: true},
+		{name: "Other resolution", opt: &Request{Resolution: "640:360"}, src: src, ext: ".mp4", expected: false},
+		{name: "Max box", opt: &Request{Resolution: "max:1920:1080"}, src: src, ext: ".mp4", expected: true},
+		{name: "Same ratio", opt: &Request{Ratio: "16:9"}, src: src, ext: ".mp4", expected: true},
+		{name: "Other ratio", opt: &Request{Ratio: "4:3"}, src: src, ext: ".mp4", expected: false},
+		{name: "Lower frame rate", opt: &Request{MaxFPS: 60}, src: src, ext: ".mp4", expected: true},
+		{name: "Higher frame rate", opt: &Request{MaxFPS: 24}, src: src, ext: ".mp4", expected: false},
+		{name: "Small file", opt: &Request{MaxSizeBytes: 1000}, src: src, ext: ".mp4", size: 1000, expected: true},
+		{name: "Large file", opt: &Request{MaxSizeBytes: 1000}, src: src, ext: ".mp4", size: 1001, expected: false},
+		{name: "Other codec", opt: &Request{}, src: src, ext: ".webm", expected: false},
+		{name: "Unknown container", opt: &Request{}, src: src, ext: ".avi", expected: false},
+		{
+			name:     "Rotated video",
+			opt:      &Request{},
+			src:      &response.Video{ResolutionX: 720, ResolutionY: 1280, Codec: "h264", Rotation: 90},
+			ext:      ".mp4",
+			expected: false,
+		},
+	}
+
+	for _, testCase := range cases {
+		t.Run(testCase.name, func(t *testing.T) {
+			if got := meetsTarget(testCase.opt, testCase.src, testCase.ext, testCase.size); got != testCase.expected {
+				t.Errorf("Invalid result, expected: %v, got: %v\n", testCase.expected, got)
+			}
+		})
+	}
+}
+
+func TestDecide(t *testing.T) {
+	dir := t.TempDir()
+	faststart := testMP4()
+	// moov is moved after mdat like ffmpeg writes it without faststart
+	moovAt := bytes.Index(faststart, []byte("moov")) - 4
+	mdatAt := bytes.Index(faststart, []byte("mdat")) - 4
+	ftyp, mdat, moov := faststart[:mdatAt], faststart[mdatAt:moovAt], faststart[moovAt:]
+	faststart = bytes.Join([][]byte{ftyp, moov, mdat}, nil)
+	slow := bytes.Join([][]byte{ftyp, mdat, moov}, nil)
+
+	disabled := false
+
+	cases := []struct {
+		name     string
+		opt      *Request
+		data     []byte
+		expected string
+	}{
+		{name: "Faststart MP4", opt: &Request{}, data: faststart, expected: DecisionReuse},
+		{name: "MP4 without faststart", opt: &Request{}, data: slow, expected: DecisionRemux},
+		{name: "Disabled faststart", opt: &Request{FastStart: &disabled}, data: slow, expected: DecisionReuse},
+		{name: "Fragmented MP4", opt: &Request{Fragmented: true}, data: faststart, expected: DecisionRemux},
+		{name: "Larger frame", opt: &Request{Resolution: "640:360"}, data: faststart, expected: DecisionConvert},
+		{name: "Watermark", opt: &Request{Watermark: &Watermark{}}, data: faststart, expected: DecisionConvert},
+		{name: "Clip", opt: &Request{Start: 2}, data: faststart, expected: DecisionConvert},
+	}
+
+	for i, testCase := range cases {
+		t.Run(testCase.name, func(t *testing.T) {
+			path := filepath.Join(dir, fmt.Sprintf("video_%d.mp4", i))
+			if err := os.WriteFile(path, testCase.data, 0600); err != nil {
+				t.Fatalf("Unexpected error: %s\n", err)
+			}
+
+			// header of test file is read natively, so ffprobe isn't required
+			srv := NewCompressor("", "")
+
+			decision, err := srv.Decide(testCase.opt, path)
+			if err != nil {
+				t.Fatalf("Unexpected error: %s\n", err)
+			}
+
+			if decision != testCase.expected {
+				t.Errorf("Invalid decision, expected: %s, got: %s\n", testCase.expected, decision)
+			}
+		})
+	}
+}
@@ -0,0 +1,9 @@
+package compressor
+
+import "context"
+
+// run executes ffmpeg with args and returns its stderr output.
+// Failed command returns *ffmpeg.Error with the command line, category and tail of stderr
+func (c *Compressor) run(ctx context.Context, args ...string) (string, error) {
+	return c.ffmpegCnf.Ffmpeg(args...).Run(ctx)
+}
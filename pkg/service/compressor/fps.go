@@ -0,0 +1,110 @@
+package compressor
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/Hargeon/compressrv/pkg/response"
+)
+
+const (
+	fpsPrecision = 1000
+	// ntscDenominator of NTSC frame rates like 30000/1001
+	ntscDenominator = 1001
+	// ntscTolerance of frame rate rounded to fpsPrecision from NTSC rate
+	ntscTolerance = 0.005
+)
+
+// Modes of frame rate conversion
+const (
+	// FPSModeDrop drops or duplicates frames
+	FPSModeDrop = "drop"
+	// FPSModeBlend blends neighbour frames, motion looks smoother but frames may be ghosted
+	FPSModeBlend = "blend"
+)
+
+// targetFPS returns frame rate of converted video, zero means frame rate isn't changed.
+// src is an original video info, it's required for opt.MaxFPS
+func targetFPS(opt *Request, src *response.Video) float64 {
+	target := opt.FPS
+
+	if opt.MaxFPS != 0 {
+		current := target
+		if current == 0 && src != nil {
+			current = src.FPS
+		}
+
+		if current > opt.MaxFPS {
+			target = opt.MaxFPS
+		}
+	}
+
+	return target
+}
+
+// fpsFilter returns filter which converts frame rate to fps with opt.FPSMode
+func fpsFilter(opt *Request, fps float64) string {
+	if opt.FPSMode == FPSModeBlend {
+		return "framerate=fps=" + formatFrameRate(fps)
+	}
+
+	return "fps=" + formatFrameRate(fps)
+}
+
+// formatFrameRate formats fps for ffmpeg, rounded NTSC rates like 29.97 are formatted as exact rationals
+func formatFrameRate(fps float64) string {
+	if fps == math.Trunc(fps) {
+		return formatNumber(fps)
+	}
+
+	ntsc := math.Round(fps * ntscDenominator / fpsPrecision)
+	if math.Abs(ntsc*fpsPrecision/ntscDenominator-fps) < ntscTolerance {
+		return fmt.Sprintf("%d/%d", int64(ntsc)*fpsPrecision, ntscDenominator)
+	}
+
+	return formatNumber(fps)
+}
+
+// gopSize returns number of frames between keyframes for opt.KeyframeInterval seconds,
+// it's zero when frame rate of video is unknown, so encoder places keyframes by itself
+func gopSize(opt *Request, src *response.Video) int {
+	fps := targetFPS(opt, src)
+	if fps == 0 && src != nil {
+		fps = src.FPS
+	}
+
+	if fps == 0 {
+		return 0
+	}
+
+	gop := int(math.Round(fps * opt.KeyframeInterval))
+	if gop < 1 {
+		return 1
+	}
+
+	return gop
+}
+
+// parseFrameRate parses frame rate of ffprobe like 30000/1001
+func parseFrameRate(rate string) float64 {
+	parts := strings.Split(rate, "/")
+
+	num, err := strconv.ParseFloat(parts[0], bitrateBitSize)
+	if err != nil {
+		return 0
+	}
+
+	if len(parts) == 1 {
+		return num
+	}
+
+	den, err := strconv.ParseFloat(parts[1], bitrateBitSize)
+	if err != nil || den == 0 {
+		return 0
+	}
+
+	// 30000/1001 is reported as 29.97
+	return math.Round(num/den*fpsPrecision) / fpsPrecision
+}
@@ -0,0 +1,133 @@
+package compressor
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/Hargeon/compressrv/pkg/response"
+)
+
+func TestParseFrameRate(t *testing.T) {
+	cases := []struct {
+		rate     string
+		expected float64
+	}{
+		{rate: "30/1", expected: 30},
+		{rate: "30000/1001", expected: 29.97},
+		{rate: "25", expected: 25},
+		{rate: "0/0", expected: 0},
+		{rate: "N/A", expected: 0},
+	}
+
+	for _, testCase := range cases {
+		t.Run(testCase.rate, func(t *testing.T) {
+			if fps := parseFrameRate(testCase.rate); fps != testCase.expected {
+				t.Errorf("Invalid frame rate, expected: %v, got: %v\n", testCase.expected, fps)
+			}
+		})
+	}
+}
+
+func TestBuildOptionsFPS(t *testing.T) {
+	disabled := false
+	src := &response.Video{ResolutionX: 1920, ResolutionY: 1080, FPS: 60}
+
+	cases := []struct {
+		name string
+		opt  *Request
+		src  *response.Video
+
+		expectedFilter string
+		expectedGOP    int
+		expectedExtra  []string
+	}{
+		{
+			name:           "Target frame rate",
+			opt:            &Request{FPS: 24},
+			expectedFilter: "fps=24",
+		},
+		{
+			name:           "Blend to target frame rate",
+			opt:            &Request{FPS: 25, FPSMode: FPSModeBlend},
+			expectedFilter: "framerate=fps=25",
+		},
+		{
+			name:           "Max frame rate of 60 fps video",
+			opt:            &Request{MaxFPS: 30},
+			src:            src,
+			expectedFilter: "fps=30",
+		},
+		{
+			name: "Max frame rate of 24 fps video",
+			opt:  &Request{MaxFPS: 30},
+			src:  &response.Video{ResolutionX: 1920, ResolutionY: 1080, FPS: 24},
+		},
+		{
+			name:           "Keyframe interval with capped frame rate",
+			opt:            &Request{MaxFPS: 30, KeyframeInterval: 2, SceneCut: &disabled},
+			src:            src,
+			expectedFilter: "fps=30",
+			expectedGOP:    60,
+			expectedExtra:  []string{"-sc_threshold", "0"},
+		},
+		{
+			name:           "NTSC frame rate",
+			opt:            &Request{FPS: 29.97},
+			expectedFilter: "fps=30000/1001",
+		},
+		{
+			name:           "Blend to NTSC film frame rate",
+			opt:            &Request{FPS: 23.976, FPSMode: FPSModeBlend},
+			expectedFilter: "framerate=fps=24000/1001",
+		},
+		{
+			name:           "Fractional frame rate",
+			opt:            &Request{FPS: 12.5},
+			expectedFilter: "fps=12.5",
+		},
+		{
+			name: "Keyframe interval with unknown frame rate",
+			opt:  &Request{KeyframeInterval: 2},
+			src:  &response.Video{ResolutionX: 1920, ResolutionY: 1080},
+		},
+		{
+			name:        "Keyframe interval with original frame rate",
+			opt:         &Request{KeyframeInterval: 0.5},
+			src:         src,
+			expectedGOP: 30,
+		},
+	}
+
+	srv := NewCompressor("", "")
+
+	for _, testCase := range cases {
+		t.Run(testCase.name, func(t *testing.T) {
+			opts, err := srv.buildOptions(testCase.opt, testCase.src)
+			if err != nil {
+				t.Fatalf("Unexpected error: %s\n", err)
+			}
+
+			filter := ""
+			if opts.VideoFilter != nil {
+				filter = *opts.VideoFilter
+			}
+
+			if filter != testCase.expectedFilter {
+				t.Errorf("Invalid video filter, expected: %s, got: %s\n", testCase.expectedFilter, filter)
+			}
+
+			gop := 0
+			if opts.KeyframeInterval != nil {
+				gop = *opts.KeyframeInterval
+			}
+
+			if gop != testCase.expectedGOP {
+				t.Errorf("Invalid keyframe interval, expected: %d, got: %d\n", testCase.expectedGOP, gop)
+			}
+
+			if extra := extraArgs(testCase.opt); !reflect.DeepEqual(extra, testCase.expectedExtra) {
+				t.Errorf("Invalid extra args, expected: %v, got: %v\n", testCase.expectedExtra, extra)
+			}
+		})
+	}
+}
@@ -0,0 +1,299 @@
+package compressor
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+
+	"github.com/Hargeon/compressrv/pkg/response"
+)
+
+// Fit modes of aspect ratio conversion
+const (
+	FitPad     = "pad"
+	FitCrop    = "crop"
+	FitStretch = "stretch"
+)
+
+const defaultPadColor = "black"
+
+// autoDimension in resolution spec is calculated from aspect ratio of video
+const autoDimension = -1
+
+var (
+	ratioRe          = regexp.MustCompile(`^([0-9]+)[:/]([0-9]+)$`)
+	resolutionRe     = regexp.MustCompile(`^([0-9]+)[x:]([0-9]+)$`)
+	resolutionSpecRe = regexp.MustCompile(`^(max:)?(-1|[0-9]+)[x:](-1|[0-9]+)$`)
+	shortSideRe      = regexp.MustCompile(`^([0-9]+)p$`)
+	colorRe          = regexp.MustCompile(`^([a-zA-Z]+|(#|0x)[0-9a-fA-F]{6}([0-9a-fA-F]{2})?)$`)
+)
+
+// parseRatio parses aspect ratio like 16:9
+func parseRatio(ratio string) (int, int, error) {
+	return parsePair(ratioRe, ratio, "ratio")
+}
+
+// parseResolution parses resolution like 1280x720 or 1280:720
+func parseResolution(resolution string) (int, int, error) {
+	return parsePair(resolutionRe, resolution, "resolution")
+}
+
+// validateResolution checks resolution spec:
+//   - 1280x720 or 1280:720 is an exact frame size, video of other orientation is padded to it;
+//   - 720p is a size of the short side, the long one keeps aspect ratio;
+//   - 1280x-1 or -1x720 sets one side, the other one keeps aspect ratio;
+//   - max:1280x720, max:1280x-1 or max:-1x720 is a box which frame fits into keeping aspect ratio.
+func validateResolution(spec string) error {
+	if m := shortSideRe.FindStringSubmatch(spec); m != nil {
+		if m[1] == "0" {
+			return fmt.Errorf("invalid resolution %q", spec)
+		}
+
+		return nil
+	}
+
+	m := resolutionSpecRe.FindStringSubmatch(spec)
+	if m == nil || m[2] == "0" || m[3] == "0" || (m[2] == "-1" && m[3] == "-1") {
+		return fmt.Errorf("invalid resolution %q", spec)
+	}
+
+	return nil
+}
+
+// exactResolution reports if resolution spec is an exact frame size
+func exactResolution(spec string) bool {
+	_, _, err := parseResolution(spec)
+
+	return err == nil
+}
+
+// resolveResolution calculates frame size by resolution spec for video with width x height frame.
+// Frame is reduced to the size of video when noUpscale is set, size is rounded to even numbers
+func resolveResolution(spec string, width, height int, noUpscale bool) (int, int, error) {
+	if err := validateResolution(spec); err != nil {
+		return 0, 0, err
+	}
+
+	srcW, srcH := float64(width), float64(height)
+	ratio := srcW / srcH
+
+	var w, h float64
+
+	if m := shortSideRe.FindStringSubmatch(spec); m != nil {
+		side, err := strconv.ParseFloat(m[1], bitrateBitSize)
+		if err != nil {
+			return 0, 0, err
+		}
+
+		if width >= height {
+			w, h = side*ratio, side
+		} else {
+			w, h = side, side/ratio
+		}
+	} else {
+		m := resolutionSpecRe.FindStringSubmatch(spec)
+		boxW, _ := strconv.ParseFloat(m[2], bitrateBitSize)
+		boxH, _ := strconv.ParseFloat(m[3], bitrateBitSize)
+
+		switch {
+		case m[1] != "":
+			// max box never upscales video
+			noUpscale = true
+
+			if boxW == autoDimension {
+				boxW = boxH * ratio
+			}
+
+			if boxH == autoDimension {
+				boxH = boxW / ratio
+			}
+
+			w, h = inside(boxW, boxH, ratio)
+		case boxW == autoDimension:
+			w, h = boxH*ratio, boxH
+		case boxH == autoDimension:
+			w, h = boxW, boxW/ratio
+		default:
+			w, h = boxW, boxH
+		}
+	}
+
+	if noUpscale && (w > srcW || h > srcH) {
+		scale := srcW / w
+		if srcH/h < scale {
+			scale = srcH / h
+		}
+
+		w, h = w*scale, h*scale
+	}
+
+	return evenDimension(w), evenDimension(h), nil
+}
+
+// parsePair parses two positive numbers of s matched by re
+func parsePair(re *regexp.Regexp, s, name string) (int, int, error) {
+	m := re.FindStringSubmatch(s)
+	if m == nil {
+		return 0, 0, fmt.Errorf("invalid %s %q", name, s)
+	}
+
+	x, err := strconv.Atoi(m[1])
+	if err != nil {
+		return 0, 0, err
+	}
+
+	y, err := strconv.Atoi(m[2])
+	if err != nil {
+		return 0, 0, err
+	}
+
+	if x == 0 || y == 0 {
+		return 0, 0, fmt.Errorf("invalid %s %q", name, s)
+	}
+
+	return x, y, nil
+}
+
+// displaySize returns size of frame as it's shown to viewer, it differs from stored size for anamorphic video
+func displaySize(src *response.Video) (int, int) {
+	if src.RatioX == 0 || src.RatioY == 0 {
+		return src.ResolutionX, src.ResolutionY
+	}
+
+	return evenDimension(float64(src.ResolutionY) * float64(src.RatioX) / float64(src.RatioY)), src.ResolutionY
+}
+
+// fitFilters returns filters which convert frame of src to opt.Ratio with fit mode of opt.
+// Frame fits into opt.Resolution when it's set, otherwise frame keeps the size of src along one side
+func fitFilters(opt *Request, src *response.Video) ([]string, error) {
+	w, h, err := fitFrame(opt, src)
+	if err != nil {
+		return nil, err
+	}
+
+	srcW, srcH := displaySize(src)
+	srcRatio := float64(srcW) / float64(srcH)
+
+	switch opt.fitMode() {
+	case FitPad:
+		scaledW, scaledH := inside(float64(w), float64(h), srcRatio)
+		color := opt.PadColor
+		if color == "" {
+			color = defaultPadColor
+		}
+
+		return []string{
+			fmt.Sprintf("scale=%d:%d", evenDimension(scaledW), evenDimension(scaledH)),
+			fmt.Sprintf("pad=%d:%d:(ow-iw)/2:(oh-ih)/2:color=%s", w, h, color),
+			"setsar=1",
+		}, nil
+	case FitCrop:
+		scaledW, scaledH := around(float64(w), float64(h), srcRatio)
+
+		return []string{
+			fmt.Sprintf("scale=%d:%d", evenDimension(scaledW), evenDimension(scaledH)),
+			fmt.Sprintf("crop=%d:%d", w, h),
+			"setsar=1",
+		}, nil
+	default:
+		return []string{fmt.Sprintf("scale=%d:%d", w, h), "setsar=1"}, nil
+	}
+}
+
+// fitFrame returns size of frame of src converted to opt.Ratio with fit mode of opt
+func fitFrame(opt *Request, src *response.Video) (int, int, error) {
+	rx, ry, err := parseRatio(opt.Ratio)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	ratio := float64(rx) / float64(ry)
+	srcW, srcH := displaySize(src)
+
+	var frameW, frameH float64
+
+	switch {
+	case opt.Resolution != "":
+		boxW, boxH, err := resolveResolution(opt.Resolution, srcW, srcH, opt.NoUpscale)
+		if err != nil {
+			return 0, 0, err
+		}
+
+		frameW, frameH = inside(float64(boxW), float64(boxH), ratio)
+	case opt.fitMode() == FitCrop:
+		frameW, frameH = inside(float64(srcW), float64(srcH), ratio)
+	case opt.fitMode() == FitPad:
+		frameW, frameH = around(float64(srcW), float64(srcH), ratio)
+	default:
+		frameW, frameH = float64(srcH)*ratio, float64(srcH)
+	}
+
+	return evenDimension(frameW), evenDimension(frameH), nil
+}
+
+// resolutionFilters returns filters which convert frame of src to opt.Resolution. Exact frame size of other
+// orientation than src (e.g. 1280x720 for portrait video) is padded, so video isn't squashed
+func resolutionFilters(opt *Request, src *response.Video) ([]string, error) {
+	if w, h, err := parseResolution(opt.Resolution); err == nil && src != nil {
+		srcW, srcH := displaySize(src)
+
+		if (w > h) != (srcW > srcH) && w != h && srcW != srcH {
+			padded := *opt
+			padded.Ratio, padded.Fit = fmt.Sprintf("%d:%d", w, h), FitPad
+
+			return fitFilters(&padded, src)
+		}
+	}
+
+	scale, err := scaleFilter(opt, src)
+	if err != nil {
+		return nil, err
+	}
+
+	return []string{scale}, nil
+}
+
+// scaleFilter returns filter which scales frame of src to opt.Resolution.
+// src is required for all resolution specs except of exact frame size without opt.NoUpscale
+func scaleFilter(opt *Request, src *response.Video) (string, error) {
+	if src == nil {
+		if opt.NoUpscale || !exactResolution(opt.Resolution) {
+			return "", errors.New("original video info is required for resolution")
+		}
+
+		w, h, err := parseResolution(opt.Resolution)
+		if err != nil {
+			return "", err
+		}
+
+		return fmt.Sprintf("scale=%d:%d", w, h), nil
+	}
+
+	srcW, srcH := displaySize(src)
+
+	w, h, err := resolveResolution(opt.Resolution, srcW, srcH, opt.NoUpscale)
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("scale=%d:%d", w, h), nil
+}
+
+// inside returns the largest frame with ratio which fits into width x height frame
+func inside(width, height, ratio float64) (float64, float64) {
+	if width/height > ratio {
+		return height * ratio, height
+	}
+
+	return width, width / ratio
+}
+
+// around returns the smallest frame with ratio which contains width x height frame
+func around(width, height, ratio float64) (float64, float64) {
+	if width/height > ratio {
+		return width, width / ratio
+	}
+
+	return height * ratio, height
+}
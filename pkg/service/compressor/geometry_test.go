@@ -0,0 +1,173 @@
+package compressor
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/Hargeon/compressrv/pkg/response"
+)
+
+func TestFitFilters(t *testing.T) {
+	fullHD := &response.Video{ResolutionX: 1920, ResolutionY: 1080, RatioX: 16, RatioY: 9}
+
+	cases := []struct {
+		name     string
+		opt      *Request
+		src      *response.Video
+		expected []string
+	}{
+		{
+			name:     "Pad 16:9 to 9:16",
+			opt:      &Request{Ratio: "9:16", Fit: FitPad},
+			src:      fullHD,
+			expected: []string{"scale=1920:1080", "pad=1920:3414:(ow-iw)/2:(oh-ih)/2:color=black", "setsar=1"},
+		},
+		{
+			name:     "Pad 16:9 to 4:3 into resolution with color",
+			opt:      &Request{Ratio: "4:3", Fit: FitPad, Resolution: "800x600", PadColor: "#FFFFFF"},
+			src:      fullHD,
+			expected: []string{"scale=800:450", "pad=800:600:(ow-iw)/2:(oh-ih)/2:color=#FFFFFF", "setsar=1"},
+		},
+		{
+			name:     "Crop 16:9 to 9:16",
+			opt:      &Request{Ratio: "9:16", Fit: FitCrop},
+			src:      fullHD,
+			expected: []string{"scale=1920:1080", "crop=608:1080", "setsar=1"},
+		},
+		{
+			name:     "Crop 16:9 to 1:1 into resolution",
+			opt:      &Request{Ratio: "1:1", Fit: FitCrop, Resolution: "720:1280"},
+			src:      fullHD,
+			expected: []string{"scale=1280:720", "crop=720:720", "setsar=1"},
+		},
+		{
+			name:     "Stretch 16:9 to 4:3",
+			opt:      &Request{Ratio: "4:3", Fit: FitStretch},
+			src:      fullHD,
+			expected: []string{"scale=1440:1080", "setsar=1"},
+		},
+		{
+			name:     "Pad anamorphic video",
+			opt:      &Request{Ratio: "16:9", Fit: FitPad},
+			src:      &response.Video{ResolutionX: 720, ResolutionY: 576, RatioX: 4, RatioY: 3},
+			expected: []string{"scale=768:576", "pad=1024:576:(ow-iw)/2:(oh-ih)/2:color=black", "setsar=1"},
+		},
+	}
+
+	for _, testCase := range cases {
+		t.Run(testCase.name, func(t *testing.T) {
+			filters, err := fitFilters(testCase.opt, testCase.src)
+			if err != nil {
+				t.Fatalf("Unexpected error: %s\n", err)
+			}
+
+			if !reflect.DeepEqual(filters, testCase.expected) {
+				t.Errorf("Invalid filters, expected: %v, got: %v\n", testCase.expected, filters)
+			}
+		})
+	}
+}
+
+func TestParseResolution(t *testing.T) {
+	cases := []struct {
+		resolution   string
+		width        int
+		height       int
+		errorPresent bool
+	}{
+		{resolution: "1280x720", width: 1280, height: 720},
+		{resolution: "800:600", width: 800, height: 600},
+		{resolution: "0x600", errorPresent: true},
+		{resolution: "720p", errorPresent: true},
+	}
+
+	for _, testCase := range cases {
+		t.Run(testCase.resolution, func(t *testing.T) {
+			w, h, err := parseResolution(testCase.resolution)
+			if err != nil && !testCase.errorPresent {
+				t.Errorf("Unexpected error: %s\n", err)
+			}
+
+			if err == nil && testCase.errorPresent {
+				t.Errorf("Should be error\n")
+			}
+
+			if w != testCase.width || h != testCase.height {
+				t.Errorf("Invalid resolution, expected: %dx%d, got: %dx%d\n", testCase.width, testCase.height, w, h)
+			}
+		})
+	}
+}
+
+func TestResolveResolution(t *testing.T) {
+	cases := []struct {
+		name      string
+		spec      string
+		width     int
+		height    int
+		noUpscale bool
+
+		expectedWidth  int
+		expectedHeight int
+		errorPresent   bool
+	}{
+		{name: "Exact size", spec: "800x600", width: 1920, height: 1080, expectedWidth: 800, expectedHeight: 600},
+		{name: "Short side of landscape", spec: "720p", width: 1920, height: 1080, expectedWidth: 1280, expectedHeight: 720},
+		{name: "Short side of portrait", spec: "720p", width: 1080, height: 1920, expectedWidth: 720, expectedHeight: 1280},
+		{name: "Auto height", spec: "1280x-1", width: 1920, height: 800, expectedWidth: 1280, expectedHeight: 534},
+		{name: "Auto width", spec: "-1:480", width: 1280, height: 720, expectedWidth: 854, expectedHeight: 480},
+		{name: "Max width of portrait", spec: "max:1280x-1", width: 1080, height: 1920, expectedWidth: 1080, expectedHeight: 1920},
+		{name: "Max box", spec: "max:1280x720", width: 1080, height: 1920, expectedWidth: 406, expectedHeight: 720},
+		{name: "Max box of small video", spec: "max:1280x720", width: 640, height: 360, expectedWidth: 640, expectedHeight: 360},
+		{
+			name: "Exact size without upscale", spec: "1920x1080", width: 1280, height: 720, noUpscale: true,
+			expectedWidth: 1280, expectedHeight: 720,
+		},
+		{
+			name: "Short side without upscale", spec: "1080p", width: 640, height: 480, noUpscale: true,
+			expectedWidth: 640, expectedHeight: 480,
+		},
+		{name: "Both sides auto", spec: "-1x-1", width: 640, height: 480, errorPresent: true},
+		{name: "Invalid spec", spec: "hd", width: 640, height: 480, errorPresent: true},
+	}
+
+	for _, testCase := range cases {
+		t.Run(testCase.name, func(t *testing.T) {
+			w, h, err := resolveResolution(testCase.spec, testCase.width, testCase.height, testCase.noUpscale)
+			if err != nil && !testCase.errorPresent {
+				t.Errorf("Unexpected error: %s\n", err)
+			}
+
+			if err == nil && testCase.errorPresent {
+				t.Errorf("Should be error\n")
+			}
+
+			if w != testCase.expectedWidth || h != testCase.expectedHeight {
+				t.Errorf("Invalid resolution, expected: %dx%d, got: %dx%d\n",
+					testCase.expectedWidth, testCase.expectedHeight, w, h)
+			}
+		})
+	}
+}
+
+func TestBuildOptionsResolution(t *testing.T) {
+	srv := NewCompressor("", "")
+	src := &response.Video{ResolutionX: 1080, ResolutionY: 1920, RatioX: 9, RatioY: 16}
+
+	opts, err := srv.buildOptions(&Request{Resolution: "720p", NoUpscale: true}, src)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s\n", err)
+	}
+
+	if opts.Resolution != nil {
+		t.Errorf("Resolution should be applied by filter, got: %s\n", *opts.Resolution)
+	}
+
+	if opts.VideoFilter == nil || *opts.VideoFilter != "scale=720:1280" {
+		t.Errorf("Invalid video filter, expected: scale=720:1280, got: %v\n", opts.VideoFilter)
+	}
+
+	if _, err = srv.buildOptions(&Request{Resolution: "720p"}, nil); err == nil {
+		t.Errorf("Should be error without original video info\n")
+	}
+}
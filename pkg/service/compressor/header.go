@@ -0,0 +1,183 @@
+package compressor
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// mp4FormatName and matroskaFormatName are format names reported by ffprobe for parsed containers
+const (
+	mp4FormatName      = "mov,mp4,m4a,3gp,3g2,mj2"
+	matroskaFormatName = "matroska,webm"
+)
+
+// maxCachedFiles limits number of files described by cache, descriptions of removed files are dropped first
+const maxCachedFiles = 256
+
+var errUnsupportedContainer = errors.New("unsupported container")
+
+// header returns description of container of file at path. Headers of MP4 and Matroska are parsed
+// without ffprobe, other formats and files which headers can't be parsed are probed by ffprobe.
+// The description of parsed header has no profile and pixel format of streams
+func (c *Compressor) header(path string) (*probeResult, error) {
+	if p := c.meta.get(path, false); p != nil {
+		return p, nil
+	}
+
+	p, err := readHeader(path)
+	if err != nil {
+		return c.probe(path)
+	}
+
+	c.meta.put(path, p, false)
+
+	return p, nil
+}
+
+// describesVideo reports if p has main video stream with fields required by VideoInfo,
+// parsed headers of files without frame rate or video track are probed by ffprobe instead
+func describesVideo(p *probeResult) bool {
+	s := p.videoStream()
+
+	return s != nil && s.Width > 0 && s.Height > 0 && s.CodecName != "" && parseFrameRate(s.AvgFrameRate) != 0
+}
+
+// readHeader parses headers of MP4 or Matroska file at path
+func readHeader(path string) (*probeResult, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	stat, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	return parseHeader(f, stat.Size())
+}
+
+// parseHeader parses headers of container of size bytes, overall bitrate is calculated by size like ffprobe does
+func parseHeader(r io.ReaderAt, size int64) (*probeResult, error) {
+	magic := make([]byte, 8)
+	if _, err := r.ReadAt(magic, 0); err != nil {
+		return nil, errUnsupportedContainer
+	}
+
+	var (
+		p   *probeResult
+		err error
+	)
+
+	switch {
+	case bytes.Equal(magic[:4], ebmlMagic):
+		p, err = parseMatroska(r, size)
+	case string(magic[4:8]) == "ftyp":
+		p, err = parseMP4(r, size)
+	default:
+		return nil, errUnsupportedContainer
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	duration := parseFloat(p.Format.Duration)
+	if duration <= 0 {
+		return nil, errors.New("duration of container is unknown")
+	}
+
+	p.Format.BitRate = strconv.FormatInt(int64(float64(size)*8/duration), decimal)
+
+	return p, nil
+}
+
+// formatSeconds formats duration in seconds like ffprobe does
+func formatSeconds(d float64) string {
+	return strconv.FormatFloat(d, 'f', 6, bitrateBitSize)
+}
+
+// metaCache keeps descriptions of files, so each file of job is parsed or probed once.
+// Description is valid while size and modification time of file are the same
+type metaCache struct {
+	mu      sync.Mutex
+	entries map[string]metaEntry
+}
+
+type metaEntry struct {
+	size    int64
+	modTime time.Time
+	result  *probeResult
+	// complete is set for descriptions made by ffprobe
+	complete bool
+}
+
+func newMetaCache() *metaCache {
+	return &metaCache{entries: make(map[string]metaEntry)}
+}
+
+// get returns cached description of file at path, complete requires description made by ffprobe
+func (m *metaCache) get(path string, complete bool) *probeResult {
+	if m == nil {
+		return nil
+	}
+
+	stat, err := os.Stat(path)
+	if err != nil {
+		return nil
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entry, ok := m.entries[path]
+	if !ok || entry.size != stat.Size() || !entry.modTime.Equal(stat.ModTime()) {
+		return nil
+	}
+
+	if complete && !entry.complete {
+		return nil
+	}
+
+	return entry.result
+}
+
+// put caches description of file at path
+func (m *metaCache) put(path string, result *probeResult, complete bool) {
+	if m == nil {
+		return
+	}
+
+	stat, err := os.Stat(path)
+	if err != nil {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if len(m.entries) >= maxCachedFiles {
+		m.evict()
+	}
+
+	m.entries[path] = metaEntry{size: stat.Size(), modTime: stat.ModTime(), result: result, complete: complete}
+}
+
+// evict drops descriptions of removed files, the whole cache is dropped when all files are present
+func (m *metaCache) evict() {
+	for path := range m.entries {
+		if _, err := os.Stat(path); err != nil {
+			delete(m.entries, path)
+		}
+	}
+
+	if len(m.entries) >= maxCachedFiles {
+		m.entries = make(map[string]metaEntry)
+	}
+}
@@ -0,0 +1,357 @@
+package compressor
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/Hargeon/compressrv/pkg/response"
+)
+
+func mp4TestBox(typ string, payload ...[]byte) []byte {
+	data := bytes.Join(payload, nil)
+	header := make([]byte, boxHeaderSize)
+	binary.BigEndian.PutUint32(header, uint32(len(data)+boxHeaderSize))
+	copy(header[4:], typ)
+
+	return append(header, data...)
+}
+
+func mp4TestUint(values ...uint32) []byte {
+	data := make([]byte, 4*len(values))
+	for i, v := range values {
+		binary.BigEndian.PutUint32(data[i*4:], v)
+	}
+
+	return data
+}
+
+// mp4TestTrackHeader returns tkhd box with flags and display matrix rotated by a and b
+func mp4TestTrackHeader(flags uint32, a, b int32) []byte {
+	matrix := mp4TestUint(uint32(a), uint32(b), 0, uint32(-b), uint32(a), 0, 0, 0, 1<<30)
+
+	return mp4TestBox("tkhd", mp4TestUint(flags, 0, 0, 1, 0, 0), make([]byte, 16), matrix, make([]byte, 8))
+}
+
+func mp4TestTrack(handler, entry string, language uint16, timescale, duration uint32, fields, sizes []byte,
+	header ...[]byte) []byte {
+	sampleEntry := mp4TestBox(entry, make([]byte, 8), fields)
+
+	return mp4TestBox("trak", append(header,
+		mp4TestBox("mdia",
+			mp4TestBox("mdhd", mp4TestUint(0, 0, 0, timescale, duration, uint32(language)<<16)),
+			mp4TestBox("hdlr", mp4TestUint(0, 0), []byte(handler), make([]byte, 12)),
+			mp4TestBox("minf",
+				mp4TestBox("stbl",
+					mp4TestBox("stsd", mp4TestUint(0, 1), sampleEntry),
+					mp4TestBox("stsz", sizes)))))...)
+}
+
+func testMP4(moovBoxes ...[]byte) []byte {
+	return testRotatedMP4(1<<16, 0, moovBoxes...)
+}
+
+// testRotatedMP4 returns MP4 file which video track has display matrix rotated by a and b
+func testRotatedMP4(a, b int32, moovBoxes ...[]byte) []byte {
+	visual := make([]byte, 70)
+	binary.BigEndian.PutUint16(visual[16:], 1280)
+	binary.BigEndian.PutUint16(visual[18:], 720)
+	visual = append(visual, mp4TestBox("pasp", mp4TestUint(4, 3))...)
+
+	audio := make([]byte, 20)
+	binary.BigEndian.PutUint16(audio[8:], 2)
+	binary.BigEndian.PutUint32(audio[16:], 48000<<16)
+
+	moov := append([][]byte{
+		mp4TestBox("mvhd", mp4TestUint(0, 0, 0, 1000, 10000), make([]byte, 80)),
+		// "und" language
+		mp4TestTrack("vide", "avc1", 0x55C4, 90000, 900000, visual, mp4TestUint(0, 0, 2, 100000, 150000),
+			mp4TestTrackHeader(trackEnabled, a, b)),
+		// Macintosh English language
+		mp4TestTrack("soun", "mp4a", 0, 48000, 480000, audio, mp4TestUint(0, 1000, 160)),
+		mp4TestTrack("tmcd", "tmcd", unspecifiedLanguage, 1000, 10000, nil, mp4TestUint(0, 4, 1)),
+	}, moovBoxes...)
+
+	return bytes.Join([][]byte{
+		mp4TestBox("ftyp", []byte("isom"), mp4TestUint(512), []byte("isomavc1")),
+		mp4TestBox("mdat", make([]byte, 1000)),
+		mp4TestBox("moov", moov...),
+	}, nil)
+}
+
+func ebmlTestElement(id uint64, payload ...[]byte) []byte {
+	data := bytes.Join(payload, nil)
+	idBytes := make([]byte, 8)
+	binary.BigEndian.PutUint64(idBytes, id)
+	idBytes = bytes.TrimLeft(idBytes, "\x00")
+
+	size := make([]byte, 8)
+	binary.BigEndian.PutUint64(size, uint64(len(data)))
+	size[0] = 0x01
+
+	return bytes.Join([][]byte{idBytes, size, data}, nil)
+}
+
+func ebmlTestUint(v uint64) []byte {
+	data := make([]byte, 8)
+	binary.BigEndian.PutUint64(data, v)
+
+	return bytes.TrimLeft(data, "\x00")
+}
+
+func ebmlTestFloat(v float64) []byte {
+	data := make([]byte, 8)
+	binary.BigEndian.PutUint64(data, math.Float64bits(v))
+
+	return data
+}
+
+func testMatroska(docType string, clusterFirst bool) []byte {
+	info := ebmlTestElement(infoID,
+		ebmlTestElement(timecodeScaleID, ebmlTestUint(defaultTimecodeScale)),
+		ebmlTestElement(durationID, ebmlTestFloat(5000)))
+	tracks := ebmlTestElement(tracksID,
+		ebmlTestElement(trackEntryID,
+			ebmlTestElement(trackTypeID, ebmlTestUint(matroskaTrackVideo)),
+			ebmlTestElement(codecIDID, []byte("V_VP9")),
+			ebmlTestElement(defaultDurationID, ebmlTestUint(40000000)),
+			ebmlTestElement(languageID, []byte("und")),
+			ebmlTestElement(trackVideoID,
+				ebmlTestElement(pixelWidthID, ebmlTestUint(640)),
+				ebmlTestElement(pixelHeightID, ebmlTestUint(360)),
+				ebmlTestElement(displayWidthID, ebmlTestUint(853)),
+				ebmlTestElement(displayHeightID, ebmlTestUint(360)))),
+		ebmlTestElement(trackEntryID,
+			ebmlTestElement(trackTypeID, ebmlTestUint(matroskaTrackAudio)),
+			ebmlTestElement(codecIDID, []byte("A_OPUS\x00")),
+			ebmlTestElement(flagDefaultID, ebmlTestUint(0)),
+			ebmlTestElement(trackAudioID,
+				ebmlTestElement(channelsID, ebmlTestUint(2)),
+				ebmlTestElement(samplingFrequencyID, ebmlTestFloat(48000)))))
+	// cluster of unknown size, like live streams have
+	cluster := append([]byte{0x1F, 0x43, 0xB6, 0x75, 0x01, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF},
+		make([]byte, 500)...)
+
+	children := [][]byte{info, tracks, cluster}
+	if clusterFirst {
+		children = [][]byte{info, cluster, tracks}
+	}
+
+	segment := append([]byte{0x18, 0x53, 0x80, 0x67, 0x01, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF},
+		bytes.Join(children, nil)...)
+
+	return append(ebmlTestElement(ebmlHeaderID, ebmlTestElement(ebmlDocTypeID, []byte(docType))), segment...)
+}
+
+func TestParseHeader(t *testing.T) {
+	mp4 := testMP4()
+	mkv := testMatroska("webm", false)
+
+	cases := []struct {
+		name         string
+		data         []byte
+		expected     *probeResult
+		errorPresent bool
+	}{
+		{
+			name: "MP4",
+			data: mp4,
+			expected: &probeResult{
+				Format: probeFormat{FormatName: mp4FormatName, Duration: "10.000000",
+					BitRate: fmt.Sprintf("%d", len(mp4)*8/10)},
+				Streams: []probeStream{
+					{Index: 0, CodecType: streamVideo, CodecName: "h264", Width: 1280, Height: 720,
+						SampleAspectRatio: "4:3", AvgFrameRate: "1/5", Duration: "10.000000", BitRate: "200000",
+						Disposition: map[string]int{"default": 1},
+						Tags:        map[string]string{"language": "und"}},
+					{Index: 1, CodecType: streamAudio, CodecName: "aac", Channels: 2, SampleRate: "48000",
+						Duration: "10.000000", BitRate: "128000", Tags: map[string]string{"language": "eng"}},
+					{Index: 2, CodecType: streamData, CodecName: "tmcd", Duration: "10.000000", BitRate: "3",
+						Tags: map[string]string{}},
+				},
+			},
+		},
+		{
+			name: "WebM",
+			data: mkv,
+			expected: &probeResult{
+				Format: probeFormat{FormatName: matroskaFormatName, Duration: "5.000000",
+					BitRate: fmt.Sprintf("%d", len(mkv)*8/5)},
+				Streams: []probeStream{
+					{Index: 0, CodecType: streamVideo, CodecName: "vp9", Width: 640, Height: 360,
+						SampleAspectRatio: "853:640", AvgFrameRate: "25/1",
+						Disposition: map[string]int{"default": 1, "forced": 0}, Tags: map[string]string{}},
+					{Index: 1, CodecType: streamAudio, CodecName: "opus", Channels: 2, SampleRate: "48000",
+						Disposition: map[string]int{"default": 0, "forced": 0},
+						Tags:        map[string]string{"language": "eng"}},
+				},
+			},
+		},
+		{
+			name:         "Fragmented MP4",
+			data:         testMP4(mp4TestBox("mvex")),
+			errorPresent: true,
+		},
+		{
+			name:         "Tracks after clusters",
+			data:         testMatroska("matroska", true),
+			errorPresent: true,
+		},
+		{
+			name:         "Other EBML document",
+			data:         testMatroska("other", false),
+			errorPresent: true,
+		},
+		{
+			name:         "Truncated MP4",
+			data:         mp4[:len(mp4)-10],
+			errorPresent: true,
+		},
+		{
+			name:         "AVI",
+			data:         append([]byte("RIFF\x00\x00\x00\x00AVI LIST"), make([]byte, 100)...),
+			errorPresent: true,
+		},
+	}
+
+	for _, testCase := range cases {
+		t.Run(testCase.name, func(t *testing.T) {
+			p, err := parseHeader(bytes.NewReader(testCase.data), int64(len(testCase.data)))
+			if err != nil && !testCase.errorPresent {
+				t.Fatalf("Unexpected error: %s\n", err)
+			}
+
+			if err == nil && testCase.errorPresent {
+				t.Fatalf("Should be error\n")
+			}
+
+			if !reflect.DeepEqual(p, testCase.expected) {
+				t.Errorf("Invalid header, expected: %+v, got: %+v\n", testCase.expected, p)
+			}
+		})
+	}
+}
+
+func TestReadHeader(t *testing.T) {
+	path := fmt.Sprintf("%s%stest_video.mkv", os.Getenv("ROOT"), originalVideoPath)
+
+	p, err := readHeader(path)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s\n", err)
+	}
+
+	if p.Format.Duration != "42.000000" {
+		t.Errorf("Invalid duration, expected: %s, got: %s\n", "42.000000", p.Format.Duration)
+	}
+
+	if p.Format.BitRate != "274625" {
+		t.Errorf("Invalid bitrate, expected: %s, got: %s\n", "274625", p.Format.BitRate)
+	}
+
+	if s := p.videoStream(); s == nil || s.CodecName != "h264" || s.Width != 1344 || s.Height != 628 {
+		t.Errorf("Invalid video stream, got: %+v\n", s)
+	}
+}
+
+func TestVideoInfoHeader(t *testing.T) {
+	dir := t.TempDir()
+	// video is rotated by 90 degrees clockwise
+	mp4 := testRotatedMP4(0, 1<<16)
+	mkv := testMatroska("matroska", false)
+
+	cases := []struct {
+		name         string
+		data         []byte
+		expected     *response.Video
+		errorPresent bool
+	}{
+		{
+			name: "MP4",
+			data: mp4,
+			expected: &response.Video{
+				Bitrate: int64(len(mp4) * 8 / 10), ResolutionX: 720, ResolutionY: 1280, RatioX: 27, RatioY: 64,
+				Duration: 10, FPS: 0.2, Codec: "h264", Rotation: 90, Container: mp4FormatName,
+				VideoBitrate: 200000, Streams: 3,
+				Audio: []response.Audio{{Index: 1, Codec: "aac", Channels: 2, SampleRate: 48000, Bitrate: 128000,
+					Language: "eng"}},
+			},
+		},
+		{
+			name: "Matroska",
+			data: mkv,
+			expected: &response.Video{
+				Bitrate: int64(len(mkv) * 8 / 5), ResolutionX: 640, ResolutionY: 360, RatioX: 853, RatioY: 360,
+				Duration: 5, FPS: 25, Codec: "vp9", Container: matroskaFormatName, Streams: 2,
+				Audio: []response.Audio{{Index: 1, Codec: "opus", Channels: 2, SampleRate: 48000, Language: "eng"}},
+			},
+		},
+		{
+			// header can't be parsed, so file is probed by missing ffprobe
+			name:         "Tracks after clusters",
+			data:         testMatroska("matroska", true),
+			errorPresent: true,
+		},
+	}
+
+	srv := NewCompressor("", filepath.Join(dir, "ffprobe"))
+
+	for i, testCase := range cases {
+		t.Run(testCase.name, func(t *testing.T) {
+			path := filepath.Join(dir, fmt.Sprintf("video_%d", i))
+			if err := os.WriteFile(path, testCase.data, 0600); err != nil {
+				t.Fatalf("Unexpected error: %s\n", err)
+			}
+
+			video, err := srv.VideoInfo(path)
+			if err != nil && !testCase.errorPresent {
+				t.Fatalf("Unexpected error: %s\n", err)
+			}
+
+			if err == nil && testCase.errorPresent {
+				t.Fatalf("Should be error\n")
+			}
+
+			if !reflect.DeepEqual(video, testCase.expected) {
+				t.Errorf("Invalid video info, expected: %+v, got: %+v\n", testCase.expected, video)
+			}
+		})
+	}
+}
+
+func TestMetaCache(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "video.mp4")
+	if err := os.WriteFile(path, testMP4(), 0600); err != nil {
+		t.Fatalf("Unexpected error: %s\n", err)
+	}
+
+	srv := NewCompressor("", "")
+
+	if _, err := srv.videoDuration(path); err != nil {
+		t.Fatalf("Unexpected error: %s\n", err)
+	}
+
+	if p := srv.meta.get(path, false); p == nil {
+		t.Errorf("Header should be cached\n")
+	}
+
+	if p := srv.meta.get(path, true); p != nil {
+		t.Errorf("Header shouldn't be cached as ffprobe output\n")
+	}
+
+	if err := os.WriteFile(path, []byte("changed"), 0600); err != nil {
+		t.Fatalf("Unexpected error: %s\n", err)
+	}
+
+	if p := srv.meta.get(path, false); p != nil {
+		t.Errorf("Header of changed file shouldn't be cached\n")
+	}
+
+	if _, err := srv.videoDuration(path); err == nil {
+		t.Errorf("Should be error\n")
+	}
+}
@@ -0,0 +1,84 @@
+package compressor
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/Hargeon/compressrv/pkg/response"
+)
+
+const hlsManifest = "master.m3u8"
+
+// hls encodes each rendition to HLS variant playlist and writes master playlist
+func (c *Compressor) hls(ctx context.Context, originalVideo, dir string,
+	renditions []rendition, segmentDuration int, trim []string) (*response.Package, error) {
+	audio, err := c.hasAudio(originalVideo)
+	if err != nil {
+		return nil, err
+	}
+
+	pkg := &response.Package{Format: OutputHLS, Manifest: hlsManifest}
+
+	for _, r := range renditions {
+		playlist := r.name + ".m3u8"
+
+		args := append([]string{"-i", originalVideo}, trim...)
+		args = append(args, "-map", "0:v:0", "-map", "0:a:0?")
+		args = append(args, r.videoArgs("")...)
+		args = append(args, encodeArgs(segmentDuration)...)
+		args = append(args, audioArgs(r.AudioBitrate)...)
+		args = append(args,
+			"-f", "hls",
+			"-hls_time", strconv.Itoa(segmentDuration),
+			"-hls_playlist_type", "vod",
+			"-hls_flags", "independent_segments",
+			"-hls_segment_filename", filepath.Join(dir, r.name+"_%04d.ts"),
+			filepath.Join(dir, playlist))
+
+		if _, err := c.run(ctx, args...); err != nil {
+			return nil, err
+		}
+
+		pkg.Variants = append(pkg.Variants, response.Variant{
+			Playlist:    playlist,
+			Bitrate:     r.Bitrate,
+			Bandwidth:   r.bandwidth(),
+			ResolutionX: r.width,
+			ResolutionY: r.height,
+			Codecs:      r.codecs(audio),
+		})
+	}
+
+	master, err := os.Create(filepath.Join(dir, hlsManifest))
+	if err != nil {
+		return nil, err
+	}
+	defer master.Close()
+
+	if err = writeMasterPlaylist(master, pkg.Variants); err != nil {
+		return nil, err
+	}
+
+	return pkg, nil
+}
+
+// writeMasterPlaylist writes HLS master playlist which references variants
+func writeMasterPlaylist(w io.Writer, variants []response.Variant) error {
+	var b strings.Builder
+
+	b.WriteString("#EXTM3U\n#EXT-X-VERSION:3\n#EXT-X-INDEPENDENT-SEGMENTS\n")
+
+	for _, v := range variants {
+		fmt.Fprintf(&b, "#EXT-X-STREAM-INF:BANDWIDTH=%d,AVERAGE-BANDWIDTH=%d,CODECS=\"%s\",RESOLUTION=%dx%d\n%s\n",
+			v.Bandwidth, v.Bitrate, v.Codecs, v.ResolutionX, v.ResolutionY, v.Playlist)
+	}
+
+	_, err := io.WriteString(w, b.String())
+
+	return err
+}
@@ -0,0 +1,32 @@
+package compressor
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Hargeon/compressrv/pkg/response"
+)
+
+func TestWriteMasterPlaylist(t *testing.T) {
+	variants := []response.Variant{
+		{Playlist: "720p.m3u8", Bitrate: 2800000, Bandwidth: 3124000, ResolutionX: 1280, ResolutionY: 720,
+			Codecs: "avc1.64001F,mp4a.40.2"},
+		{Playlist: "360p.m3u8", Bitrate: 800000, Bandwidth: 952000, ResolutionX: 640, ResolutionY: 360,
+			Codecs: "avc1.64001E,mp4a.40.2"},
+	}
+
+	expected := "#EXTM3U\n#EXT-X-VERSION:3\n#EXT-X-INDEPENDENT-SEGMENTS\n" +
+		"#EXT-X-STREAM-INF:BANDWIDTH=3124000,AVERAGE-BANDWIDTH=2800000,CODECS=\"avc1.64001F,mp4a.40.2\"," +
+		"RESOLUTION=1280x720\n720p.m3u8\n" +
+		"#EXT-X-STREAM-INF:BANDWIDTH=952000,AVERAGE-BANDWIDTH=800000,CODECS=\"avc1.64001E,mp4a.40.2\"," +
+		"RESOLUTION=640x360\n360p.m3u8\n"
+
+	var b strings.Builder
+	if err := writeMasterPlaylist(&b, variants); err != nil {
+		t.Fatalf("Unexpected error: %s\n", err)
+	}
+
+	if b.String() != expected {
+		t.Errorf("Invalid master playlist, expected:\n%s\ngot:\n%s\n", expected, b.String())
+	}
+}
@@ -0,0 +1,88 @@
+package compressor
+
+import (
+	"fmt"
+	"math"
+)
+
+const (
+	// h264ProfileHigh is a profile_idc of High profile, variants of adaptive output are encoded with it
+	h264ProfileHigh = 100
+	// aacLowComplexity is an RFC 6381 codec of AAC-LC audio of variants
+	aacLowComplexity = "mp4a.40.2"
+	// macroblockSize of H.264 in pixels
+	macroblockSize = 16
+	// unknownLevelFPS is used for level of video with unknown frame rate
+	unknownLevelFPS = 60
+)
+
+// h264Level limits frame size and rate in macroblocks and bitrate of High profile of H.264 level
+type h264Level struct {
+	idc       int
+	frameSize int
+	rate      float64
+	bitrate   int64
+}
+
+// h264Levels are sorted by idc, levels lower than 3.0 aren't used
+var h264Levels = []h264Level{
+	{idc: 30, frameSize: 1620, rate: 40500, bitrate: 12500000},
+	{idc: 31, frameSize: 3600, rate: 108000, bitrate: 17500000},
+	{idc: 32, frameSize: 5120, rate: 216000, bitrate: 25000000},
+	{idc: 40, frameSize: 8192, rate: 245760, bitrate: 25000000},
+	{idc: 41, frameSize: 8192, rate: 245760, bitrate: 62500000},
+	{idc: 42, frameSize: 8704, rate: 522240, bitrate: 62500000},
+	{idc: 50, frameSize: 22080, rate: 589824, bitrate: 168750000},
+	{idc: 51, frameSize: 36864, rate: 983040, bitrate: 300000000},
+	{idc: 52, frameSize: 36864, rate: 2073600, bitrate: 300000000},
+	{idc: 60, frameSize: 139264, rate: 4177920, bitrate: 300000000},
+	{idc: 61, frameSize: 139264, rate: 8355840, bitrate: 600000000},
+	{idc: 62, frameSize: 139264, rate: 16711680, bitrate: 1000000000},
+}
+
+// withLevels returns renditions with the lowest H.264 levels which fit their frame size,
+// fps frame rate and peak bitrate
+func withLevels(renditions []rendition, fps float64) []rendition {
+	if fps == 0 {
+		fps = unknownLevelFPS
+	}
+
+	leveled := make([]rendition, len(renditions))
+
+	for i, r := range renditions {
+		frameSize := macroblocks(r.width) * macroblocks(r.height)
+		r.level = h264Levels[len(h264Levels)-1].idc
+
+		for _, l := range h264Levels {
+			if frameSize <= l.frameSize && float64(frameSize)*fps <= l.rate && r.peakBitrate() <= l.bitrate {
+				r.level = l.idc
+
+				break
+			}
+		}
+
+		leveled[i] = r
+	}
+
+	return leveled
+}
+
+// macroblocks returns number of macroblocks which cover d pixels
+func macroblocks(d int) int {
+	return int(math.Ceil(float64(d) / macroblockSize))
+}
+
+// formatLevel formats level_idc like 40 as 4.0 for encoder
+func formatLevel(idc int) string {
+	return fmt.Sprintf("%d.%d", idc/10, idc%10)
+}
+
+// codecs returns RFC 6381 codecs of rendition encoded with High profile, audio is AAC-LC
+func (r rendition) codecs(audio bool) string {
+	codecs := fmt.Sprintf("avc1.%02X00%02X", h264ProfileHigh, r.level)
+	if audio {
+		codecs += "," + aacLowComplexity
+	}
+
+	return codecs
+}
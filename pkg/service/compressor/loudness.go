@@ -0,0 +1,146 @@
+package compressor
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/Hargeon/compressrv/pkg/response"
+)
+
+// Default EBU R128 targets of loudness normalization
+const (
+	defaultIntegratedLoudness = -23
+	defaultTruePeak           = -1
+	defaultLoudnessRange      = 7
+	defaultAudioRate          = 48000
+)
+
+// loudnormStats is a json printed by loudnorm filter
+type loudnormStats struct {
+	InputI      string `json:"input_i"`
+	InputTP     string `json:"input_tp"`
+	InputLRA    string `json:"input_lra"`
+	InputThresh string `json:"input_thresh"`
+}
+
+// targets returns integrated loudness, true peak and loudness range targets, defaults are used for zero values
+func (l *LoudnessOptions) targets() (float64, float64, float64) {
+	i, tp, lra := l.Integrated, l.TruePeak, l.LRA
+	if i == 0 {
+		i = defaultIntegratedLoudness
+	}
+
+	if tp == 0 {
+		tp = defaultTruePeak
+	}
+
+	if lra == 0 {
+		lra = defaultLoudnessRange
+	}
+
+	return i, tp, lra
+}
+
+// MeasureLoudness measures loudness of clip of video described by opt with loudnorm filter.
+// It returns nil for video without audio or with silent audio
+func (c *Compressor) MeasureLoudness(ctx context.Context, opt *Request, path string) (*response.LoudnessLevel, error) {
+	audio, err := c.hasAudio(path)
+	if err != nil || !audio {
+		return nil, err
+	}
+
+	args := append([]string{"-i", path}, trimArgs(opt)...)
+	args = append(args, "-map", "0:a:0", "-af", "loudnorm=print_format=json", "-f", "null", "-")
+
+	stderr, err := c.run(ctx, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return parseLoudnorm(stderr)
+}
+
+// parseLoudnorm parses measured values from loudnorm output, it returns nil for silent audio
+func parseLoudnorm(stderr string) (*response.LoudnessLevel, error) {
+	start, end := strings.LastIndex(stderr, "{"), strings.LastIndex(stderr, "}")
+	if start == -1 || end < start {
+		return nil, errors.New("loudnorm stats not found")
+	}
+
+	var stats loudnormStats
+	if err := json.Unmarshal([]byte(stderr[start:end+1]), &stats); err != nil {
+		return nil, err
+	}
+
+	level := new(response.LoudnessLevel)
+	values := []struct {
+		s string
+		v *float64
+	}{
+		{stats.InputI, &level.Integrated},
+		{stats.InputTP, &level.TruePeak},
+		{stats.InputLRA, &level.LRA},
+		{stats.InputThresh, &level.Threshold},
+	}
+
+	for _, value := range values {
+		v, err := strconv.ParseFloat(value.s, bitrateBitSize)
+		if err != nil {
+			return nil, fmt.Errorf("invalid loudnorm stats: %w", err)
+		}
+
+		// loudness of silence is -inf, it can't be normalized
+		if math.IsInf(v, 0) {
+			return nil, nil
+		}
+
+		*value.v = v
+	}
+
+	return level, nil
+}
+
+// loudnormFilter returns the second pass loudnorm filter which normalizes audio with measured values.
+// Linear normalization keeps dynamics of audio, loudnorm falls back to dynamic one when linear can't reach targets
+func loudnormFilter(opt *LoudnessOptions, measured *response.LoudnessLevel) string {
+	i, tp, lra := opt.targets()
+
+	return fmt.Sprintf("loudnorm=I=%s:TP=%s:LRA=%s", formatNumber(i), formatNumber(tp), formatNumber(lra)) +
+		fmt.Sprintf(":measured_I=%s:measured_TP=%s:measured_LRA=%s:measured_thresh=%s:linear=true",
+			formatNumber(measured.Integrated), formatNumber(measured.TruePeak),
+			formatNumber(measured.LRA), formatNumber(measured.Threshold))
+}
+
+// withLoudness returns copy of opt with measured loudness of originalVideo, opt is returned when it has measured loudness
+func (c *Compressor) withLoudness(ctx context.Context, opt *Request, originalVideo string) (*Request, error) {
+	if opt.Loudness == nil || opt.Loudness.Measured != nil {
+		return opt, nil
+	}
+
+	measured, err := c.MeasureLoudness(ctx, opt, originalVideo)
+	if err != nil || measured == nil {
+		return opt, err
+	}
+
+	loudness := *opt.Loudness
+	loudness.Measured = measured
+
+	req := *opt
+	req.Loudness = &loudness
+
+	return &req, nil
+}
+
+// audioRate returns sample rate of audio of src
+func audioRate(src *response.Video) int {
+	if src != nil && len(src.Audio) != 0 && src.Audio[0].SampleRate != 0 {
+		return src.Audio[0].SampleRate
+	}
+
+	return defaultAudioRate
+}
@@ -0,0 +1,80 @@
+package compressor
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/Hargeon/compressrv/pkg/response"
+)
+
+func TestParseLoudnorm(t *testing.T) {
+	cases := []struct {
+		name         string
+		stderr       string
+		expected     *response.LoudnessLevel
+		errorPresent bool
+	}{
+		{
+			name: "Valid stats",
+			stderr: "size=N/A time=00:01:00.00 bitrate=N/A speed= 120x\n" +
+				"[Parsed_loudnorm_0 @ 0x55d1] \n{\n" +
+				"\t\"input_i\" : \"-27.61\",\n\t\"input_tp\" : \"-4.47\",\n" +
+				"\t\"input_lra\" : \"18.06\",\n\t\"input_thresh\" : \"-39.20\",\n" +
+				"\t\"output_i\" : \"-16.58\",\n\t\"output_tp\" : \"-1.50\",\n" +
+				"\t\"output_lra\" : \"14.78\",\n\t\"output_thresh\" : \"-27.71\",\n" +
+				"\t\"normalization_type\" : \"dynamic\",\n\t\"target_offset\" : \"0.58\"\n}\n",
+			expected: &response.LoudnessLevel{Integrated: -27.61, TruePeak: -4.47, LRA: 18.06, Threshold: -39.2},
+		},
+		{
+			name:   "Silent audio",
+			stderr: "{\n\t\"input_i\" : \"-inf\",\n\t\"input_tp\" : \"-inf\",\n\t\"input_lra\" : \"0.00\",\n\t\"input_thresh\" : \"-70.00\"\n}\n",
+		},
+		{
+			name:         "Without stats",
+			stderr:       "Output file #0 does not contain any stream\n",
+			errorPresent: true,
+		},
+	}
+
+	for _, testCase := range cases {
+		t.Run(testCase.name, func(t *testing.T) {
+			level, err := parseLoudnorm(testCase.stderr)
+			if err != nil && !testCase.errorPresent {
+				t.Errorf("Unexpected error: %s\n", err)
+			}
+
+			if err == nil && testCase.errorPresent {
+				t.Errorf("Should be error\n")
+			}
+
+			if !reflect.DeepEqual(level, testCase.expected) {
+				t.Errorf("Invalid loudness, expected: %+v, got: %+v\n", testCase.expected, level)
+			}
+		})
+	}
+}
+
+func TestBuildOptionsLoudness(t *testing.T) {
+	opt := &Request{
+		Loudness: &LoudnessOptions{
+			Integrated: -16,
+			Measured:   &response.LoudnessLevel{Integrated: -27.61, TruePeak: -4.47, LRA: 18.06, Threshold: -39.2},
+		},
+	}
+	src := &response.Video{Audio: []response.Audio{{SampleRate: 44100}}}
+
+	opts, err := NewCompressor("", "").buildOptions(opt, src)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s\n", err)
+	}
+
+	expected := "loudnorm=I=-16:TP=-1:LRA=7:measured_I=-27.61:measured_TP=-4.47:measured_LRA=18.06:" +
+		"measured_thresh=-39.2:linear=true"
+	if opts.AudioFilter == nil || *opts.AudioFilter != expected {
+		t.Errorf("Invalid audio filter, expected: %s, got: %v\n", expected, opts.AudioFilter)
+	}
+
+	if opts.AudioRate == nil || *opts.AudioRate != 44100 {
+		t.Errorf("Invalid audio rate, expected: %d, got: %v\n", 44100, opts.AudioRate)
+	}
+}
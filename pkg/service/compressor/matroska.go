@@ -0,0 +1,518 @@
+package compressor
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/bits"
+	"strings"
+)
+
+// EBML element ids of Matroska headers
+const (
+	ebmlHeaderID        = 0x1A45DFA3
+	ebmlDocTypeID       = 0x4282
+	segmentID           = 0x18538067
+	clusterID           = 0x1F43B675
+	infoID              = 0x1549A966
+	timecodeScaleID     = 0x2AD7B1
+	durationID          = 0x4489
+	tracksID            = 0x1654AE6B
+	trackEntryID        = 0xAE
+	trackTypeID         = 0x83
+	codecIDID           = 0x86
+	trackVideoID        = 0xE0
+	pixelWidthID        = 0xB0
+	pixelHeightID       = 0xBA
+	trackAudioID        = 0xE1
+	samplingFrequencyID = 0xB5
+	channelsID          = 0x9F
+	defaultDurationID   = 0x23E383
+	languageID          = 0x22B59C
+	flagDefaultID       = 0x88
+	flagForcedID        = 0x55AA
+	displayWidthID      = 0x54B0
+	displayHeightID     = 0x54BA
+	projectionID        = 0x7670
+)
+
+const (
+	defaultTimecodeScale = 1000000
+	nanosecondsPerSecond = 1e9
+	maxEBMLVintLength    = 8
+	// maxEBMLStringSize limits size of parsed strings, document type and codec ids are short
+	maxEBMLStringSize = 256
+	// defaultTrackLanguage is a language of track without Language element
+	defaultTrackLanguage = "eng"
+)
+
+// Matroska track types
+const (
+	matroskaTrackVideo    = 1
+	matroskaTrackAudio    = 2
+	matroskaTrackSubtitle = 17
+)
+
+var ebmlMagic = []byte{0x1A, 0x45, 0xDF, 0xA3}
+
+// matroskaCodecs maps Matroska codec ids to ffprobe codec names
+var matroskaCodecs = map[string]string{
+	"V_MPEG4/ISO/AVC":  "h264",
+	"V_MPEGH/ISO/HEVC": "hevc",
+	"V_AV1":            "av1",
+	"V_VP8":            "vp8",
+	"V_VP9":            "vp9",
+	"V_MPEG4/ISO/ASP":  "mpeg4",
+	"A_AAC":            "aac",
+	"A_OPUS":           "opus",
+	"A_VORBIS":         "vorbis",
+	"A_MPEG/L3":        "mp3",
+	"A_AC3":            "ac3",
+	"A_EAC3":           "eac3",
+	"A_FLAC":           "flac",
+	"S_TEXT/UTF8":      "subrip",
+	"S_TEXT/ASS":       "ass",
+	"S_TEXT/WEBVTT":    "webvtt",
+	"S_HDMV/PGS":       "hdmv_pgs_subtitle",
+	"S_VOBSUB":         "dvd_subtitle",
+}
+
+// matroskaTypes maps Matroska track types to ffprobe codec types, tracks of other types are skipped
+var matroskaTypes = map[uint64]string{
+	matroskaTrackVideo:    streamVideo,
+	matroskaTrackAudio:    streamAudio,
+	matroskaTrackSubtitle: streamSubtitle,
+}
+
+// ebmlElement is an element of EBML document, offset and size describe its data.
+// Size is -1 for elements of unknown size
+type ebmlElement struct {
+	id     uint64
+	offset int64
+	size   int64
+}
+
+// parseMatroska parses Info and Tracks elements of Matroska or WebM file of size bytes.
+// Parsing stops at the first cluster, so files with tracks described after clusters are unsupported
+func parseMatroska(r io.ReaderAt, size int64) (*probeResult, error) {
+	header, err := readElement(r, 0, size)
+	if err != nil {
+		return nil, err
+	}
+
+	if header.id != ebmlHeaderID || header.size < 0 {
+		return nil, errors.New("invalid EBML header")
+	}
+
+	if err = checkDocType(r, header); err != nil {
+		return nil, err
+	}
+
+	segment, err := readElement(r, header.offset+header.size, size)
+	if err != nil {
+		return nil, err
+	}
+
+	if segment.id != segmentID {
+		return nil, errors.New("segment not found")
+	}
+
+	end := size
+	if segment.size >= 0 {
+		end = segment.offset + segment.size
+	}
+
+	p := &probeResult{Format: probeFormat{FormatName: matroskaFormatName}}
+
+	var info, tracks bool
+
+	for pos := segment.offset; pos < end && !(info && tracks); {
+		e, err := readElement(r, pos, end)
+		if err != nil {
+			return nil, err
+		}
+
+		if e.id == clusterID {
+			break
+		}
+
+		if e.size < 0 {
+			return nil, fmt.Errorf("element %X has unknown size", e.id)
+		}
+
+		switch e.id {
+		case infoID:
+			if p.Format.Duration, err = parseMatroskaInfo(r, e); err != nil {
+				return nil, err
+			}
+
+			info = true
+		case tracksID:
+			if p.Streams, err = parseMatroskaTracks(r, e); err != nil {
+				return nil, err
+			}
+
+			tracks = true
+		}
+
+		pos = e.offset + e.size
+	}
+
+	if !info || !tracks {
+		return nil, errors.New("info or tracks not found before clusters")
+	}
+
+	return p, nil
+}
+
+// checkDocType checks that EBML document is Matroska or WebM
+func checkDocType(r io.ReaderAt, header ebmlElement) error {
+	children, err := readElements(r, header)
+	if err != nil {
+		return err
+	}
+
+	for _, e := range children {
+		if e.id != ebmlDocTypeID {
+			continue
+		}
+
+		docType, err := readString(r, e)
+		if err != nil {
+			return err
+		}
+
+		if docType != "matroska" && docType != "webm" {
+			return fmt.Errorf("unsupported EBML document %q", docType)
+		}
+
+		return nil
+	}
+
+	return errors.New("EBML document type not found")
+}
+
+// parseMatroskaInfo returns duration of segment in seconds formatted like ffprobe does
+func parseMatroskaInfo(r io.ReaderAt, info ebmlElement) (string, error) {
+	children, err := readElements(r, info)
+	if err != nil {
+		return "", err
+	}
+
+	var (
+		scale    uint64 = defaultTimecodeScale
+		duration float64
+	)
+
+	for _, e := range children {
+		switch e.id {
+		case timecodeScaleID:
+			if scale, err = readUint(r, e); err != nil {
+				return "", err
+			}
+		case durationID:
+			if duration, err = readFloat(r, e); err != nil {
+				return "", err
+			}
+		}
+	}
+
+	if duration <= 0 {
+		return "", errors.New("duration of segment not found")
+	}
+
+	return formatSeconds(duration * float64(scale) / nanosecondsPerSecond), nil
+}
+
+// parseMatroskaTracks describes video, audio and subtitle tracks like ffprobe does
+func parseMatroskaTracks(r io.ReaderAt, tracks ebmlElement) ([]probeStream, error) {
+	entries, err := readElements(r, tracks)
+	if err != nil {
+		return nil, err
+	}
+
+	var streams []probeStream
+
+	for _, entry := range entries {
+		if entry.id != trackEntryID {
+			continue
+		}
+
+		s, trackType, err := parseTrackEntry(r, entry)
+		if err != nil {
+			return nil, err
+		}
+
+		codecType, ok := matroskaTypes[trackType]
+		if !ok {
+			continue
+		}
+
+		s.Index, s.CodecType = len(streams), codecType
+		streams = append(streams, s)
+	}
+
+	return streams, nil
+}
+
+// parseTrackEntry parses codec, frame rate, language, flags, frame size and audio parameters of track entry,
+// it returns type of track
+func parseTrackEntry(r io.ReaderAt, entry ebmlElement) (probeStream, uint64, error) {
+	var (
+		s         probeStream
+		trackType uint64
+		codec     string
+		frame     uint64
+	)
+
+	children, err := readElements(r, entry)
+	if err != nil {
+		return s, 0, err
+	}
+
+	// flags and language have default values, ffprobe reports language unless it's undefined
+	flagDefault, flagForced, language := uint64(1), uint64(0), defaultTrackLanguage
+
+	for _, e := range children {
+		switch e.id {
+		case trackTypeID:
+			trackType, err = readUint(r, e)
+		case codecIDID:
+			codec, err = readString(r, e)
+			s.CodecName = matroskaCodec(codec)
+		case defaultDurationID:
+			frame, err = readUint(r, e)
+		case languageID:
+			language, err = readString(r, e)
+		case flagDefaultID:
+			flagDefault, err = readUint(r, e)
+		case flagForcedID:
+			flagForced, err = readUint(r, e)
+		case trackVideoID, trackAudioID:
+			err = parseTrackSettings(r, e, &s)
+		}
+
+		if err != nil {
+			return s, 0, err
+		}
+	}
+
+	if frame != 0 {
+		// default duration of frame is in nanoseconds
+		d := gcd(nanosecondsPerSecond, int(frame))
+		s.AvgFrameRate = fmt.Sprintf("%d/%d", nanosecondsPerSecond/d, int(frame)/d)
+	}
+
+	s.Disposition = map[string]int{"default": int(flagDefault), "forced": int(flagForced)}
+	s.Tags = map[string]string{}
+
+	if language != "und" {
+		s.Tags["language"] = language
+	}
+
+	return s, trackType, nil
+}
+
+// parseTrackSettings parses frame size and pixel aspect ratio of Video element
+// or channels and sample rate of Audio element of track
+func parseTrackSettings(r io.ReaderAt, settings ebmlElement, s *probeStream) error {
+	children, err := readElements(r, settings)
+	if err != nil {
+		return err
+	}
+
+	var displayWidth, displayHeight uint64
+
+	for _, e := range children {
+		var value uint64
+
+		switch e.id {
+		case displayWidthID:
+			displayWidth, err = readUint(r, e)
+		case displayHeightID:
+			displayHeight, err = readUint(r, e)
+		case projectionID:
+			// projection pose may rotate video, ffprobe describes it
+			return errors.New("projection of video is unsupported")
+		case pixelWidthID:
+			value, err = readUint(r, e)
+			s.Width = int(value)
+		case pixelHeightID:
+			value, err = readUint(r, e)
+			s.Height = int(value)
+		case channelsID:
+			value, err = readUint(r, e)
+			s.Channels = int(value)
+		case samplingFrequencyID:
+			var rate float64
+			rate, err = readFloat(r, e)
+			s.SampleRate = fmt.Sprintf("%d", int64(rate))
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	if displayWidth != 0 && displayHeight != 0 && s.Width > 0 && s.Height > 0 {
+		// display size stretches frame like pixel aspect ratio does
+		x, y := int(displayWidth)*s.Height, int(displayHeight)*s.Width
+		d := gcd(x, y)
+		s.SampleAspectRatio = fmt.Sprintf("%d:%d", x/d, y/d)
+	}
+
+	return nil
+}
+
+// matroskaCodec returns ffprobe codec name of Matroska codec id, AAC ids may have profile suffix
+func matroskaCodec(id string) string {
+	if strings.HasPrefix(id, "A_AAC") {
+		return "aac"
+	}
+
+	if codec, ok := matroskaCodecs[id]; ok {
+		return codec
+	}
+
+	return strings.ToLower(id)
+}
+
+// readElements reads headers of children of element e, data of children is skipped
+func readElements(r io.ReaderAt, e ebmlElement) ([]ebmlElement, error) {
+	var children []ebmlElement
+
+	end := e.offset + e.size
+
+	for pos := e.offset; pos < end; {
+		child, err := readElement(r, pos, end)
+		if err != nil {
+			return nil, err
+		}
+
+		if child.size < 0 {
+			return nil, fmt.Errorf("element %X has unknown size", child.id)
+		}
+
+		children = append(children, child)
+		pos = child.offset + child.size
+	}
+
+	return children, nil
+}
+
+// readElement reads header of element at pos, element of known size must end before end
+func readElement(r io.ReaderAt, pos, end int64) (ebmlElement, error) {
+	id, idLength, _, err := readVint(r, pos, true)
+	if err != nil {
+		return ebmlElement{}, err
+	}
+
+	size, sizeLength, unknown, err := readVint(r, pos+int64(idLength), false)
+	if err != nil {
+		return ebmlElement{}, err
+	}
+
+	e := ebmlElement{id: id, offset: pos + int64(idLength+sizeLength), size: int64(size)}
+
+	if unknown {
+		e.size = -1
+
+		return e, nil
+	}
+
+	if size > uint64(end-e.offset) || e.offset > end {
+		return ebmlElement{}, fmt.Errorf("invalid size of element %X", id)
+	}
+
+	return e, nil
+}
+
+// readVint reads EBML variable size integer at pos. Marker bit is kept for element ids,
+// unknown is set for sizes with all value bits set
+func readVint(r io.ReaderAt, pos int64, marker bool) (value uint64, length int, unknown bool, err error) {
+	data := make([]byte, maxEBMLVintLength)
+
+	n, err := r.ReadAt(data, pos)
+	if n == 0 {
+		if err == nil {
+			err = io.ErrUnexpectedEOF
+		}
+
+		return 0, 0, false, err
+	}
+
+	length = bits.LeadingZeros8(data[0]) + 1
+	if length > maxEBMLVintLength || length > n {
+		return 0, 0, false, fmt.Errorf("invalid EBML integer at %d", pos)
+	}
+
+	value = uint64(data[0])
+	if !marker {
+		value &= 0xFF >> length
+	}
+
+	for _, b := range data[1:length] {
+		value = value<<8 | uint64(b)
+	}
+
+	unknown = value == 1<<(7*length)-1
+
+	return value, length, unknown, nil
+}
+
+// readUint reads unsigned integer data of element
+func readUint(r io.ReaderAt, e ebmlElement) (uint64, error) {
+	if e.size > maxEBMLVintLength {
+		return 0, fmt.Errorf("invalid size of integer element %X", e.id)
+	}
+
+	data := make([]byte, e.size)
+	if _, err := r.ReadAt(data, e.offset); err != nil {
+		return 0, err
+	}
+
+	var value uint64
+	for _, b := range data {
+		value = value<<8 | uint64(b)
+	}
+
+	return value, nil
+}
+
+// readFloat reads float data of element, it's 4 or 8 bytes long
+func readFloat(r io.ReaderAt, e ebmlElement) (float64, error) {
+	if e.size != 0 && e.size != 4 && e.size != 8 {
+		return 0, fmt.Errorf("invalid size of float element %X", e.id)
+	}
+
+	data := make([]byte, e.size)
+	if _, err := r.ReadAt(data, e.offset); err != nil {
+		return 0, err
+	}
+
+	switch e.size {
+	case 4:
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(data))), nil
+	case 8:
+		return math.Float64frombits(binary.BigEndian.Uint64(data)), nil
+	}
+
+	return 0, nil
+}
+
+// readString reads string data of element, strings may be padded by zero bytes
+func readString(r io.ReaderAt, e ebmlElement) (string, error) {
+	if e.size > maxEBMLStringSize {
+		return "", fmt.Errorf("string element %X is too long", e.id)
+	}
+
+	data := make([]byte, e.size)
+	if _, err := r.ReadAt(data, e.offset); err != nil {
+		return "", err
+	}
+
+	return string(bytes.TrimRight(data, "\x00")), nil
+}
@@ -0,0 +1,38 @@
+package compressor
+
+import "strings"
+
+// movflags of MP4 output
+const (
+	// faststartFlags move moov atom to the beginning of file, so playback starts before the whole file is downloaded
+	faststartFlags = "+faststart"
+	// fragmentedFlags split file to fragments starting on keyframes, so playback starts from the first fragment
+	fragmentedFlags = "+frag_keyframe+empty_moov+default_base_moof"
+)
+
+// mp4Container reports if file with ext extension is MP4 or QuickTime container
+func mp4Container(ext string) bool {
+	switch strings.ToLower(ext) {
+	case ".mp4", ".m4v", ".mov", ".3gp":
+		return true
+	default:
+		return false
+	}
+}
+
+// movFlags returns movflags of output with ext extension, it's empty for other containers than MP4
+func movFlags(opt *Request, ext string) string {
+	if !mp4Container(ext) {
+		return ""
+	}
+
+	if opt.Fragmented {
+		return fragmentedFlags
+	}
+
+	if opt.FastStart != nil && !*opt.FastStart {
+		return ""
+	}
+
+	return faststartFlags
+}
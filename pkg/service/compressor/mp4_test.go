@@ -0,0 +1,40 @@
+package compressor
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMovFlags(t *testing.T) {
+	disabled := false
+
+	cases := []struct {
+		name     string
+		opt      *Request
+		ext      string
+		expected string
+	}{
+		{name: "MP4 by default", opt: &Request{}, ext: ".mp4", expected: faststartFlags},
+		{name: "QuickTime by default", opt: &Request{}, ext: ".MOV", expected: faststartFlags},
+		{name: "Fragmented MP4", opt: &Request{Fragmented: true}, ext: ".mp4", expected: fragmentedFlags},
+		{name: "Faststart disabled", opt: &Request{FastStart: &disabled}, ext: ".mp4", expected: ""},
+		{name: "Matroska", opt: &Request{Fragmented: true}, ext: ".mkv", expected: ""},
+	}
+
+	for _, testCase := range cases {
+		t.Run(testCase.name, func(t *testing.T) {
+			if flags := movFlags(testCase.opt, testCase.ext); flags != testCase.expected {
+				t.Errorf("Invalid movflags, expected: %s, got: %s\n", testCase.expected, flags)
+			}
+		})
+	}
+}
+
+func TestCutArgsMP4(t *testing.T) {
+	expected := []string{"-i", "in.mp4", "-t", "30",
+		"-map", "0:V", "-map", "0:a?", "-c", "copy", "-avoid_negative_ts", "make_zero", "-movflags", "+faststart", "out.mp4"}
+
+	if args := cutArgs(&Request{Duration: 30}, "in.mp4", "out.mp4"); !reflect.DeepEqual(args, expected) {
+		t.Errorf("Invalid args, expected: %v, got: %v\n", expected, args)
+	}
+}
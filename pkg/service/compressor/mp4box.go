@@ -0,0 +1,558 @@
+package compressor
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+)
+
+const (
+	boxHeaderSize      = 8
+	largeBoxHeaderSize = 16
+	// sampleEntryHeaderSize is a size of sample entry header and reserved fields before its data
+	sampleEntryHeaderSize = 16
+	// visualSampleEntrySize is a size of fields of visual sample entry, boxes like pasp follow them
+	visualSampleEntrySize = 70
+	// trackEnabled flag of tkhd box, ffprobe reports enabled tracks as default ones
+	trackEnabled = 1
+	// macLanguageEnglish is a Macintosh language code used instead of ISO 639-2 code by QuickTime files
+	macLanguageEnglish = 0
+	// packedLanguageMin and unspecifiedLanguage bound packed ISO 639-2 codes of mdhd box
+	packedLanguageMin   = 0x400
+	unspecifiedLanguage = 0x7FFF
+)
+
+// mp4Codecs maps sample entry types to ffprobe codec names
+var mp4Codecs = map[string]string{
+	"avc1": "h264",
+	"avc3": "h264",
+	"hev1": "hevc",
+	"hvc1": "hevc",
+	"av01": "av1",
+	"vp08": "vp8",
+	"vp09": "vp9",
+	"mp4v": "mpeg4",
+	"mp4a": "aac",
+	"ac-3": "ac3",
+	"ec-3": "eac3",
+	"Opus": "opus",
+	"fLaC": "flac",
+	"tx3g": "mov_text",
+	"wvtt": "webvtt",
+}
+
+// mp4Handlers maps handler types of tracks to ffprobe codec types, tracks of other handlers are data streams
+var mp4Handlers = map[string]string{
+	"vide": streamVideo,
+	"soun": streamAudio,
+	"subt": streamSubtitle,
+	"text": streamSubtitle,
+	"sbtl": streamSubtitle,
+}
+
+// mp4Box is a box of ISO base media file, offset and size describe payload of box
+type mp4Box struct {
+	typ    string
+	offset int64
+	size   int64
+}
+
+// mp4Track is a track parsed from trak box
+type mp4Track struct {
+	handler   string
+	entry     string
+	width     int
+	height    int
+	channels  int
+	rate      int
+	timescale uint32
+	duration  uint64
+	bytes     uint64
+	samples   uint32
+	// sarX and sarY is a pixel aspect ratio of pasp box
+	sarX, sarY uint32
+	// rotation of display matrix of tkhd box, clockwise in degrees
+	rotation float64
+	enabled  bool
+	language string
+}
+
+// parseMP4 parses moov box of MP4 file of size bytes. Fragmented files are unsupported,
+// their moov box doesn't describe samples of fragments
+func parseMP4(r io.ReaderAt, size int64) (*probeResult, error) {
+	moov, err := findBox(r, 0, size, "moov")
+	if err != nil {
+		return nil, err
+	}
+
+	boxes, err := readBoxes(r, moov.offset, moov.offset+moov.size)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &probeResult{Format: probeFormat{FormatName: mp4FormatName}}
+
+	for _, b := range boxes {
+		switch b.typ {
+		case "mvex":
+			return nil, errors.New("fragmented mp4 is unsupported")
+		case "mvhd":
+			timescale, duration, err := readMediaHeader(r, b)
+			if err != nil {
+				return nil, err
+			}
+
+			p.Format.Duration = formatSeconds(float64(duration) / float64(timescale))
+		case "trak":
+			track, err := parseTrack(r, b)
+			if err != nil {
+				return nil, err
+			}
+
+			codecType, ok := mp4Handlers[track.handler]
+			if !ok {
+				codecType = streamData
+			}
+
+			p.Streams = append(p.Streams, track.stream(len(p.Streams), codecType))
+		}
+	}
+
+	if p.Format.Duration == "" {
+		return nil, errors.New("mvhd box not found")
+	}
+
+	return p, nil
+}
+
+// stream describes track like ffprobe does
+func (t *mp4Track) stream(index int, codecType string) probeStream {
+	codec, ok := mp4Codecs[t.entry]
+	if !ok {
+		codec = strings.ToLower(strings.TrimSpace(t.entry))
+	}
+
+	s := probeStream{Index: index, CodecType: codecType, CodecName: codec, Tags: map[string]string{}}
+
+	if t.enabled {
+		s.Disposition = map[string]int{"default": 1}
+	}
+
+	if t.language != "" {
+		s.Tags["language"] = t.language
+	}
+
+	switch codecType {
+	case streamVideo:
+		s.Width, s.Height = t.width, t.height
+
+		if t.sarX != 0 && t.sarY != 0 {
+			s.SampleAspectRatio = fmt.Sprintf("%d:%d", t.sarX, t.sarY)
+		}
+
+		if t.samples != 0 && t.duration != 0 {
+			// average frame rate is a number of samples per duration of track like ffprobe reports it
+			num, den := int(t.samples)*int(t.timescale), int(t.duration)
+			d := gcd(num, den)
+			s.AvgFrameRate = fmt.Sprintf("%d/%d", num/d, den/d)
+		}
+
+		if t.rotation != 0 {
+			s.Tags["rotate"] = formatNumber(t.rotation)
+		}
+	case streamAudio:
+		s.Channels = t.channels
+		if t.rate != 0 {
+			s.SampleRate = fmt.Sprintf("%d", t.rate)
+		}
+	}
+
+	if t.timescale != 0 && t.duration != 0 {
+		seconds := float64(t.duration) / float64(t.timescale)
+		s.Duration = formatSeconds(seconds)
+		s.BitRate = fmt.Sprintf("%d", int64(float64(t.bytes)*8/seconds))
+	}
+
+	return s
+}
+
+// parseTrack parses display matrix, handler, sample entry, duration and size of samples of trak box
+func parseTrack(r io.ReaderAt, trak mp4Box) (*mp4Track, error) {
+	track := new(mp4Track)
+
+	boxes, err := readBoxes(r, trak.offset, trak.offset+trak.size)
+	if err != nil {
+		return nil, err
+	}
+
+	mdia := false
+
+	for _, b := range boxes {
+		switch b.typ {
+		case "tkhd":
+			err = parseTrackHeader(r, b, track)
+		case "mdia":
+			mdia = true
+			err = parseMedia(r, b, track)
+		}
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if !mdia {
+		return nil, errors.New("mdia box not found")
+	}
+
+	return track, nil
+}
+
+// parseTrackHeader parses enabled flag and rotation of display matrix of tkhd box
+func parseTrackHeader(r io.ReaderAt, tkhd mp4Box, track *mp4Track) error {
+	data, err := readPayload(r, tkhd, 4)
+	if err != nil {
+		return err
+	}
+
+	// version 1 has 64-bit times and duration, reserved, layer, group and volume precede matrix
+	matrixOffset := int64(40)
+	if data[0] == 1 {
+		matrixOffset = 52
+	}
+
+	track.enabled = data[3]&trackEnabled != 0
+
+	if data, err = readPayload(r, tkhd, matrixOffset+8); err != nil {
+		return err
+	}
+
+	// a and b of matrix are 16.16 fixed point numbers, the rest of matrix doesn't change rotation
+	a := float64(int32(binary.BigEndian.Uint32(data[matrixOffset : matrixOffset+4])))
+	b := float64(int32(binary.BigEndian.Uint32(data[matrixOffset+4 : matrixOffset+8])))
+
+	track.rotation = math.Round(math.Atan2(b, a) * 180 / math.Pi)
+
+	return nil
+}
+
+// parseMedia parses language, timescale, duration, handler and sample table of mdia box
+func parseMedia(r io.ReaderAt, mdia mp4Box, track *mp4Track) error {
+	boxes, err := readBoxes(r, mdia.offset, mdia.offset+mdia.size)
+	if err != nil {
+		return err
+	}
+
+	for _, b := range boxes {
+		switch b.typ {
+		case "mdhd":
+			if track.timescale, track.duration, err = readMediaHeader(r, b); err != nil {
+				return err
+			}
+
+			if track.language, err = readLanguage(r, b); err != nil {
+				return err
+			}
+		case "hdlr":
+			// version, flags and pre_defined precede handler type
+			data, err := readPayload(r, b, 12)
+			if err != nil {
+				return err
+			}
+
+			track.handler = string(data[8:12])
+		case "minf":
+			if err = parseSampleTable(r, b, track); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// parseSampleTable parses sample entry and sample sizes of minf box
+func parseSampleTable(r io.ReaderAt, minf mp4Box, track *mp4Track) error {
+	stbl, err := findBox(r, minf.offset, minf.offset+minf.size, "stbl")
+	if err != nil {
+		return err
+	}
+
+	boxes, err := readBoxes(r, stbl.offset, stbl.offset+stbl.size)
+	if err != nil {
+		return err
+	}
+
+	for _, b := range boxes {
+		switch b.typ {
+		case "stsd":
+			if err = parseSampleEntry(r, b, track); err != nil {
+				return err
+			}
+		case "stsz":
+			if track.samples, track.bytes, err = readSampleSizes(r, b); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// parseSampleEntry parses type of the first sample entry, frame size and pixel aspect ratio of visual entry
+// and channels, sample rate of audio entry
+func parseSampleEntry(r io.ReaderAt, stsd mp4Box, track *mp4Track) error {
+	// version, flags and entry count precede entries
+	const entriesOffset = 8
+
+	data, err := readPayload(r, stsd, entriesOffset+sampleEntryHeaderSize)
+	if err != nil {
+		return err
+	}
+
+	track.entry = string(data[entriesOffset+4 : entriesOffset+8])
+
+	if track.handler != "vide" && track.handler != "soun" {
+		return nil
+	}
+
+	if data, err = readPayload(r, stsd, entriesOffset+sampleEntryHeaderSize+20); err != nil {
+		return err
+	}
+
+	fields := data[entriesOffset+sampleEntryHeaderSize:]
+
+	if track.handler == "vide" {
+		// pre_defined and reserved fields precede width and height
+		track.width = int(binary.BigEndian.Uint16(fields[16:18]))
+		track.height = int(binary.BigEndian.Uint16(fields[18:20]))
+
+		return parsePixelAspect(r, stsd, int64(binary.BigEndian.Uint32(data[entriesOffset:])), track)
+	} else {
+		// reserved fields precede channel count, sample rate is 16.16 fixed point number
+		track.channels = int(binary.BigEndian.Uint16(fields[8:10]))
+		track.rate = int(binary.BigEndian.Uint32(fields[16:20]) >> 16)
+	}
+
+	return nil
+}
+
+// parsePixelAspect parses pasp box of visual sample entry of entrySize bytes, the first entry of stsd box
+func parsePixelAspect(r io.ReaderAt, stsd mp4Box, entrySize int64, track *mp4Track) error {
+	// version, flags and entry count precede entries
+	const entriesOffset = 8
+
+	start := stsd.offset + entriesOffset + sampleEntryHeaderSize + visualSampleEntrySize
+	end := stsd.offset + entriesOffset + entrySize
+
+	if end > stsd.offset+stsd.size {
+		return errors.New("invalid size of sample entry")
+	}
+
+	boxes, err := readBoxes(r, start, end)
+	if err != nil {
+		return err
+	}
+
+	for _, b := range boxes {
+		if b.typ != "pasp" {
+			continue
+		}
+
+		data, err := readPayload(r, b, 8)
+		if err != nil {
+			return err
+		}
+
+		track.sarX, track.sarY = binary.BigEndian.Uint32(data[:4]), binary.BigEndian.Uint32(data[4:8])
+	}
+
+	return nil
+}
+
+// readSampleSizes returns number and total size of samples described by stsz box
+func readSampleSizes(r io.ReaderAt, stsz mp4Box) (uint32, uint64, error) {
+	data, err := readPayload(r, stsz, 12)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	sampleSize := binary.BigEndian.Uint32(data[4:8])
+	count := binary.BigEndian.Uint32(data[8:12])
+
+	if sampleSize != 0 {
+		return count, uint64(sampleSize) * uint64(count), nil
+	}
+
+	if int64(count)*4 > stsz.size-12 {
+		return 0, 0, errors.New("invalid stsz box")
+	}
+
+	sizes := make([]byte, int64(count)*4)
+	if _, err = r.ReadAt(sizes, stsz.offset+12); err != nil {
+		return 0, 0, err
+	}
+
+	var total uint64
+	for i := 0; i < len(sizes); i += 4 {
+		total += uint64(binary.BigEndian.Uint32(sizes[i : i+4]))
+	}
+
+	return count, total, nil
+}
+
+// readMediaHeader returns timescale and duration of mvhd or mdhd box, both boxes have the same layout of them
+func readMediaHeader(r io.ReaderAt, b mp4Box) (uint32, uint64, error) {
+	data, err := readPayload(r, b, 4)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	var (
+		timescale uint32
+		duration  uint64
+	)
+
+	if data[0] == 1 {
+		// version 1 has 64-bit creation time, modification time and duration
+		if data, err = readPayload(r, b, 32); err != nil {
+			return 0, 0, err
+		}
+
+		timescale = binary.BigEndian.Uint32(data[20:24])
+		duration = binary.BigEndian.Uint64(data[24:32])
+	} else {
+		if data, err = readPayload(r, b, 20); err != nil {
+			return 0, 0, err
+		}
+
+		timescale = binary.BigEndian.Uint32(data[12:16])
+		duration = uint64(binary.BigEndian.Uint32(data[16:20]))
+	}
+
+	if timescale == 0 {
+		return 0, 0, fmt.Errorf("invalid timescale of %s box", b.typ)
+	}
+
+	return timescale, duration, nil
+}
+
+// readLanguage returns ISO 639-2 language of mdhd box, it's empty when track language is unspecified
+func readLanguage(r io.ReaderAt, mdhd mp4Box) (string, error) {
+	data, err := readPayload(r, mdhd, 4)
+	if err != nil {
+		return "", err
+	}
+
+	// language follows times, timescale and duration, they are 64-bit in version 1
+	offset := int64(20)
+	if data[0] == 1 {
+		offset = 32
+	}
+
+	if data, err = readPayload(r, mdhd, offset+2); err != nil {
+		return "", err
+	}
+
+	code := binary.BigEndian.Uint16(data[offset:])
+
+	switch {
+	case code == macLanguageEnglish:
+		return "eng", nil
+	case code < packedLanguageMin || code == unspecifiedLanguage:
+		return "", nil
+	}
+
+	// three letters are packed by 5 bits as offsets from 0x60
+	return string([]byte{byte(code>>10&0x1F) + 0x60, byte(code>>5&0x1F) + 0x60, byte(code&0x1F) + 0x60}), nil
+}
+
+// readPayload reads the first n bytes of payload of box
+func readPayload(r io.ReaderAt, b mp4Box, n int64) ([]byte, error) {
+	if b.size < n {
+		return nil, fmt.Errorf("%s box is too short", b.typ)
+	}
+
+	data := make([]byte, n)
+	if _, err := r.ReadAt(data, b.offset); err != nil {
+		return nil, err
+	}
+
+	return data, nil
+}
+
+// findBox returns the first box of typ between start and end
+func findBox(r io.ReaderAt, start, end int64, typ string) (mp4Box, error) {
+	boxes, err := readBoxes(r, start, end)
+	if err != nil {
+		return mp4Box{}, err
+	}
+
+	for _, b := range boxes {
+		if b.typ == typ {
+			return b, nil
+		}
+	}
+
+	return mp4Box{}, fmt.Errorf("%s box not found", typ)
+}
+
+// readBoxes reads headers of sequence of boxes between start and end, payloads are skipped
+func readBoxes(r io.ReaderAt, start, end int64) ([]mp4Box, error) {
+	var boxes []mp4Box
+
+	header := make([]byte, largeBoxHeaderSize)
+
+	for pos := start; pos+boxHeaderSize <= end; {
+		if _, err := r.ReadAt(header[:boxHeaderSize], pos); err != nil {
+			return nil, err
+		}
+
+		size := int64(binary.BigEndian.Uint32(header[:4]))
+		headerSize := int64(boxHeaderSize)
+
+		switch size {
+		case 0:
+			// box extends to the end of file
+			size = end - pos
+		case 1:
+			if _, err := r.ReadAt(header[boxHeaderSize:], pos+boxHeaderSize); err != nil {
+				return nil, err
+			}
+
+			size = int64(binary.BigEndian.Uint64(header[boxHeaderSize:]))
+			headerSize = largeBoxHeaderSize
+		}
+
+		if size < headerSize || pos+size > end {
+			return nil, fmt.Errorf("invalid size of %s box", header[4:8])
+		}
+
+		boxes = append(boxes, mp4Box{typ: string(header[4:8]), offset: pos + headerSize, size: size - headerSize})
+		pos += size
+	}
+
+	return boxes, nil
+}
+
+// moovFirst reports if moov box of MP4 file of size bytes precedes media data like faststart output has
+func moovFirst(r io.ReaderAt, size int64) (bool, error) {
+	boxes, err := readBoxes(r, 0, size)
+	if err != nil {
+		return false, err
+	}
+
+	for _, b := range boxes {
+		switch b.typ {
+		case "moov":
+			return true, nil
+		case "mdat":
+			return false, nil
+		}
+	}
+
+	return false, errors.New("moov box not found")
+}
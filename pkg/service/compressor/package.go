@@ -0,0 +1,219 @@
+package compressor
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+
+	"github.com/Hargeon/compressrv/pkg/response"
+)
+
+const (
+	defaultSegmentDuration = 6
+	defaultAudioBitrate    = 128000
+	peakBitrateRatio       = 1.07 // maxrate relative to average bitrate of variant
+	bufferBitrateRatio     = 1.5  // bufsize relative to average bitrate of variant
+	dirPerm                = 0o755
+)
+
+// defaultLadder is used when request doesn't contain own ladder
+var defaultLadder = []Variant{
+	{Height: 1080, Bitrate: 5000000, AudioBitrate: defaultAudioBitrate},
+	{Height: 720, Bitrate: 2800000, AudioBitrate: defaultAudioBitrate},
+	{Height: 480, Bitrate: 1400000, AudioBitrate: defaultAudioBitrate},
+	{Height: 360, Bitrate: 800000, AudioBitrate: 96000},
+}
+
+// rendition is a Variant with calculated frame size and H.264 level
+type rendition struct {
+	Variant
+	name   string
+	width  int
+	height int
+	// level is a level_idc of H.264, encoder chooses it when zero
+	level int
+}
+
+// peakBitrate returns maximum bitrate of video stream
+func (r rendition) peakBitrate() int64 {
+	return int64(float64(r.Bitrate) * peakBitrateRatio)
+}
+
+// bandwidth returns maximum bitrate of rendition with audio
+func (r rendition) bandwidth() int64 {
+	return r.peakBitrate() + r.AudioBitrate
+}
+
+// videoArgs returns ffmpeg options for encoding video of rendition.
+// stream is a suffix of output stream specifier (e.g. ":0"), it's empty when output has one video stream
+func (r rendition) videoArgs(stream string) []string {
+	args := []string{
+		"-filter:v" + stream, fmt.Sprintf("scale=%d:%d", r.width, r.height),
+		"-b:v" + stream, strconv.FormatInt(r.Bitrate, decimal),
+		"-maxrate:v" + stream, strconv.FormatInt(r.peakBitrate(), decimal),
+		"-bufsize:v" + stream, strconv.FormatInt(int64(float64(r.Bitrate)*bufferBitrateRatio), decimal),
+	}
+
+	if r.level != 0 {
+		// level is declared by codecs of variant, so encoder has to keep it
+		args = append(args, "-level:v"+stream, formatLevel(r.level))
+	}
+
+	return args
+}
+
+// encodeArgs returns ffmpeg options shared by all video streams of adaptive streaming package
+func encodeArgs(segmentDuration int) []string {
+	args := []string{
+		"-c:v", "libx264",
+		"-profile:v", "high",
+		"-pix_fmt", "yuv420p",
+		// keyframes on segment borders keep segments of all variants aligned
+		"-force_key_frames", fmt.Sprintf("expr:gte(t,n_forced*%d)", segmentDuration),
+		"-sc_threshold", "0",
+	}
+
+	return append(args, clearRotationArgs...)
+}
+
+// audioArgs returns ffmpeg options for encoding audio of adaptive streaming package
+func audioArgs(bitrate int64) []string {
+	return []string{
+		"-c:a", "aac",
+		"-b:a", strconv.FormatInt(bitrate, decimal),
+		"-ac", "2",
+	}
+}
+
+// Package encodes originalVideo to adaptive streaming package.
+// It returns directory with package files and description of package with paths relative to the directory
+func (c *Compressor) Package(ctx context.Context, opt *Request, originalVideo string) (string, *response.Package, error) {
+	info, err := c.VideoInfo(originalVideo)
+	if err != nil {
+		return "", nil, err
+	}
+
+	renditions := buildRenditions(ladder(opt), info.ResolutionX, info.ResolutionY)
+
+	if opt.AutoBitrate != nil {
+		selected, err := c.selectedBitrate(ctx, opt, originalVideo)
+		if err != nil {
+			return "", nil, err
+		}
+
+		renditions = scaleRenditions(renditions, selected.Scale)
+	}
+
+	renditions = withLevels(renditions, info.FPS)
+
+	segmentDuration := opt.SegmentDuration
+	if segmentDuration == 0 {
+		segmentDuration = defaultSegmentDuration
+	}
+
+	dir := packageDir(opt, originalVideo, opt.Output)
+	if err = os.RemoveAll(dir); err != nil {
+		return "", nil, err
+	}
+
+	if err = os.MkdirAll(dir, dirPerm); err != nil {
+		return "", nil, err
+	}
+
+	var pkg *response.Package
+
+	switch opt.Output {
+	case OutputHLS:
+		pkg, err = c.hls(ctx, originalVideo, dir, renditions, segmentDuration, trimArgs(opt))
+	case OutputDASH:
+		pkg, err = c.dash(ctx, originalVideo, dir, renditions, segmentDuration, trimArgs(opt))
+	default:
+		err = fmt.Errorf("output %q is not adaptive", opt.Output)
+	}
+
+	if err != nil {
+		os.RemoveAll(dir)
+
+		return "", nil, err
+	}
+
+	return dir, pkg, nil
+}
+
+// ladder returns ladder of request or default one
+func ladder(opt *Request) []Variant {
+	if len(opt.Ladder) == 0 {
+		return defaultLadder
+	}
+
+	return opt.Ladder
+}
+
+// buildRenditions calculates frame size of ladder variants for video with width x height frame.
+// Variants higher than original video are skipped, the lowest one is kept with original height.
+// Names of renditions come from rounded height, so only the first variant of the same rounded height is kept
+func buildRenditions(ladder []Variant, width, height int) []rendition {
+	sorted := make([]Variant, len(ladder))
+	copy(sorted, ladder)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Height > sorted[j].Height })
+
+	renditions := make([]rendition, 0, len(sorted))
+	seen := make(map[int]bool)
+
+	for _, v := range sorted {
+		if v.Height > height {
+			continue
+		}
+
+		r := newRendition(v, width, height)
+		if seen[r.height] {
+			continue
+		}
+
+		seen[r.height] = true
+		renditions = append(renditions, r)
+	}
+
+	if len(renditions) == 0 && len(sorted) != 0 {
+		lowest := sorted[len(sorted)-1]
+		lowest.Height = height
+		renditions = append(renditions, newRendition(lowest, width, height))
+	}
+
+	return renditions
+}
+
+// newRendition scales width x height frame to the height of variant keeping aspect ratio
+func newRendition(v Variant, width, height int) rendition {
+	if v.AudioBitrate == 0 {
+		v.AudioBitrate = defaultAudioBitrate
+	}
+
+	h := evenDimension(float64(v.Height))
+
+	return rendition{
+		Variant: v,
+		name:    fmt.Sprintf("%dp", h),
+		width:   evenDimension(float64(width) * float64(h) / float64(height)),
+		height:  h,
+	}
+}
+
+// evenDimension rounds frame dimension to the nearest even number, most of encoders require it
+func evenDimension(d float64) int {
+	even := int(math.Round(d/2)) * 2
+	if even < 2 {
+		return 2
+	}
+
+	return even
+}
+
+// packageDir returns directory for set of files (e.g. adaptive streaming package) made from originalVideo
+func packageDir(opt *Request, originalVideo, format string) string {
+	return workPath(opt, filepath.Base(originalVideo)+"_"+format)
+}
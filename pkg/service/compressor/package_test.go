@@ -0,0 +1,163 @@
+package compressor
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestBuildRenditions(t *testing.T) {
+	cases := []struct {
+		name   string
+		ladder []Variant
+		width  int
+		height int
+
+		expectedNames []string
+		expectedSizes [][2]int
+	}{
+		{
+			name:          "Full HD video with default ladder",
+			ladder:        defaultLadder,
+			width:         1920,
+			height:        1080,
+			expectedNames: []string{"1080p", "720p", "480p", "360p"},
+			expectedSizes: [][2]int{{1920, 1080}, {1280, 720}, {854, 480}, {640, 360}},
+		},
+		{
+			name:          "Variants higher than video are skipped",
+			ladder:        defaultLadder,
+			width:         800,
+			height:        600,
+			expectedNames: []string{"480p", "360p"},
+			expectedSizes: [][2]int{{640, 480}, {480, 360}},
+		},
+		{
+			name:          "Video lower than all variants",
+			ladder:        defaultLadder,
+			width:         320,
+			height:        240,
+			expectedNames: []string{"240p"},
+			expectedSizes: [][2]int{{320, 240}},
+		},
+		{
+			name: "Duplicated and unsorted variants",
+			ladder: []Variant{
+				{Height: 360, Bitrate: 800000},
+				{Height: 720, Bitrate: 2800000},
+				{Height: 360, Bitrate: 600000},
+			},
+			width:         1080,
+			height:        1920,
+			expectedNames: []string{"720p", "360p"},
+			expectedSizes: [][2]int{{406, 720}, {202, 360}},
+		},
+		{
+			name: "Variants of the same rounded height",
+			ladder: []Variant{
+				{Height: 719, Bitrate: 2500000},
+				{Height: 720, Bitrate: 2800000},
+			},
+			width:         1280,
+			height:        720,
+			expectedNames: []string{"720p"},
+			expectedSizes: [][2]int{{1280, 720}},
+		},
+	}
+
+	for _, testCase := range cases {
+		t.Run(testCase.name, func(t *testing.T) {
+			renditions := buildRenditions(testCase.ladder, testCase.width, testCase.height)
+
+			names := make([]string, 0, len(renditions))
+			sizes := make([][2]int, 0, len(renditions))
+
+			for _, r := range renditions {
+				names = append(names, r.name)
+				sizes = append(sizes, [2]int{r.width, r.height})
+
+				if r.AudioBitrate == 0 {
+					t.Errorf("Audio bitrate of %s should be set\n", r.name)
+				}
+			}
+
+			if !reflect.DeepEqual(names, testCase.expectedNames) {
+				t.Errorf("Invalid renditions, expected: %v, got: %v\n", testCase.expectedNames, names)
+			}
+
+			if !reflect.DeepEqual(sizes, testCase.expectedSizes) {
+				t.Errorf("Invalid sizes, expected: %v, got: %v\n", testCase.expectedSizes, sizes)
+			}
+		})
+	}
+}
+
+func TestWithLevels(t *testing.T) {
+	cases := []struct {
+		name     string
+		variant  Variant
+		width    int
+		height   int
+		fps      float64
+		expected int
+		codecs   string
+	}{
+		{
+			name:     "360p",
+			variant:  Variant{Height: 360, Bitrate: 800000},
+			width:    640,
+			height:   360,
+			fps:      30,
+			expected: 30,
+			codecs:   "avc1.64001E,mp4a.40.2",
+		},
+		{
+			name:     "1080p at 30 fps",
+			variant:  Variant{Height: 1080, Bitrate: 5000000},
+			width:    1920,
+			height:   1080,
+			fps:      30,
+			expected: 40,
+			codecs:   "avc1.640028,mp4a.40.2",
+		},
+		{
+			name:     "1080p at 60 fps",
+			variant:  Variant{Height: 1080, Bitrate: 5000000},
+			width:    1920,
+			height:   1080,
+			fps:      60,
+			expected: 42,
+			codecs:   "avc1.64002A,mp4a.40.2",
+		},
+		{
+			name:     "720p of unknown frame rate",
+			variant:  Variant{Height: 720, Bitrate: 2800000},
+			width:    1280,
+			height:   720,
+			expected: 32,
+			codecs:   "avc1.640020,mp4a.40.2",
+		},
+		{
+			name:     "High bitrate",
+			variant:  Variant{Height: 720, Bitrate: 30000000},
+			width:    1280,
+			height:   720,
+			fps:      30,
+			expected: 41,
+			codecs:   "avc1.640029,mp4a.40.2",
+		},
+	}
+
+	for _, testCase := range cases {
+		t.Run(testCase.name, func(t *testing.T) {
+			r := withLevels([]rendition{newRendition(testCase.variant, testCase.width, testCase.height)}, testCase.fps)[0]
+
+			if r.level != testCase.expected {
+				t.Errorf("Invalid level, expected: %d, got: %d\n", testCase.expected, r.level)
+			}
+
+			if codecs := r.codecs(true); codecs != testCase.codecs {
+				t.Errorf("Invalid codecs, expected: %s, got: %s\n", testCase.codecs, codecs)
+			}
+		})
+	}
+}
@@ -0,0 +1,125 @@
+package compressor
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+)
+
+// Presets are named encoding options, each preset is a json of Request
+type Presets map[string]json.RawMessage
+
+// LoadPresets reads presets from json file at path, there are no presets when path is empty
+func LoadPresets(path string) (Presets, error) {
+	if path == "" {
+		return Presets{}, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	presets := Presets{}
+	if err = json.Unmarshal(data, &presets); err != nil {
+		return nil, fmt.Errorf("invalid presets file: %w", err)
+	}
+
+	for name := range presets {
+		preset, err := presets.preset(name)
+		if err != nil {
+			return nil, err
+		}
+
+		if preset.Preset != "" {
+			return nil, fmt.Errorf("preset %q can't refer to other preset", name)
+		}
+
+		if err = preset.Validate(); err != nil {
+			return nil, fmt.Errorf("invalid preset %q: %w", name, err)
+		}
+	}
+
+	return presets, nil
+}
+
+// Apply fills options of req which aren't set explicitly from preset req.Preset
+func (p Presets) Apply(req *Request) error {
+	if req.Preset == "" {
+		return nil
+	}
+
+	preset, err := p.preset(req.Preset)
+	if err != nil {
+		return err
+	}
+
+	merge(req, preset)
+
+	return nil
+}
+
+// preset decodes preset with name, each call returns new Request, so requests don't share options
+func (p Presets) preset(name string) (*Request, error) {
+	raw, ok := p[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown preset %q", name)
+	}
+
+	preset := new(Request)
+	if err := json.Unmarshal(raw, preset); err != nil {
+		return nil, fmt.Errorf("invalid preset %q: %w", name, err)
+	}
+
+	return preset, nil
+}
+
+// rateControlOptions are json names of options which select bitrate of converted video,
+// only one of them is used, so preset's ones are skipped when request sets any of them
+var rateControlOptions = map[string]bool{"bitrate": true, "max_size_bytes": true, "auto_bitrate": true}
+
+// merge sets options of req which aren't set to values of preset.
+// Option is set when it's present in decoded json of req, zero options are unset in requests built in code
+func merge(req, preset *Request) {
+	dst := reflect.ValueOf(req).Elem()
+	src := reflect.ValueOf(preset).Elem()
+	rateControl := false
+
+	for i := 0; i < dst.NumField(); i++ {
+		if name := jsonName(dst.Type().Field(i)); rateControlOptions[name] && req.isSet(name, dst.Field(i)) {
+			rateControl = true
+		}
+	}
+
+	for i := 0; i < dst.NumField(); i++ {
+		field := dst.Field(i)
+		name := jsonName(dst.Type().Field(i))
+
+		if !field.CanSet() || name == "" || req.isSet(name, field) || (rateControl && rateControlOptions[name]) {
+			continue
+		}
+
+		field.Set(src.Field(i))
+	}
+}
+
+// isSet reports if option with json name is set in request, field is a value of the option
+func (r *Request) isSet(name string, field reflect.Value) bool {
+	if r.present != nil {
+		return r.present[name]
+	}
+
+	return !field.IsZero()
+}
+
+// jsonName returns json name of field, it's empty for fields which aren't decoded from json
+func jsonName(field reflect.StructField) string {
+	name := strings.Split(field.Tag.Get("json"), ",")[0]
+	if name == "-" {
+		return ""
+	}
+
+	return name
+}
@@ -0,0 +1,194 @@
+package compressor
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestLoadPresets(t *testing.T) {
+	cases := []struct {
+		name         string
+		data         string
+		errorPresent bool
+	}{
+		{
+			name: "Valid presets",
+			data: `{"mobile": {"bitrate": 800000, "resolution": "max:-1x480"}}`,
+		},
+		{
+			name:         "Invalid json",
+			data:         `{"mobile": `,
+			errorPresent: true,
+		},
+		{
+			name:         "Invalid preset options",
+			data:         `{"mobile": {"resolution": "full hd"}}`,
+			errorPresent: true,
+		},
+		{
+			name:         "Preset refers to other preset",
+			data:         `{"mobile": {"preset": "web-hd"}, "web-hd": {"bitrate": 4000000}}`,
+			errorPresent: true,
+		},
+	}
+
+	for _, testCase := range cases {
+		t.Run(testCase.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "presets.json")
+			if err := os.WriteFile(path, []byte(testCase.data), 0o600); err != nil {
+				t.Fatalf("Unexpected error: %s\n", err)
+			}
+
+			_, err := LoadPresets(path)
+			if err != nil && !testCase.errorPresent {
+				t.Errorf("Unexpected error: %s\n", err)
+			}
+
+			if err == nil && testCase.errorPresent {
+				t.Errorf("Should be error\n")
+			}
+		})
+	}
+
+	if _, err := LoadPresets(filepath.Join("..", "..", "..", "presets.json")); err != nil {
+		t.Errorf("Invalid presets.json of the project: %s\n", err)
+	}
+}
+
+func TestPresetsApply(t *testing.T) {
+	presets := Presets{
+		"mobile": []byte(`{"bitrate": 800000, "resolution": "max:-1x480", "max_fps": 30,
+			"thumbnails": {"poster": true}}`),
+	}
+
+	cases := []struct {
+		name         string
+		req          *Request
+		expected     *Request
+		errorPresent bool
+	}{
+		{
+			name:     "Without preset",
+			req:      &Request{Bitrate: 64000},
+			expected: &Request{Bitrate: 64000},
+		},
+		{
+			name: "Preset with overrides",
+			req:  &Request{Preset: "mobile", VideoID: 1, Resolution: "360p"},
+			expected: &Request{
+				Preset:     "mobile",
+				VideoID:    1,
+				Bitrate:    800000,
+				Resolution: "360p",
+				MaxFPS:     30,
+				Thumbnails: &ThumbnailOptions{Poster: true},
+			},
+		},
+		{
+			name: "Rate control of request",
+			req:  &Request{Preset: "mobile", MaxSizeBytes: 1000000},
+			expected: &Request{
+				Preset:       "mobile",
+				MaxSizeBytes: 1000000,
+				Resolution:   "max:-1x480",
+				MaxFPS:       30,
+				Thumbnails:   &ThumbnailOptions{Poster: true},
+			},
+		},
+		{
+			name:         "Unknown preset",
+			req:          &Request{Preset: "archive"},
+			expected:     &Request{Preset: "archive"},
+			errorPresent: true,
+		},
+	}
+
+	for _, testCase := range cases {
+		t.Run(testCase.name, func(t *testing.T) {
+			err := presets.Apply(testCase.req)
+			if err != nil && !testCase.errorPresent {
+				t.Errorf("Unexpected error: %s\n", err)
+			}
+
+			if err == nil && testCase.errorPresent {
+				t.Errorf("Should be error\n")
+			}
+
+			if !reflect.DeepEqual(testCase.req, testCase.expected) {
+				t.Errorf("Invalid request, expected: %+v, got: %+v\n", testCase.expected, testCase.req)
+			}
+		})
+	}
+
+	first, second := &Request{Preset: "mobile"}, &Request{Preset: "mobile"}
+	if err := presets.Apply(first); err != nil {
+		t.Fatalf("Unexpected error: %s\n", err)
+	}
+
+	if err := presets.Apply(second); err != nil {
+		t.Fatalf("Unexpected error: %s\n", err)
+	}
+
+	if first.Thumbnails == second.Thumbnails {
+		t.Errorf("Requests share options of preset\n")
+	}
+}
+
+func TestPresetsApplyDecoded(t *testing.T) {
+	presets := Presets{
+		"web-hd": []byte(`{"bitrate": 4000000, "resolution": "max:-1x1080", "no_upscale": true}`),
+	}
+
+	cases := []struct {
+		name     string
+		body     string
+		expected *Request
+	}{
+		{
+			name:     "Options of preset",
+			body:     `{"preset": "web-hd"}`,
+			expected: &Request{Preset: "web-hd", Bitrate: 4000000, Resolution: "max:-1x1080", NoUpscale: true},
+		},
+		{
+			name:     "Explicit false",
+			body:     `{"preset": "web-hd", "no_upscale": false}`,
+			expected: &Request{Preset: "web-hd", Bitrate: 4000000, Resolution: "max:-1x1080"},
+		},
+		{
+			name:     "Max size instead of bitrate",
+			body:     `{"preset": "web-hd", "max_size_bytes": 10000000}`,
+			expected: &Request{Preset: "web-hd", MaxSizeBytes: 10000000, Resolution: "max:-1x1080", NoUpscale: true},
+		},
+		{
+			name: "Auto bitrate instead of bitrate",
+			body: `{"preset": "web-hd", "auto_bitrate": {"quality": 20}}`,
+			expected: &Request{Preset: "web-hd", AutoBitrate: &AutoBitrateOptions{Quality: 20},
+				Resolution: "max:-1x1080", NoUpscale: true},
+		},
+	}
+
+	for _, testCase := range cases {
+		t.Run(testCase.name, func(t *testing.T) {
+			req := new(Request)
+			if err := json.Unmarshal([]byte(testCase.body), req); err != nil {
+				t.Fatalf("Unexpected error: %s\n", err)
+			}
+
+			if err := presets.Apply(req); err != nil {
+				t.Fatalf("Unexpected error: %s\n", err)
+			}
+
+			if err := req.Validate(); err != nil {
+				t.Errorf("Unexpected error: %s\n", err)
+			}
+
+			req.present = nil
+			if !reflect.DeepEqual(req, testCase.expected) {
+				t.Errorf("Invalid request, expected: %+v, got: %+v\n", testCase.expected, req)
+			}
+		})
+	}
+}
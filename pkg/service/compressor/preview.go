@@ -0,0 +1,166 @@
+package compressor
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Hargeon/compressrv/pkg/response"
+)
+
+const (
+	defaultPreviewSegments        = 5
+	defaultPreviewSegmentDuration = 1.5
+	defaultPreviewWidth           = 320
+	defaultPreviewFPS             = 10
+	previewName                   = "preview"
+	webpQuality                   = "70"
+)
+
+// Preview cuts short clips from across originalVideo and joins them to muted animated previews.
+// It returns directory with previews and description of previews with paths relative to the directory
+func (c *Compressor) Preview(ctx context.Context, opt *Request, originalVideo string) (string, *response.Preview, error) {
+	duration, err := c.videoDuration(originalVideo)
+	if err != nil {
+		return "", nil, err
+	}
+
+	dir := packageDir(opt, originalVideo, previewName)
+	if err = os.RemoveAll(dir); err != nil {
+		return "", nil, err
+	}
+
+	if err = os.MkdirAll(dir, dirPerm); err != nil {
+		return "", nil, err
+	}
+
+	preview, err := c.preview(ctx, opt.Preview, originalVideo, dir, duration)
+	if err != nil {
+		os.RemoveAll(dir)
+
+		return "", nil, err
+	}
+
+	return dir, preview, nil
+}
+
+// preview makes MP4 preview and converts it to other formats of opt
+func (c *Compressor) preview(ctx context.Context, opt *PreviewOptions, originalVideo, dir string,
+	duration float64) (*response.Preview, error) {
+	segments := opt.Segments
+	if segments == 0 {
+		segments = defaultPreviewSegments
+	}
+
+	segmentDuration := opt.SegmentDuration
+	if segmentDuration == 0 {
+		segmentDuration = defaultPreviewSegmentDuration
+	}
+
+	width := opt.Width
+	if width == 0 {
+		width = defaultPreviewWidth
+	}
+
+	fps := opt.FPS
+	if fps == 0 {
+		fps = defaultPreviewFPS
+	}
+
+	formats := opt.Formats
+	if len(formats) == 0 {
+		formats = []string{PreviewMP4}
+	}
+
+	mp4 := previewName + "." + PreviewMP4
+	starts := previewStarts(duration, segmentDuration, segments)
+
+	_, err := c.run(ctx, previewArgs(originalVideo, filepath.Join(dir, mp4), starts, segmentDuration, width, fps)...)
+	if err != nil {
+		return nil, err
+	}
+
+	preview := new(response.Preview)
+	keepMP4 := false
+
+	for _, f := range formats {
+		name := previewName + "." + f
+
+		switch f {
+		case PreviewMP4:
+			keepMP4 = true
+			preview.MP4 = name
+		case PreviewGIF:
+			// own palette makes GIF colors much closer to the video
+			_, err = c.run(ctx, "-i", filepath.Join(dir, mp4),
+				"-filter_complex", "[0:v]split[a][b];[a]palettegen[p];[b][p]paletteuse",
+				"-loop", "0", filepath.Join(dir, name))
+			preview.GIF = name
+		case PreviewWebP:
+			_, err = c.run(ctx, "-i", filepath.Join(dir, mp4),
+				"-c:v", "libwebp", "-q:v", webpQuality, "-loop", "0", filepath.Join(dir, name))
+			preview.WebP = name
+		}
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if !keepMP4 {
+		if err = os.Remove(filepath.Join(dir, mp4)); err != nil {
+			return nil, err
+		}
+	}
+
+	return preview, nil
+}
+
+// previewArgs returns ffmpeg options for joining clips of originalVideo started at starts to muted MP4
+func previewArgs(originalVideo, output string, starts []float64, segmentDuration float64, width, fps int) []string {
+	args := make([]string, 0, len(starts)*6+16)
+	streams := make([]string, 0, len(starts))
+
+	for i, start := range starts {
+		args = append(args, "-ss", formatNumber(start), "-t", formatNumber(segmentDuration), "-i", originalVideo)
+		streams = append(streams, fmt.Sprintf("[%d:v]", i))
+	}
+
+	graph := fmt.Sprintf("%sconcat=n=%d:v=1:a=0,fps=%d,scale=%d:-2,setsar=1[v]",
+		strings.Join(streams, ""), len(starts), fps, width)
+
+	return append(args,
+		"-filter_complex", graph,
+		"-map", "[v]",
+		"-an",
+		"-c:v", "libx264",
+		"-pix_fmt", "yuv420p",
+		"-movflags", "+faststart",
+		output)
+}
+
+// previewStarts returns start times of segments evenly spread across the video.
+// Number of segments is reduced when video is too short for all of them.
+func previewStarts(duration, segmentDuration float64, segments int) []float64 {
+	if fit := int(duration / segmentDuration); fit < segments {
+		segments = fit
+	}
+
+	if segments < 1 {
+		return []float64{0}
+	}
+
+	starts := make([]float64, segments)
+	step := duration / float64(segments)
+
+	for i := range starts {
+		// each segment is centered in its part of the video
+		start := step*(float64(i)+0.5) - segmentDuration/2
+		starts[i] = math.Round(math.Max(start, 0)*1000) / 1000
+	}
+
+	return starts
+}
@@ -0,0 +1,66 @@
+package compressor
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPreviewStarts(t *testing.T) {
+	cases := []struct {
+		name            string
+		duration        float64
+		segmentDuration float64
+		segments        int
+		expected        []float64
+	}{
+		{
+			name:            "Long video",
+			duration:        100,
+			segmentDuration: 2,
+			segments:        4,
+			expected:        []float64{11.5, 36.5, 61.5, 86.5},
+		},
+		{
+			name:            "Video shorter than all segments",
+			duration:        5,
+			segmentDuration: 2,
+			segments:        5,
+			expected:        []float64{0.25, 2.75},
+		},
+		{
+			name:            "Video shorter than one segment",
+			duration:        1,
+			segmentDuration: 2,
+			segments:        3,
+			expected:        []float64{0},
+		},
+	}
+
+	for _, testCase := range cases {
+		t.Run(testCase.name, func(t *testing.T) {
+			starts := previewStarts(testCase.duration, testCase.segmentDuration, testCase.segments)
+			if !reflect.DeepEqual(starts, testCase.expected) {
+				t.Errorf("Invalid starts, expected: %v, got: %v\n", testCase.expected, starts)
+			}
+		})
+	}
+}
+
+func TestPreviewArgs(t *testing.T) {
+	expected := []string{
+		"-ss", "1.5", "-t", "2", "-i", "in.mkv",
+		"-ss", "10", "-t", "2", "-i", "in.mkv",
+		"-filter_complex", "[0:v][1:v]concat=n=2:v=1:a=0,fps=12,scale=480:-2,setsar=1[v]",
+		"-map", "[v]",
+		"-an",
+		"-c:v", "libx264",
+		"-pix_fmt", "yuv420p",
+		"-movflags", "+faststart",
+		"preview.mp4",
+	}
+
+	args := previewArgs("in.mkv", "preview.mp4", []float64{1.5, 10}, 2, 480, 12)
+	if !reflect.DeepEqual(args, expected) {
+		t.Errorf("Invalid args, expected: %v, got: %v\n", expected, args)
+	}
+}
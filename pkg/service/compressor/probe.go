@@ -0,0 +1,220 @@
+package compressor
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/Hargeon/compressrv/pkg/response"
+)
+
+const (
+	streamVideo    = "video"
+	streamAudio    = "audio"
+	streamSubtitle = "subtitle"
+	streamData     = "data"
+)
+
+// ErrNoVideoStream is returned by VideoInfo for file without video stream
+var ErrNoVideoStream = errors.New("video stream not found")
+
+// probeResult is a part of ffprobe json output used by VideoInfo.
+type probeResult struct {
+	Format  probeFormat   `json:"format"`
+	Streams []probeStream `json:"streams"`
+}
+
+type probeFormat struct {
+	FormatName string `json:"format_name"`
+	Duration   string `json:"duration"`
+	BitRate    string `json:"bit_rate"`
+}
+
+type probeStream struct {
+	Index              int               `json:"index"`
+	CodecType          string            `json:"codec_type"`
+	CodecName          string            `json:"codec_name"`
+	Profile            string            `json:"profile"`
+	Width              int               `json:"width"`
+	Height             int               `json:"height"`
+	SampleAspectRatio  string            `json:"sample_aspect_ratio"`
+	DisplayAspectRatio string            `json:"display_aspect_ratio"`
+	AvgFrameRate       string            `json:"avg_frame_rate"`
+	RFrameRate         string            `json:"r_frame_rate"`
+	PixFmt             string            `json:"pix_fmt"`
+	BitRate            string            `json:"bit_rate"`
+	Duration           string            `json:"duration"`
+	StartTime          string            `json:"start_time"`
+	Channels           int               `json:"channels"`
+	SampleRate         string            `json:"sample_rate"`
+	Disposition        map[string]int    `json:"disposition"`
+	Tags               map[string]string `json:"tags"`
+	SideDataList       []struct {
+		Rotation float64 `json:"rotation"`
+	} `json:"side_data_list"`
+}
+
+// probe runs ffprobe for path and parses its output, output is cached while file isn't changed
+func (c *Compressor) probe(path string) (*probeResult, error) {
+	if p := c.meta.get(path, true); p != nil {
+		return p, nil
+	}
+
+	stdout, err := c.ffmpegCnf.Ffprobe("-print_format", "json", "-show_format", "-show_streams", path).
+		Stdout(context.Background())
+	if err != nil {
+		return nil, err
+	}
+
+	result := new(probeResult)
+	if err := json.Unmarshal(stdout, result); err != nil {
+		return nil, err
+	}
+
+	c.meta.put(path, result, true)
+
+	return result, nil
+}
+
+// videoInfo describes main video stream and audio streams of probed file
+func videoInfo(p *probeResult) (*response.Video, error) {
+	s := p.videoStream()
+	if s == nil {
+		return nil, ErrNoVideoStream
+	}
+
+	if s.Width <= 0 || s.Height <= 0 {
+		return nil, fmt.Errorf("invalid frame size %dx%d", s.Width, s.Height)
+	}
+
+	video := &response.Video{
+		Bitrate:      parseInt(p.Format.BitRate),
+		ResolutionX:  s.Width,
+		ResolutionY:  s.Height,
+		Duration:     parseFloat(p.Format.Duration),
+		FPS:          parseFrameRate(s.AvgFrameRate),
+		Codec:        s.CodecName,
+		Profile:      s.Profile,
+		PixelFormat:  s.PixFmt,
+		Rotation:     s.rotation(),
+		Container:    p.Format.FormatName,
+		VideoBitrate: parseInt(s.BitRate),
+		Streams:      len(p.Streams),
+	}
+
+	video.RatioX, video.RatioY = s.displayRatio()
+
+	displayed(video)
+
+	if video.Duration == 0 {
+		video.Duration = parseFloat(s.Duration)
+	}
+
+	if video.FPS == 0 {
+		video.FPS = parseFrameRate(s.RFrameRate)
+	}
+
+	for _, a := range p.Streams {
+		switch a.CodecType {
+		case streamAudio:
+			video.Audio = append(video.Audio, response.Audio{
+				Index:      a.Index,
+				Codec:      a.CodecName,
+				Channels:   a.Channels,
+				SampleRate: int(parseInt(a.SampleRate)),
+				Bitrate:    parseInt(a.BitRate),
+				Language:   a.Tags["language"],
+			})
+		case streamSubtitle:
+			video.Subtitles = append(video.Subtitles, response.Subtitle{
+				Index:    a.Index,
+				Codec:    a.CodecName,
+				Language: a.Tags["language"],
+				Title:    a.Tags["title"],
+				Default:  a.Disposition["default"] != 0,
+				Forced:   a.Disposition["forced"] != 0,
+			})
+		}
+	}
+
+	if video.Bitrate == 0 {
+		// some containers don't store overall bitrate, it's estimated from streams
+		video.Bitrate = video.VideoBitrate
+		for _, a := range video.Audio {
+			video.Bitrate += a.Bitrate
+		}
+	}
+
+	return video, nil
+}
+
+// videoStream returns the first video stream which isn't cover art, nil when file has no video
+func (p *probeResult) videoStream() *probeStream {
+	var cover *probeStream
+
+	for i := range p.Streams {
+		s := &p.Streams[i]
+		if s.CodecType != streamVideo {
+			continue
+		}
+
+		if s.Disposition["attached_pic"] == 0 {
+			return s
+		}
+
+		if cover == nil {
+			cover = s
+		}
+	}
+
+	return cover
+}
+
+// displayRatio returns display aspect ratio of stream,
+// it's calculated from frame size and sample aspect ratio when ffprobe doesn't report it
+func (s *probeStream) displayRatio() (int, int) {
+	if x, y, err := parseRatio(s.DisplayAspectRatio); err == nil {
+		return x, y
+	}
+
+	w, h := s.Width, s.Height
+	if sarX, sarY, err := parseRatio(s.SampleAspectRatio); err == nil {
+		w, h = w*sarX, h*sarY
+	}
+
+	d := gcd(w, h)
+
+	return w / d, h / d
+}
+
+// parseInt parses ffprobe integer value, missing or N/A value is zero
+func parseInt(s string) int64 {
+	n, err := strconv.ParseInt(strings.TrimSpace(s), decimal, bitrateBitSize)
+	if err != nil {
+		return 0
+	}
+
+	return n
+}
+
+// parseFloat parses ffprobe float value, missing or N/A value is zero
+func parseFloat(s string) float64 {
+	f, err := strconv.ParseFloat(strings.TrimSpace(s), bitrateBitSize)
+	if err != nil {
+		return 0
+	}
+
+	return f
+}
+
+// gcd returns the greatest common divisor of positive a and b
+func gcd(a, b int) int {
+	for b != 0 {
+		a, b = b, a%b
+	}
+
+	return a
+}
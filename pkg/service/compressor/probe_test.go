@@ -0,0 +1,162 @@
+package compressor
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/Hargeon/compressrv/pkg/response"
+)
+
+func TestVideoInfoFromProbe(t *testing.T) {
+	cases := []struct {
+		name  string
+		probe string
+
+		expected     *response.Video
+		errorPresent bool
+	}{
+		{
+			name: "Audio first with N/A aspect ratio",
+			probe: `{
+				"format": {"format_name": "mov,mp4,m4a,3gp,3g2,mj2", "duration": "12.5", "bit_rate": "1200000"},
+				"streams": [
+					{"index": 0, "codec_type": "audio", "codec_name": "aac", "channels": 2, "sample_rate": "48000",
+						"bit_rate": "128000", "tags": {"language": "eng"}},
+					{"index": 1, "codec_type": "video", "codec_name": "h264", "profile": "High", "width": 1440,
+						"height": 1080, "sample_aspect_ratio": "4:3", "display_aspect_ratio": "N/A",
+						"avg_frame_rate": "30000/1001", "pix_fmt": "yuv420p", "bit_rate": "1000000"},
+					{"index": 2, "codec_type": "subtitle", "codec_name": "subrip",
+						"disposition": {"default": 1, "forced": 0}, "tags": {"language": "eng", "title": "SDH"}}
+				]
+			}`,
+			expected: &response.Video{
+				Bitrate:      1200000,
+				ResolutionX:  1440,
+				ResolutionY:  1080,
+				RatioX:       16,
+				RatioY:       9,
+				Duration:     12.5,
+				FPS:          29.97,
+				Codec:        "h264",
+				Profile:      "High",
+				PixelFormat:  "yuv420p",
+				Container:    "mov,mp4,m4a,3gp,3g2,mj2",
+				VideoBitrate: 1000000,
+				Streams:      3,
+				Audio: []response.Audio{
+					{Index: 0, Codec: "aac", Channels: 2, SampleRate: 48000, Bitrate: 128000, Language: "eng"},
+				},
+				Subtitles: []response.Subtitle{
+					{Index: 2, Codec: "subrip", Language: "eng", Title: "SDH", Default: true},
+				},
+			},
+		},
+		{
+			name: "Cover art and rotated video without overall bitrate",
+			probe: `{
+				"format": {"format_name": "matroska,webm", "duration": "N/A"},
+				"streams": [
+					{"index": 0, "codec_type": "video", "codec_name": "mjpeg", "width": 600, "height": 600,
+						"disposition": {"attached_pic": 1}},
+					{"index": 1, "codec_type": "video", "codec_name": "vp9", "width": 1920, "height": 1080,
+						"sample_aspect_ratio": "0:1", "display_aspect_ratio": "0:1", "avg_frame_rate": "0/0",
+						"r_frame_rate": "25/1", "duration": "3.2", "bit_rate": "800000",
+						"side_data_list": [{"rotation": -90}]}
+				]
+			}`,
+			expected: &response.Video{
+				Bitrate:      800000,
+				ResolutionX:  1080,
+				ResolutionY:  1920,
+				RatioX:       9,
+				RatioY:       16,
+				Duration:     3.2,
+				FPS:          25,
+				Codec:        "vp9",
+				Rotation:     90,
+				Container:    "matroska,webm",
+				VideoBitrate: 800000,
+				Streams:      2,
+			},
+		},
+		{
+			name: "Rotate tag",
+			probe: `{
+				"format": {"format_name": "mov", "bit_rate": "500000"},
+				"streams": [
+					{"index": 0, "codec_type": "video", "width": 1280, "height": 720,
+						"display_aspect_ratio": "16:9", "tags": {"rotate": "270"}}
+				]
+			}`,
+			expected: &response.Video{
+				Bitrate:     500000,
+				ResolutionX: 720,
+				ResolutionY: 1280,
+				RatioX:      9,
+				RatioY:      16,
+				Rotation:    270,
+				Container:   "mov",
+				Streams:     1,
+			},
+		},
+		{
+			name: "Upside down video",
+			probe: `{
+				"format": {"format_name": "mov", "bit_rate": "500000"},
+				"streams": [
+					{"index": 0, "codec_type": "video", "width": 1280, "height": 720,
+						"display_aspect_ratio": "16:9", "side_data_list": [{"rotation": 180}]}
+				]
+			}`,
+			expected: &response.Video{
+				Bitrate:     500000,
+				ResolutionX: 1280,
+				ResolutionY: 720,
+				RatioX:      16,
+				RatioY:      9,
+				Rotation:    180,
+				Container:   "mov",
+				Streams:     1,
+			},
+		},
+		{
+			name: "Audio only",
+			probe: `{
+				"format": {"format_name": "mp3"},
+				"streams": [{"index": 0, "codec_type": "audio", "codec_name": "mp3"}]
+			}`,
+			errorPresent: true,
+		},
+	}
+
+	for _, testCase := range cases {
+		t.Run(testCase.name, func(t *testing.T) {
+			p := new(probeResult)
+			if err := json.Unmarshal([]byte(testCase.probe), p); err != nil {
+				t.Fatalf("Unexpected error: %s\n", err)
+			}
+
+			video, err := videoInfo(p)
+			if err != nil && !testCase.errorPresent {
+				t.Errorf("Unexpected error: %s\n", err)
+			}
+
+			if err == nil && testCase.errorPresent {
+				t.Errorf("Should be error\n")
+			}
+
+			if !reflect.DeepEqual(video, testCase.expected) {
+				t.Errorf("Invalid video info, expected: %+v, got: %+v\n", testCase.expected, video)
+			}
+		})
+	}
+}
+
+func TestVideoInfoWithoutFfprobe(t *testing.T) {
+	srv := NewCompressor("", "")
+
+	if _, err := srv.VideoInfo("video.mp4"); err == nil {
+		t.Errorf("Should be error\n")
+	}
+}
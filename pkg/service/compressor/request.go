@@ -1,5 +1,36 @@
 package compressor
 
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/Hargeon/compressrv/pkg/response"
+)
+
+// Formats of animated preview
+const (
+	PreviewMP4  = "mp4"
+	PreviewGIF  = "gif"
+	PreviewWebP = "webp"
+)
+
+// Positions of watermark
+const (
+	PositionTopLeft     = "top-left"
+	PositionTopRight    = "top-right"
+	PositionBottomLeft  = "bottom-left"
+	PositionBottomRight = "bottom-right"
+	PositionCenter      = "center"
+)
+
+// Output formats of converted video
+const (
+	OutputFile = "file"
+	OutputHLS  = "hls"
+	OutputDASH = "dash"
+)
+
 // Request from rabbit mq
 type Request struct {
 	RequestID      int64  `json:"request_id"`
@@ -9,4 +40,494 @@ type Request struct {
 	VideoID        int64  `json:"video_id"`
 	UserID         int64  `json:"user_id"`
 	VideoServiceID string `json:"video_service_id"`
+
+	// Preset is a name of Presets entry, options which aren't set in request are taken from it.
+	// Bitrate, max size and auto bitrate of preset aren't taken when request sets any of them
+	Preset string `json:"preset"`
+
+	// MaxSizeBytes limits size of converted video, bitrate is calculated from duration of video when it's set
+	MaxSizeBytes int64 `json:"max_size_bytes"`
+	// AutoBitrate selects bitrate by content of video, fixed Bitrate or Ladder bitrates are used when nil
+	AutoBitrate *AutoBitrateOptions `json:"auto_bitrate,omitempty"`
+
+	// NoUpscale keeps frame not larger than original video, Resolution is reduced when needed
+	NoUpscale bool `json:"no_upscale"`
+	// Fit converts frame to Ratio with real scale, crop or pad (FitPad, FitCrop, FitStretch).
+	// FitPad is used when Ratio is set and Fit is empty
+	Fit string `json:"fit"`
+	// PadColor of bars for FitPad, black is used when empty
+	PadColor string `json:"pad_color"`
+
+	// FPS is a frame rate of converted video, original frame rate is kept when zero
+	FPS float64 `json:"fps"`
+	// MaxFPS caps frame rate, video with lower frame rate keeps it
+	MaxFPS float64 `json:"max_fps"`
+	// FPSMode of frame rate conversion (FPSModeDrop, FPSModeBlend), FPSModeDrop is used when empty
+	FPSMode string `json:"fps_mode"`
+	// KeyframeInterval in seconds, encoder decides when zero
+	KeyframeInterval float64 `json:"keyframe_interval"`
+	// SceneCut enables keyframes on scene changes, encoder default is used when nil
+	SceneCut *bool `json:"scene_cut"`
+
+	// Deinterlace enables deinterlacing of video which is detected as interlaced by idet filter
+	Deinterlace bool `json:"deinterlace"`
+	// Denoise is a strength of noise reduction (DenoiseLight, DenoiseMedium, DenoiseStrong), noise isn't reduced when empty
+	Denoise string `json:"denoise"`
+	// Deshake enables shake reduction
+	Deshake bool `json:"deshake"`
+	// AutoCrop enables removal of black bars detected by cropdetect filter
+	AutoCrop bool `json:"auto_crop"`
+
+	// Loudness describes EBU R128 loudness normalization, loudness isn't changed when nil
+	Loudness *LoudnessOptions `json:"loudness,omitempty"`
+
+	// Start of clip in seconds, conversion starts from the beginning when zero
+	Start float64 `json:"start"`
+	// End of clip in seconds, Duration is used when End is zero
+	End float64 `json:"end"`
+	// Duration of clip in seconds, conversion lasts till the end when both End and Duration are zero
+	Duration float64 `json:"duration"`
+	// FastCut cuts clip on keyframes without re-encoding, so clip may start a bit earlier than Start
+	FastCut bool `json:"fast_cut"`
+
+	// Watermark is an image overlaid on converted video, nothing is overlaid when nil
+	Watermark *Watermark `json:"watermark,omitempty"`
+	// Subtitles describes handling of subtitle tracks, subtitles are dropped when nil
+	Subtitles *SubtitleOptions `json:"subtitles,omitempty"`
+
+	// Output is a format of converted video, OutputFile is used when empty
+	Output string `json:"output"`
+	// FastStart moves moov atom of MP4 file to the beginning, it's enabled when nil
+	FastStart *bool `json:"faststart"`
+	// Fragmented makes fragmented MP4 file for low-latency streaming instead of faststart one
+	Fragmented bool `json:"fragmented"`
+	// SegmentDuration in seconds for adaptive streaming outputs
+	SegmentDuration int `json:"segment_duration"`
+	// Ladder of variants for adaptive streaming outputs, default ladder is used when empty
+	Ladder []Variant `json:"ladder"`
+	// Thumbnails describes preview images extracted from original video, nothing is extracted when nil
+	Thumbnails *ThumbnailOptions `json:"thumbnails,omitempty"`
+	// Preview describes short animated preview made from original video, nothing is made when nil
+	Preview *PreviewOptions `json:"preview,omitempty"`
+	// Verify enables complete decoding of converted file and check of its duration, streams and frame size,
+	// broken file fails conversion instead of being uploaded
+	Verify bool `json:"verify"`
+
+	// WorkDir is a directory of job where intermediate and converted files are written, it's set by handler
+	WorkDir string `json:"-"`
+
+	// detected is a result of analysis for Deinterlace and AutoCrop
+	detected *detection
+	// present are json names of options set in decoded request, explicit zero values override preset
+	present map[string]bool
+}
+
+// UnmarshalJSON decodes request and remembers which options are set in it
+func (r *Request) UnmarshalJSON(data []byte) error {
+	// plain has fields of Request without its methods, so decoding doesn't recurse
+	type plain Request
+
+	if err := json.Unmarshal(data, (*plain)(r)); err != nil {
+		return err
+	}
+
+	var options map[string]json.RawMessage
+	if err := json.Unmarshal(data, &options); err != nil {
+		return err
+	}
+
+	r.present = make(map[string]bool, len(options))
+	for name := range options {
+		r.present[name] = true
+	}
+
+	return nil
+}
+
+// Variant is one rendition of adaptive streaming output
+type Variant struct {
+	Height       int   `json:"height"`
+	Bitrate      int64 `json:"bitrate"`
+	AudioBitrate int64 `json:"audio_bitrate"`
+}
+
+// ThumbnailOptions describes preview images of video
+type ThumbnailOptions struct {
+	// Poster enables extraction of full size frame at PosterAt seconds
+	Poster   bool    `json:"poster"`
+	PosterAt float64 `json:"poster_at"`
+	// Count of evenly spaced thumbnails
+	Count int `json:"count"`
+	// Width of thumbnails and sprite tiles, height is calculated from aspect ratio of video
+	Width int `json:"width"`
+	// Sprite enables sprite sheet with WebVTT thumbnail track for hover-scrub previews
+	Sprite bool `json:"sprite"`
+	// SpriteInterval in seconds between sprite tiles
+	SpriteInterval float64 `json:"sprite_interval"`
+	// SpriteColumns is a number of tiles in a row of sprite sheet
+	SpriteColumns int `json:"sprite_columns"`
+}
+
+// PreviewOptions describes animated preview of video
+type PreviewOptions struct {
+	// Segments is a number of clips cut from across the video
+	Segments int `json:"segments"`
+	// SegmentDuration of each clip in seconds
+	SegmentDuration float64 `json:"segment_duration"`
+	Width           int     `json:"width"`
+	FPS             int     `json:"fps"`
+	// Formats of preview (PreviewMP4, PreviewGIF, PreviewWebP), muted MP4 is made when empty
+	Formats []string `json:"formats"`
+}
+
+// SubtitleOptions describes handling of subtitle tracks
+type SubtitleOptions struct {
+	// Mode of subtitles in converted video (SubtitleDrop, SubtitleKeep, SubtitleBurn), SubtitleDrop is used when empty.
+	// Kept text subtitles are converted to the codec supported by container, bitmap ones are kept only by mkv
+	Mode string `json:"mode"`
+	// Track is a number of subtitle track burned in with SubtitleBurn
+	Track int `json:"track"`
+	// Extract enables extraction of text subtitle tracks to WebVTT files
+	Extract bool `json:"extract"`
+
+	// source is a local path of video with burned subtitles
+	source string
+}
+
+// LoudnessOptions describes targets of two-pass loudness normalization, EBU R128 targets are used for zero values
+type LoudnessOptions struct {
+	// Integrated loudness in LUFS
+	Integrated float64 `json:"integrated"`
+	// TruePeak in dBTP
+	TruePeak float64 `json:"true_peak"`
+	// LRA is a loudness range in LU
+	LRA float64 `json:"lra"`
+	// Measured is loudness of original video measured by the first pass, it's measured by Convert when nil
+	Measured *response.LoudnessLevel `json:"-"`
+}
+
+// Watermark describes image overlaid on video
+type Watermark struct {
+	// ImageServiceID is an id of image in VideoStorage
+	ImageServiceID string `json:"image_service_id"`
+	// ImagePath is a local path of downloaded image
+	ImagePath string `json:"-"`
+	// Position of watermark, PositionBottomRight is used when empty
+	Position string `json:"position"`
+	// Margin in pixels between watermark and edges of video
+	Margin int `json:"margin"`
+	// Scale is a width of watermark relative to width of video, image keeps own size when zero
+	Scale float64 `json:"scale"`
+	// Opacity from 0 to 1, watermark is opaque when zero
+	Opacity float64 `json:"opacity"`
+	// Start and End in seconds limit time when watermark is shown, End is zero for the end of video
+	Start float64 `json:"start"`
+	End   float64 `json:"end"`
+}
+
+// Validate checks that request can be processed
+func (r *Request) Validate() error {
+	switch r.Output {
+	case "", OutputFile, OutputHLS, OutputDASH:
+	default:
+		return fmt.Errorf("unknown output %q", r.Output)
+	}
+
+	if r.MaxSizeBytes < 0 {
+		return errors.New("max size can't be negative")
+	}
+
+	if r.MaxSizeBytes != 0 && (r.Bitrate != 0 || r.Adaptive()) {
+		return errors.New("max size can't be used with bitrate or adaptive output")
+	}
+
+	// bitrates of variants of adaptive output are set by ladder
+	if r.Bitrate != 0 && r.Adaptive() {
+		return errors.New("bitrate can't be used with adaptive output")
+	}
+
+	if r.AutoBitrate != nil {
+		if r.Bitrate != 0 || r.MaxSizeBytes != 0 {
+			return errors.New("auto bitrate can't be used with bitrate or max size")
+		}
+
+		if err := r.AutoBitrate.validate(); err != nil {
+			return err
+		}
+	}
+
+	if r.Resolution != "" {
+		if err := validateResolution(r.Resolution); err != nil {
+			return err
+		}
+	}
+
+	if err := r.validateFit(); err != nil {
+		return err
+	}
+
+	if err := r.validateFPS(); err != nil {
+		return err
+	}
+
+	if _, ok := denoiseFilters[r.Denoise]; r.Denoise != "" && !ok {
+		return fmt.Errorf("unknown denoise strength %q", r.Denoise)
+	}
+
+	if r.Start < 0 || r.End < 0 || r.Duration < 0 {
+		return errors.New("clip bounds can't be negative")
+	}
+
+	if r.End != 0 && r.Duration != 0 {
+		return errors.New("only one of end and duration can be set")
+	}
+
+	if r.End != 0 && r.End <= r.Start {
+		return errors.New("end should be after start")
+	}
+
+	if err := r.Watermark.validate(); err != nil {
+		return err
+	}
+
+	if err := r.Subtitles.validate(); err != nil {
+		return err
+	}
+
+	if err := r.Loudness.validate(); err != nil {
+		return err
+	}
+
+	if r.Loudness != nil && r.Adaptive() {
+		return errors.New("loudness normalization can't be used with adaptive output")
+	}
+
+	if option := r.frameOption(); option != "" && r.Adaptive() {
+		return fmt.Errorf("%s can't be used with adaptive output", option)
+	}
+
+	if r.Fragmented && (r.Adaptive() || (r.FastStart != nil && *r.FastStart)) {
+		return errors.New("fragmented mp4 can't be used with faststart or adaptive output")
+	}
+
+	if r.SegmentDuration < 0 {
+		return errors.New("segment duration can't be negative")
+	}
+
+	for i, v := range r.Ladder {
+		if v.Height <= 0 || v.Bitrate <= 0 || v.AudioBitrate < 0 {
+			return fmt.Errorf("invalid ladder variant %d", i)
+		}
+	}
+
+	if t := r.Thumbnails; t != nil {
+		if t.PosterAt < 0 || t.Count < 0 || t.Width < 0 || t.SpriteInterval < 0 || t.SpriteColumns < 0 {
+			return errors.New("thumbnail options can't be negative")
+		}
+
+		if t.Width > maxSpriteSize {
+			return fmt.Errorf("thumbnail width can't exceed %d pixels", maxSpriteSize)
+		}
+	}
+
+	if p := r.Preview; p != nil {
+		if p.Segments < 0 || p.SegmentDuration < 0 || p.Width < 0 || p.FPS < 0 {
+			return errors.New("preview options can't be negative")
+		}
+
+		for _, f := range p.Formats {
+			if f != PreviewMP4 && f != PreviewGIF && f != PreviewWebP {
+				return fmt.Errorf("unknown preview format %q", f)
+			}
+		}
+	}
+
+	return nil
+}
+
+// Adaptive reports if request has adaptive streaming output
+func (r *Request) Adaptive() bool {
+	return r.Output == OutputHLS || r.Output == OutputDASH
+}
+
+// frameOption returns name of the first option which changes frame of converted video,
+// frame of variants of adaptive output is only scaled by ladder, so these options aren't applied to it
+func (r *Request) frameOption() string {
+	switch {
+	case r.Watermark != nil:
+		return "watermark"
+	case r.Fit != "" || r.PadColor != "":
+		return "fit"
+	case r.Ratio != "":
+		return "ratio"
+	case r.Resolution != "":
+		return "resolution"
+	case r.FPS != 0 || r.MaxFPS != 0:
+		return "frame rate"
+	case r.Deinterlace || r.Denoise != "" || r.Deshake || r.AutoCrop:
+		return "cleanup"
+	case r.subtitleMode() == SubtitleBurn:
+		return "burned subtitles"
+	}
+
+	return ""
+}
+
+// WithThumbnails reports if request asks for preview images
+func (r *Request) WithThumbnails() bool {
+	t := r.Thumbnails
+
+	return t != nil && (t.Poster || t.Count > 0 || t.Sprite)
+}
+
+// trimmed reports if only a clip of video should be converted
+func (r *Request) trimmed() bool {
+	return r.Start != 0 || r.clipDuration() != 0
+}
+
+// clipDuration returns duration of clip in seconds, zero means till the end of video
+func (r *Request) clipDuration() float64 {
+	if r.End != 0 {
+		return r.End - r.Start
+	}
+
+	return r.Duration
+}
+
+// reencode reports if conversion changes video streams
+func (r *Request) reencode() bool {
+	return r.Bitrate != 0 || r.MaxSizeBytes != 0 || r.Resolution != "" || r.Ratio != "" || r.Watermark != nil ||
+		r.FPS != 0 || r.MaxFPS != 0 || r.KeyframeInterval != 0 || r.SceneCut != nil ||
+		r.subtitleMode() == SubtitleBurn || r.Loudness != nil || r.Deinterlace || r.Denoise != "" || r.Deshake || r.AutoCrop ||
+		r.AutoBitrate != nil
+}
+
+// fitMode returns mode of aspect ratio conversion, it's empty when ratio isn't changed
+func (r *Request) fitMode() string {
+	if r.Fit == "" && r.Ratio != "" {
+		return FitPad
+	}
+
+	return r.Fit
+}
+
+// validateFit checks options of aspect ratio conversion
+func (r *Request) validateFit() error {
+	switch r.fitMode() {
+	case "":
+		return nil
+	case FitPad, FitCrop, FitStretch:
+	default:
+		return fmt.Errorf("unknown fit %q", r.Fit)
+	}
+
+	if _, _, err := parseRatio(r.Ratio); err != nil {
+		return err
+	}
+
+	if r.PadColor != "" && !colorRe.MatchString(r.PadColor) {
+		return fmt.Errorf("invalid pad color %q", r.PadColor)
+	}
+
+	return nil
+}
+
+// validateFPS checks options of frame rate
+func (r *Request) validateFPS() error {
+	if r.FPS < 0 || r.MaxFPS < 0 || r.KeyframeInterval < 0 {
+		return errors.New("frame rate options can't be negative")
+	}
+
+	if r.FPSMode != "" && r.FPSMode != FPSModeDrop && r.FPSMode != FPSModeBlend {
+		return fmt.Errorf("unknown fps mode %q", r.FPSMode)
+	}
+
+	return nil
+}
+
+// validate checks watermark options, nil watermark is valid
+func (w *Watermark) validate() error {
+	if w == nil {
+		return nil
+	}
+
+	if w.ImageServiceID == "" {
+		return errors.New("watermark image is required")
+	}
+
+	switch w.Position {
+	case "", PositionTopLeft, PositionTopRight, PositionBottomLeft, PositionBottomRight, PositionCenter:
+	default:
+		return fmt.Errorf("unknown watermark position %q", w.Position)
+	}
+
+	if w.Margin < 0 || w.Scale < 0 || w.Scale > 1 || w.Opacity < 0 || w.Opacity > 1 {
+		return errors.New("invalid watermark margin, scale or opacity")
+	}
+
+	if w.Start < 0 || w.End < 0 || (w.End != 0 && w.End <= w.Start) {
+		return errors.New("invalid watermark time range")
+	}
+
+	return nil
+}
+
+// validate checks subtitle options, nil options are valid
+func (s *SubtitleOptions) validate() error {
+	if s == nil {
+		return nil
+	}
+
+	switch s.Mode {
+	case "", SubtitleDrop, SubtitleKeep, SubtitleBurn:
+	default:
+		return fmt.Errorf("unknown subtitle mode %q", s.Mode)
+	}
+
+	if s.Track < 0 {
+		return errors.New("subtitle track can't be negative")
+	}
+
+	return nil
+}
+
+// validate checks loudness targets, nil options are valid
+func (l *LoudnessOptions) validate() error {
+	if l == nil {
+		return nil
+	}
+
+	if l.Integrated != 0 && (l.Integrated < -70 || l.Integrated > -5) {
+		return errors.New("integrated loudness should be from -70 to -5 LUFS")
+	}
+
+	if l.TruePeak != 0 && (l.TruePeak < -9 || l.TruePeak > 0) {
+		return errors.New("true peak should be from -9 to 0 dBTP")
+	}
+
+	if l.LRA != 0 && (l.LRA < 1 || l.LRA > 50) {
+		return errors.New("loudness range should be from 1 to 50 LU")
+	}
+
+	return nil
+}
+
+// WithSubtitleFiles reports if request asks for extraction of subtitles
+func (r *Request) WithSubtitleFiles() bool {
+	return r.Subtitles != nil && r.Subtitles.Extract
+}
+
+// subtitleMode returns mode of subtitles in converted video
+func (r *Request) subtitleMode() string {
+	if r.Subtitles == nil || r.Subtitles.Mode == "" {
+		return SubtitleDrop
+	}
+
+	return r.Subtitles.Mode
+}
+
+// needsSource reports if conversion depends on original video info
+func (r *Request) needsSource() bool {
+	return r.fitMode() != "" || r.NoUpscale || r.MaxSizeBytes != 0 || r.Loudness != nil || r.Resolution != "" ||
+		r.MaxFPS != 0 || (r.KeyframeInterval != 0 && r.FPS == 0) || r.subtitleMode() != SubtitleDrop
 }
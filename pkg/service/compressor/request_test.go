@@ -0,0 +1,230 @@
+package compressor
+
+import "testing"
+
+func TestValidate(t *testing.T) {
+	cases := []struct {
+		name         string
+		req          *Request
+		errorPresent bool
+	}{
+		{
+			name:         "Empty request",
+			req:          &Request{},
+			errorPresent: false,
+		},
+		{
+			name:         "Unknown output",
+			req:          &Request{Output: "avi"},
+			errorPresent: true,
+		},
+		{
+			name:         "Invalid ladder",
+			req:          &Request{Output: OutputHLS, Ladder: []Variant{{Height: 720}}},
+			errorPresent: true,
+		},
+		{
+			name:         "Too wide thumbnails",
+			req:          &Request{Thumbnails: &ThumbnailOptions{Sprite: true, Width: 70000}},
+			errorPresent: true,
+		},
+		{
+			name:         "Bitrate with adaptive output",
+			req:          &Request{Output: OutputDASH, Bitrate: 800000},
+			errorPresent: true,
+		},
+		{
+			name:         "Auto bitrate with adaptive output",
+			req:          &Request{Output: OutputHLS, AutoBitrate: &AutoBitrateOptions{Quality: 20}},
+			errorPresent: false,
+		},
+		{
+			name:         "Auto bitrate",
+			req:          &Request{AutoBitrate: &AutoBitrateOptions{Quality: 20, MinBitrate: 500000, MaxBitrate: 4000000}},
+			errorPresent: false,
+		},
+		{
+			name:         "Auto bitrate with fixed bitrate",
+			req:          &Request{Bitrate: 800000, AutoBitrate: &AutoBitrateOptions{}},
+			errorPresent: true,
+		},
+		{
+			name:         "Auto bitrate with invalid quality",
+			req:          &Request{AutoBitrate: &AutoBitrateOptions{Quality: 60}},
+			errorPresent: true,
+		},
+		{
+			name:         "Auto bitrate with max below min",
+			req:          &Request{AutoBitrate: &AutoBitrateOptions{MinBitrate: 500000, MaxBitrate: 400000}},
+			errorPresent: true,
+		},
+		{
+			name:         "Valid clip",
+			req:          &Request{Start: 10, End: 20},
+			errorPresent: false,
+		},
+		{
+			name:         "End before start",
+			req:          &Request{Start: 10, End: 5},
+			errorPresent: true,
+		},
+		{
+			name:         "End and duration",
+			req:          &Request{End: 10, Duration: 5},
+			errorPresent: true,
+		},
+		{
+			name:         "Negative thumbnails count",
+			req:          &Request{Thumbnails: &ThumbnailOptions{Count: -1}},
+			errorPresent: true,
+		},
+		{
+			name:         "Unknown preview format",
+			req:          &Request{Preview: &PreviewOptions{Formats: []string{"avi"}}},
+			errorPresent: true,
+		},
+		{
+			name:         "Watermark without image",
+			req:          &Request{Watermark: &Watermark{}},
+			errorPresent: true,
+		},
+		{
+			name:         "Watermark with invalid opacity",
+			req:          &Request{Watermark: &Watermark{ImageServiceID: "logo.png", Opacity: 2}},
+			errorPresent: true,
+		},
+		{
+			name:         "Fit without ratio",
+			req:          &Request{Fit: FitPad},
+			errorPresent: true,
+		},
+		{
+			name:         "Fit with invalid pad color",
+			req:          &Request{Fit: FitPad, Ratio: "16:9", PadColor: "red;"},
+			errorPresent: true,
+		},
+		{
+			name:         "Valid fit",
+			req:          &Request{Fit: FitCrop, Ratio: "9:16", Resolution: "1080x1920"},
+			errorPresent: false,
+		},
+		{
+			name:         "Valid resolution spec",
+			req:          &Request{Resolution: "max:1280x-1", NoUpscale: true},
+			errorPresent: false,
+		},
+		{
+			name:         "Invalid resolution spec",
+			req:          &Request{Resolution: "full hd"},
+			errorPresent: true,
+		},
+		{
+			name:         "Negative frame rate",
+			req:          &Request{FPS: -24},
+			errorPresent: true,
+		},
+		{
+			name:         "Unknown fps mode",
+			req:          &Request{FPS: 24, FPSMode: "interpolate"},
+			errorPresent: true,
+		},
+		{
+			name:         "Max size with bitrate",
+			req:          &Request{MaxSizeBytes: 16000000, Bitrate: 64000},
+			errorPresent: true,
+		},
+		{
+			name:         "Valid max size",
+			req:          &Request{MaxSizeBytes: 16000000, Resolution: "720p"},
+			errorPresent: false,
+		},
+		{
+			name:         "Invalid loudness target",
+			req:          &Request{Loudness: &LoudnessOptions{TruePeak: 2}},
+			errorPresent: true,
+		},
+		{
+			name:         "Loudness with adaptive output",
+			req:          &Request{Output: OutputHLS, Loudness: &LoudnessOptions{}},
+			errorPresent: true,
+		},
+		{
+			name:         "Valid loudness",
+			req:          &Request{Loudness: &LoudnessOptions{Integrated: -16, TruePeak: -1.5, LRA: 11}},
+			errorPresent: false,
+		},
+		{
+			name:         "Watermark with adaptive output",
+			req:          &Request{Output: OutputHLS, Watermark: &Watermark{ImageServiceID: "logo.png"}},
+			errorPresent: true,
+		},
+		{
+			name:         "Ratio with adaptive output",
+			req:          &Request{Output: OutputDASH, Ratio: "16:9", Fit: FitCrop},
+			errorPresent: true,
+		},
+		{
+			name:         "Resolution with adaptive output",
+			req:          &Request{Output: OutputDASH, Resolution: "1280x720"},
+			errorPresent: true,
+		},
+		{
+			name:         "Frame rate with adaptive output",
+			req:          &Request{Output: OutputHLS, MaxFPS: 30},
+			errorPresent: true,
+		},
+		{
+			name:         "Cleanup with adaptive output",
+			req:          &Request{Output: OutputHLS, Deinterlace: true},
+			errorPresent: true,
+		},
+		{
+			name:         "Burned subtitles with adaptive output",
+			req:          &Request{Output: OutputHLS, Subtitles: &SubtitleOptions{Mode: SubtitleBurn}},
+			errorPresent: true,
+		},
+		{
+			name:         "Fragmented mp4 with adaptive output",
+			req:          &Request{Output: OutputDASH, Fragmented: true},
+			errorPresent: true,
+		},
+		{
+			name:         "Valid fragmented mp4",
+			req:          &Request{Fragmented: true},
+			errorPresent: false,
+		},
+		{
+			name:         "Unknown denoise strength",
+			req:          &Request{Denoise: "extreme"},
+			errorPresent: true,
+		},
+		{
+			name:         "Unknown subtitle mode",
+			req:          &Request{Subtitles: &SubtitleOptions{Mode: "translate"}},
+			errorPresent: true,
+		},
+		{
+			name:         "Valid subtitle options",
+			req:          &Request{Subtitles: &SubtitleOptions{Mode: SubtitleBurn, Track: 1, Extract: true}},
+			errorPresent: false,
+		},
+		{
+			name:         "Valid frame rate options",
+			req:          &Request{MaxFPS: 30, FPSMode: FPSModeBlend, KeyframeInterval: 2},
+			errorPresent: false,
+		},
+	}
+
+	for _, testCase := range cases {
+		t.Run(testCase.name, func(t *testing.T) {
+			err := testCase.req.Validate()
+			if err != nil && !testCase.errorPresent {
+				t.Errorf("Unexpected error: %s\n", err)
+			}
+
+			if err == nil && testCase.errorPresent {
+				t.Errorf("Should be error\n")
+			}
+		})
+	}
+}
@@ -0,0 +1,47 @@
+package compressor
+
+import (
+	"math"
+	"strconv"
+
+	"github.com/Hargeon/compressrv/pkg/response"
+)
+
+// clearRotationArgs reset rotation metadata of converted video streams.
+// ffmpeg rotates frames of input with rotation metadata before filters (autorotate is on by default),
+// so the flag copied from input would make players rotate converted video once more
+var clearRotationArgs = []string{"-metadata:s:v", "rotate=0"}
+
+// rotation returns clockwise rotation of stream in degrees normalized to [0, 360)
+func (s *probeStream) rotation() int {
+	var degrees float64
+
+	if r, err := strconv.ParseFloat(s.Tags["rotate"], bitrateBitSize); err == nil {
+		degrees = r
+	}
+
+	// display matrix rotation is counterclockwise, unlike rotate tag
+	for _, side := range s.SideDataList {
+		if side.Rotation != 0 {
+			degrees = -side.Rotation
+		}
+	}
+
+	r := int(math.Round(degrees)) % 360
+	if r < 0 {
+		r += 360
+	}
+
+	return r
+}
+
+// displayed swaps frame size and aspect ratio of video rotated by 90 or 270 degrees,
+// so video describes frame as it's shown to viewer and as filters get it after autorotation
+func displayed(video *response.Video) {
+	if video.Rotation%180 == 0 {
+		return
+	}
+
+	video.ResolutionX, video.ResolutionY = video.ResolutionY, video.ResolutionX
+	video.RatioX, video.RatioY = video.RatioY, video.RatioX
+}
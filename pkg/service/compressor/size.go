@@ -0,0 +1,111 @@
+package compressor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/Hargeon/compressrv/pkg/ffmpeg"
+	"github.com/Hargeon/compressrv/pkg/response"
+)
+
+const (
+	// containerOverhead is a part of file size taken by container headers and indexes
+	containerOverhead = 0.02
+	// maxSizeAttempts is a number of encodings made to fit into max size
+	maxSizeAttempts = 3
+	// sizeMargin lowers bitrate of retry a bit more than overflow of previous attempt
+	sizeMargin = 0.95
+
+	sizeAudioBitrate    = 128000
+	minSizeVideoBitrate = 50000
+)
+
+// convertToSize converts originalVideo with bitrate budget calculated from opt.MaxSizeBytes and duration of video.
+// Conversion is retried with lower budget while converted video is larger than opt.MaxSizeBytes
+func (c *Compressor) convertToSize(ctx context.Context, opt *Request, originalVideo string, src *response.Video,
+	opts *ffmpeg.Options, extra ...string) (string, error) {
+	audio := sizeAudio(src)
+
+	video, err := sizeBudget(opt.MaxSizeBytes, outputDuration(opt, src), audio)
+	if err != nil {
+		return "", err
+	}
+
+	if audio != 0 {
+		codec, bitrate := "aac", fmt.Sprintf("%d", audio)
+		opts.AudioCodec, opts.AudioBitrate = &codec, &bitrate
+	}
+
+	newVideoPath := convertedVideoPath(opt, originalVideo)
+
+	for attempt := 1; ; attempt++ {
+		bStr, maxRate, bufSize := fmt.Sprintf("%d", video), int(video), int(video*2)
+		opts.VideoBitRate, opts.VideoMaxBitRate, opts.BufferSize = &bStr, &maxRate, &bufSize
+
+		if err = c.convertVideo(ctx, originalVideo, newVideoPath, opts, extra...); err != nil {
+			return "", err
+		}
+
+		stat, err := os.Stat(newVideoPath)
+		if err != nil {
+			return "", err
+		}
+
+		if stat.Size() <= opt.MaxSizeBytes {
+			return newVideoPath, nil
+		}
+
+		video = shrinkBitrate(video, stat.Size(), opt.MaxSizeBytes)
+		if attempt == maxSizeAttempts || video < minSizeVideoBitrate {
+			os.Remove(newVideoPath)
+
+			return "", fmt.Errorf("converted video is %d bytes, it's larger than %d bytes", stat.Size(), opt.MaxSizeBytes)
+		}
+	}
+}
+
+// sizeBudget returns video bitrate which fits video of duration seconds with audio bitrate into maxSize bytes
+func sizeBudget(maxSize int64, duration float64, audio int64) (int64, error) {
+	if duration <= 0 {
+		return 0, errors.New("duration of video is unknown")
+	}
+
+	total := float64(maxSize) * 8 * (1 - containerOverhead) / duration
+	video := int64(total) - audio
+
+	if video < minSizeVideoBitrate {
+		return 0, fmt.Errorf("max size %d bytes is too small for %s seconds of video", maxSize, formatNumber(duration))
+	}
+
+	return video, nil
+}
+
+// shrinkBitrate lowers bitrate of video which size is larger than maxSize
+func shrinkBitrate(bitrate, size, maxSize int64) int64 {
+	return int64(float64(bitrate) * float64(maxSize) / float64(size) * sizeMargin)
+}
+
+// sizeAudio returns bitrate of audio for size budget, it's zero for video without audio
+func sizeAudio(src *response.Video) int64 {
+	if len(src.Audio) == 0 {
+		return 0
+	}
+
+	if b := src.Audio[0].Bitrate; b != 0 && b < sizeAudioBitrate {
+		return b
+	}
+
+	return sizeAudioBitrate
+}
+
+// outputDuration returns duration of converted video in seconds
+func outputDuration(opt *Request, src *response.Video) float64 {
+	d := src.Duration - opt.Start
+	if clip := opt.clipDuration(); clip != 0 && clip < d {
+		d = clip
+	}
+
+	return d
+}
@@ -0,0 +1,99 @@
+package compressor
+
+import (
+	"testing"
+
+	"github.com/Hargeon/compressrv/pkg/response"
+)
+
+func TestSizeBudget(t *testing.T) {
+	cases := []struct {
+		name     string
+		maxSize  int64
+		duration float64
+		audio    int64
+
+		expected     int64
+		errorPresent bool
+	}{
+		{
+			name:     "16 MB for 2 minutes",
+			maxSize:  16000000,
+			duration: 120,
+			audio:    128000,
+			expected: 917333,
+		},
+		{
+			name:     "Video without audio",
+			maxSize:  1000000,
+			duration: 10,
+			expected: 784000,
+		},
+		{
+			name:         "Too long video",
+			maxSize:      1000000,
+			duration:     3600,
+			audio:        128000,
+			errorPresent: true,
+		},
+		{
+			name:         "Unknown duration",
+			maxSize:      1000000,
+			errorPresent: true,
+		},
+	}
+
+	for _, testCase := range cases {
+		t.Run(testCase.name, func(t *testing.T) {
+			video, err := sizeBudget(testCase.maxSize, testCase.duration, testCase.audio)
+			if err != nil && !testCase.errorPresent {
+				t.Errorf("Unexpected error: %s\n", err)
+			}
+
+			if err == nil && testCase.errorPresent {
+				t.Errorf("Should be error\n")
+			}
+
+			if video != testCase.expected {
+				t.Errorf("Invalid video bitrate, expected: %d, got: %d\n", testCase.expected, video)
+			}
+		})
+	}
+}
+
+func TestShrinkBitrate(t *testing.T) {
+	if b := shrinkBitrate(1000000, 20000000, 16000000); b != 760000 {
+		t.Errorf("Invalid bitrate, expected: %d, got: %d\n", 760000, b)
+	}
+}
+
+func TestOutputDuration(t *testing.T) {
+	src := &response.Video{Duration: 120, Audio: []response.Audio{{Bitrate: 96000}}}
+
+	cases := []struct {
+		name     string
+		opt      *Request
+		expected float64
+	}{
+		{name: "Whole video", opt: &Request{}, expected: 120},
+		{name: "Till the end", opt: &Request{Start: 30}, expected: 90},
+		{name: "Clip", opt: &Request{Start: 30, End: 60}, expected: 30},
+		{name: "Clip longer than video", opt: &Request{Start: 100, Duration: 60}, expected: 20},
+	}
+
+	for _, testCase := range cases {
+		t.Run(testCase.name, func(t *testing.T) {
+			if d := outputDuration(testCase.opt, src); d != testCase.expected {
+				t.Errorf("Invalid duration, expected: %v, got: %v\n", testCase.expected, d)
+			}
+		})
+	}
+
+	if audio := sizeAudio(src); audio != 96000 {
+		t.Errorf("Invalid audio bitrate, expected: %d, got: %d\n", 96000, audio)
+	}
+
+	if audio := sizeAudio(&response.Video{}); audio != 0 {
+		t.Errorf("Invalid audio bitrate, expected: %d, got: %d\n", 0, audio)
+	}
+}
@@ -0,0 +1,194 @@
+package compressor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/Hargeon/compressrv/pkg/ffmpeg"
+	"github.com/Hargeon/compressrv/pkg/response"
+)
+
+// Modes of subtitle handling
+const (
+	SubtitleDrop = "drop"
+	SubtitleKeep = "keep"
+	SubtitleBurn = "burn"
+)
+
+const subtitlesName = "subtitles"
+
+// bitmapSubtitleCodecs are subtitle codecs with images instead of text, they can't be converted to text codecs
+var bitmapSubtitleCodecs = map[string]bool{
+	"hdmv_pgs_subtitle": true,
+	"dvd_subtitle":      true,
+	"dvb_subtitle":      true,
+	"dvb_teletext":      true,
+	"xsub":              true,
+}
+
+// textSubtitle reports if subtitle track is stored as text
+func textSubtitle(s response.Subtitle) bool {
+	return !bitmapSubtitleCodecs[s.Codec]
+}
+
+// subtitleCodec returns codec of subtitles for container with ext extension.
+// "copy" means container keeps subtitles of any codec, empty codec means container can't keep subtitles
+func subtitleCodec(ext string) string {
+	if mp4Container(ext) {
+		return "mov_text"
+	}
+
+	switch strings.ToLower(ext) {
+	case ".webm":
+		return "webvtt"
+	case ".mkv":
+		return "copy"
+	default:
+		return ""
+	}
+}
+
+// burnedSubtitle returns subtitle track which should be burned in, nil when nothing is burned
+func burnedSubtitle(opt *Request, src *response.Video) (*response.Subtitle, error) {
+	if opt.subtitleMode() != SubtitleBurn {
+		return nil, nil
+	}
+
+	if src == nil {
+		return nil, errors.New("original video info is required for burning subtitles")
+	}
+
+	if opt.Subtitles.Track >= len(src.Subtitles) {
+		return nil, fmt.Errorf("subtitle track %d not found", opt.Subtitles.Track)
+	}
+
+	return &src.Subtitles[opt.Subtitles.Track], nil
+}
+
+// subtitleFilter returns filter which burns text subtitles in, it's empty for other modes and bitmap subtitles
+func subtitleFilter(opt *Request, src *response.Video) (string, error) {
+	s, err := burnedSubtitle(opt, src)
+	if err != nil || s == nil || !textSubtitle(*s) {
+		return "", err
+	}
+
+	return fmt.Sprintf("subtitles=filename=%s:si=%d", ffmpeg.EscapeValue(opt.Subtitles.source), opt.Subtitles.Track), nil
+}
+
+// subtitleArgs returns ffmpeg options which drop, keep or burn in subtitles of src for output with ext extension.
+// Bitmap subtitles are overlaid by -filter_complex, so filtergraph of opts is moved there
+func subtitleArgs(opt *Request, src *response.Video, ext string, opts *ffmpeg.Options) ([]string, error) {
+	switch opt.subtitleMode() {
+	case SubtitleKeep:
+		if src == nil {
+			return nil, errors.New("original video info is required for keeping subtitles")
+		}
+
+		codec := subtitleCodec(ext)
+		args := []string{"-map", "0:V:0", "-map", "0:a:0?"}
+		kept := false
+
+		for _, s := range src.Subtitles {
+			if codec == "" || (codec != "copy" && !textSubtitle(s)) {
+				continue
+			}
+
+			args = append(args, "-map", "0:"+strconv.Itoa(s.Index))
+			kept = true
+		}
+
+		if !kept {
+			return append(args, "-sn"), nil
+		}
+
+		return append(args, "-c:s", codec), nil
+	case SubtitleBurn:
+		s, err := burnedSubtitle(opt, src)
+		if err != nil {
+			return nil, err
+		}
+
+		if textSubtitle(*s) {
+			return []string{"-sn"}, nil
+		}
+
+		graph := fmt.Sprintf("[0:V:0][0:s:%d]overlay", opt.Subtitles.Track)
+
+		switch {
+		case opts.VideoFilter == nil:
+			graph += "[out]"
+		case strings.HasPrefix(*opts.VideoFilter, "[in]"):
+			graph += "," + strings.TrimPrefix(*opts.VideoFilter, "[in]")
+		default:
+			graph += "," + *opts.VideoFilter + "[out]"
+		}
+
+		opts.VideoFilter = nil
+
+		return []string{"-filter_complex", graph, "-map", "[out]", "-map", "0:a:0?", "-sn"}, nil
+	default:
+		return []string{"-sn"}, nil
+	}
+}
+
+// withSubtitleSource returns copy of opt with originalVideo as a source of burned subtitles
+func withSubtitleSource(opt *Request, originalVideo string) *Request {
+	if opt.subtitleMode() != SubtitleBurn {
+		return opt
+	}
+
+	subtitles := *opt.Subtitles
+	subtitles.source = originalVideo
+
+	req := *opt
+	req.Subtitles = &subtitles
+
+	return &req
+}
+
+// Subtitles extracts text subtitle tracks of originalVideo to WebVTT files.
+// It returns directory with files and description of files with paths relative to the directory
+func (c *Compressor) Subtitles(ctx context.Context, opt *Request,
+	originalVideo string) (string, []response.SubtitleFile, error) {
+	info, err := c.VideoInfo(originalVideo)
+	if err != nil {
+		return "", nil, err
+	}
+
+	dir := packageDir(opt, originalVideo, subtitlesName)
+	if err = os.RemoveAll(dir); err != nil {
+		return "", nil, err
+	}
+
+	if err = os.MkdirAll(dir, dirPerm); err != nil {
+		return "", nil, err
+	}
+
+	var files []response.SubtitleFile
+
+	for i, s := range info.Subtitles {
+		if !textSubtitle(s) {
+			continue
+		}
+
+		name := fmt.Sprintf("subtitle_%d.vtt", i)
+
+		args := append([]string{"-i", originalVideo}, trimArgs(opt)...)
+		args = append(args, "-map", fmt.Sprintf("0:s:%d", i), "-c:s", "webvtt", filepath.Join(dir, name))
+
+		if _, err = c.run(ctx, args...); err != nil {
+			os.RemoveAll(dir)
+
+			return "", nil, err
+		}
+
+		files = append(files, response.SubtitleFile{Track: i, Language: s.Language, Key: name})
+	}
+
+	return dir, files, nil
+}
@@ -0,0 +1,141 @@
+package compressor
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/Hargeon/compressrv/pkg/ffmpeg"
+	"github.com/Hargeon/compressrv/pkg/response"
+)
+
+func TestSubtitleArgs(t *testing.T) {
+	src := &response.Video{
+		ResolutionX: 1920,
+		ResolutionY: 1080,
+		Subtitles: []response.Subtitle{
+			{Index: 2, Codec: "subrip", Language: "eng"},
+			{Index: 3, Codec: "hdmv_pgs_subtitle", Language: "ger"},
+		},
+	}
+
+	chain := "scale=1280:720"
+	graph := "[in]null[base];movie=logo.png[logo];[base][logo]overlay[out]"
+
+	cases := []struct {
+		name   string
+		opt    *Request
+		ext    string
+		filter *string
+
+		expectedArgs   []string
+		expectedFilter *string
+		errorPresent   bool
+	}{
+		{
+			name:         "Drop by default",
+			opt:          &Request{},
+			ext:          ".mp4",
+			expectedArgs: []string{"-sn"},
+		},
+		{
+			name:         "Keep text subtitles in mp4",
+			opt:          &Request{Subtitles: &SubtitleOptions{Mode: SubtitleKeep}},
+			ext:          ".mp4",
+			expectedArgs: []string{"-map", "0:V:0", "-map", "0:a:0?", "-map", "0:2", "-c:s", "mov_text"},
+		},
+		{
+			name: "Keep all subtitles in mkv",
+			opt:  &Request{Subtitles: &SubtitleOptions{Mode: SubtitleKeep}},
+			ext:  ".mkv",
+			expectedArgs: []string{"-map", "0:V:0", "-map", "0:a:0?", "-map", "0:2", "-map", "0:3",
+				"-c:s", "copy"},
+		},
+		{
+			name:         "Keep subtitles in container without subtitles",
+			opt:          &Request{Subtitles: &SubtitleOptions{Mode: SubtitleKeep}},
+			ext:          ".avi",
+			expectedArgs: []string{"-map", "0:V:0", "-map", "0:a:0?", "-sn"},
+		},
+		{
+			name:           "Burn text subtitles",
+			opt:            &Request{Subtitles: &SubtitleOptions{Mode: SubtitleBurn}},
+			ext:            ".mp4",
+			filter:         &chain,
+			expectedArgs:   []string{"-sn"},
+			expectedFilter: &chain,
+		},
+		{
+			name:   "Burn bitmap subtitles",
+			opt:    &Request{Subtitles: &SubtitleOptions{Mode: SubtitleBurn, Track: 1}},
+			ext:    ".mp4",
+			filter: &chain,
+			expectedArgs: []string{"-filter_complex", "[0:V:0][0:s:1]overlay,scale=1280:720[out]",
+				"-map", "[out]", "-map", "0:a:0?", "-sn"},
+		},
+		{
+			name:   "Burn bitmap subtitles under watermark",
+			opt:    &Request{Subtitles: &SubtitleOptions{Mode: SubtitleBurn, Track: 1}},
+			ext:    ".mp4",
+			filter: &graph,
+			expectedArgs: []string{"-filter_complex",
+				"[0:V:0][0:s:1]overlay,null[base];movie=logo.png[logo];[base][logo]overlay[out]",
+				"-map", "[out]", "-map", "0:a:0?", "-sn"},
+		},
+		{
+			name:         "Burn missing track",
+			opt:          &Request{Subtitles: &SubtitleOptions{Mode: SubtitleBurn, Track: 2}},
+			ext:          ".mp4",
+			errorPresent: true,
+		},
+	}
+
+	for _, testCase := range cases {
+		t.Run(testCase.name, func(t *testing.T) {
+			opts := &ffmpeg.Options{VideoFilter: testCase.filter}
+
+			args, err := subtitleArgs(testCase.opt, src, testCase.ext, opts)
+			if err != nil && !testCase.errorPresent {
+				t.Errorf("Unexpected error: %s\n", err)
+			}
+
+			if err == nil && testCase.errorPresent {
+				t.Errorf("Should be error\n")
+			}
+
+			if !reflect.DeepEqual(args, testCase.expectedArgs) {
+				t.Errorf("Invalid args, expected: %v, got: %v\n", testCase.expectedArgs, args)
+			}
+
+			if !testCase.errorPresent && !reflect.DeepEqual(opts.VideoFilter, testCase.expectedFilter) {
+				t.Errorf("Invalid video filter, expected: %v, got: %v\n", testCase.expectedFilter, opts.VideoFilter)
+			}
+		})
+	}
+}
+
+func TestBuildOptionsBurnSubtitles(t *testing.T) {
+	src := &response.Video{
+		ResolutionX: 1920,
+		ResolutionY: 1080,
+		Subtitles:   []response.Subtitle{{Index: 2, Codec: "ass"}},
+	}
+
+	opt := withSubtitleSource(&Request{
+		Resolution: "720p",
+		Subtitles:  &SubtitleOptions{Mode: SubtitleBurn},
+	}, "/videos/movie:1.mkv")
+
+	opts, err := NewCompressor("", "").buildOptions(opt, src)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s\n", err)
+	}
+
+	if opts.VideoFilter == nil {
+		t.Fatalf("Video filter is missing\n")
+	}
+
+	expected := `scale=1280:720,subtitles=filename=/videos/movie\\:1.mkv:si=0`
+	if *opts.VideoFilter != expected {
+		t.Errorf("Invalid video filter, expected: %s, got: %s\n", expected, *opts.VideoFilter)
+	}
+}
@@ -0,0 +1,217 @@
+package compressor
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/Hargeon/compressrv/pkg/response"
+)
+
+const (
+	defaultThumbnailWidth = 320
+	defaultSpriteInterval = 10
+	defaultSpriteColumns  = 10
+	posterName            = "poster.jpg"
+	spriteName            = "sprite.jpg"
+	spriteVTTName         = "sprite.vtt"
+	jpegQuality           = "3" // 2-31, lower is better
+	// maxSpriteSize is a max width and height of JPEG image in pixels
+	maxSpriteSize = 65535
+)
+
+// Thumbnails extracts poster, evenly spaced thumbnails and sprite sheet with WebVTT track from originalVideo.
+// It returns directory with images and description of images with paths relative to the directory
+func (c *Compressor) Thumbnails(ctx context.Context, opt *Request,
+	originalVideo string) (string, *response.Thumbnails, error) {
+	info, err := c.VideoInfo(originalVideo)
+	if err != nil {
+		return "", nil, err
+	}
+
+	duration, err := c.videoDuration(originalVideo)
+	if err != nil {
+		return "", nil, err
+	}
+
+	dir := packageDir(opt, originalVideo, "thumbnails")
+	if err = os.RemoveAll(dir); err != nil {
+		return "", nil, err
+	}
+
+	if err = os.MkdirAll(dir, dirPerm); err != nil {
+		return "", nil, err
+	}
+
+	thumbs, err := c.thumbnails(ctx, opt.Thumbnails, originalVideo, dir, duration, info)
+	if err != nil {
+		os.RemoveAll(dir)
+
+		return "", nil, err
+	}
+
+	return dir, thumbs, nil
+}
+
+// thumbnails extracts images described by opt to dir
+func (c *Compressor) thumbnails(ctx context.Context, opt *ThumbnailOptions, originalVideo, dir string,
+	duration float64, info *response.Video) (*response.Thumbnails, error) {
+	thumbs := new(response.Thumbnails)
+
+	width := opt.Width
+	if width == 0 {
+		width = defaultThumbnailWidth
+	}
+
+	height := evenDimension(float64(width) * float64(info.ResolutionY) / float64(info.ResolutionX))
+	scale := fmt.Sprintf("scale=%d:%d", width, height)
+
+	if opt.Poster {
+		// there is no frame to extract at the very end of video
+		at := math.Min(opt.PosterAt, math.Max(duration-1, 0))
+		if err := c.frame(ctx, originalVideo, filepath.Join(dir, posterName), at, ""); err != nil {
+			return nil, err
+		}
+
+		thumbs.Poster = posterName
+	}
+
+	for i := 0; i < opt.Count; i++ {
+		name := fmt.Sprintf("thumbnail_%03d.jpg", i)
+		at := duration * (float64(i) + 0.5) / float64(opt.Count)
+
+		if err := c.frame(ctx, originalVideo, filepath.Join(dir, name), at, scale); err != nil {
+			return nil, err
+		}
+
+		thumbs.Thumbnails = append(thumbs.Thumbnails, name)
+	}
+
+	if opt.Sprite {
+		if err := c.sprite(ctx, opt, originalVideo, dir, duration, width, height); err != nil {
+			return nil, err
+		}
+
+		thumbs.Sprite = spriteName
+		thumbs.SpriteVTT = spriteVTTName
+	}
+
+	return thumbs, nil
+}
+
+// frame saves one frame of video at the moment at (seconds) to image
+func (c *Compressor) frame(ctx context.Context, originalVideo, image string, at float64, filter string) error {
+	// seeking before input is fast, it decodes only from the nearest keyframe
+	args := []string{"-ss", formatNumber(at), "-i", originalVideo, "-frames:v", "1", "-q:v", jpegQuality}
+	if filter != "" {
+		args = append(args, "-vf", filter)
+	}
+
+	_, err := c.run(ctx, append(args, image)...)
+
+	return err
+}
+
+// sprite saves tiles of video taken each opt.SpriteInterval seconds to one image and writes WebVTT track for it
+func (c *Compressor) sprite(ctx context.Context, opt *ThumbnailOptions, originalVideo, dir string,
+	duration float64, width, height int) error {
+	interval, columns, frames := spriteLayout(opt, duration, width, height)
+
+	rows := (frames + columns - 1) / columns
+	filter := fmt.Sprintf("fps=1/%s,scale=%d:%d,tile=%dx%d",
+		formatNumber(interval), width, height, columns, rows)
+
+	_, err := c.run(ctx, "-i", originalVideo, "-vf", filter, "-frames:v", "1", "-q:v", jpegQuality,
+		filepath.Join(dir, spriteName))
+	if err != nil {
+		return err
+	}
+
+	vtt, err := os.Create(filepath.Join(dir, spriteVTTName))
+	if err != nil {
+		return err
+	}
+	defer vtt.Close()
+
+	return writeSpriteVTT(vtt, spriteName, frames, columns, width, height, interval, duration)
+}
+
+// spriteLayout returns interval in seconds between tiles, number of columns and number of tiles of sprite
+// of video lasting duration seconds. Sprite of width x height tiles has to fit size limit of JPEG,
+// so columns are reduced for wide tiles and interval is raised for long videos
+func spriteLayout(opt *ThumbnailOptions, duration float64, width, height int) (float64, int, int) {
+	interval := opt.SpriteInterval
+	if interval == 0 {
+		interval = defaultSpriteInterval
+	}
+
+	columns := opt.SpriteColumns
+	if columns == 0 {
+		columns = defaultSpriteColumns
+	}
+
+	// width of tiles is limited by validation of request
+	if maxColumns := maxSpriteSize / width; columns > maxColumns {
+		columns = maxColumns
+	}
+
+	maxRows := maxSpriteSize / height
+	if maxRows < 1 {
+		maxRows = 1
+	}
+
+	if maxFrames := float64(columns * maxRows); duration/interval > maxFrames {
+		// interval is rounded up to milliseconds, so tiles still fit the limit
+		interval = math.Ceil(duration/maxFrames*1000) / 1000
+	}
+
+	frames := int(math.Ceil(duration / interval))
+	if frames == 0 {
+		frames = 1
+	}
+
+	if frames < columns {
+		columns = frames
+	}
+
+	return interval, columns, frames
+}
+
+// writeSpriteVTT writes WebVTT thumbnail track, each cue references a tile of sprite by media fragment
+func writeSpriteVTT(w io.Writer, sprite string, frames, columns, width, height int,
+	interval, duration float64) error {
+	var b strings.Builder
+
+	b.WriteString("WEBVTT\n")
+
+	for i := 0; i < frames; i++ {
+		start := float64(i) * interval
+		end := math.Min(start+interval, duration)
+		x := (i % columns) * width
+		y := (i / columns) * height
+
+		fmt.Fprintf(&b, "\n%s --> %s\n%s#xywh=%d,%d,%d,%d\n",
+			vttTimestamp(start), vttTimestamp(end), sprite, x, y, width, height)
+	}
+
+	_, err := io.WriteString(w, b.String())
+
+	return err
+}
+
+// vttTimestamp formats seconds as WebVTT timestamp (hh:mm:ss.ttt)
+func vttTimestamp(seconds float64) string {
+	ms := int64(math.Round(seconds * 1000))
+
+	return fmt.Sprintf("%02d:%02d:%02d.%03d", ms/3600000, ms/60000%60, ms/1000%60, ms%1000)
+}
+
+// formatNumber formats number (e.g. seconds) as ffmpeg option value
+func formatNumber(n float64) string {
+	return strconv.FormatFloat(n, 'f', -1, bitrateBitSize)
+}
@@ -0,0 +1,103 @@
+package compressor
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestWriteSpriteVTT(t *testing.T) {
+	expected := "WEBVTT\n" +
+		"\n00:00:00.000 --> 00:00:10.000\nsprite.jpg#xywh=0,0,160,90\n" +
+		"\n00:00:10.000 --> 00:00:20.000\nsprite.jpg#xywh=160,0,160,90\n" +
+		"\n00:00:20.000 --> 00:00:25.500\nsprite.jpg#xywh=0,90,160,90\n"
+
+	var b strings.Builder
+	if err := writeSpriteVTT(&b, "sprite.jpg", 3, 2, 160, 90, 10, 25.5); err != nil {
+		t.Fatalf("Unexpected error: %s\n", err)
+	}
+
+	if b.String() != expected {
+		t.Errorf("Invalid WebVTT track, expected:\n%s\ngot:\n%s\n", expected, b.String())
+	}
+}
+
+func TestVttTimestamp(t *testing.T) {
+	cases := []struct {
+		seconds  float64
+		expected string
+	}{
+		{seconds: 0, expected: "00:00:00.000"},
+		{seconds: 61.25, expected: "00:01:01.250"},
+		{seconds: 7322.0004, expected: "02:02:02.000"},
+	}
+
+	for _, testCase := range cases {
+		t.Run(testCase.expected, func(t *testing.T) {
+			if got := vttTimestamp(testCase.seconds); got != testCase.expected {
+				t.Errorf("Invalid timestamp, expected: %s, got: %s\n", testCase.expected, got)
+			}
+		})
+	}
+}
+
+func TestSpriteLayout(t *testing.T) {
+	cases := []struct {
+		name     string
+		opt      *ThumbnailOptions
+		duration float64
+		width    int
+		height   int
+
+		expectedInterval float64
+		expectedColumns  int
+		expectedFrames   int
+	}{
+		{
+			name:             "Default layout",
+			opt:              &ThumbnailOptions{},
+			duration:         60,
+			width:            320,
+			height:           180,
+			expectedInterval: 10,
+			expectedColumns:  6,
+			expectedFrames:   6,
+		},
+		{
+			name:             "Long video with short interval",
+			opt:              &ThumbnailOptions{SpriteInterval: 1},
+			duration:         10800,
+			width:            320,
+			height:           180,
+			expectedInterval: 2.968,
+			expectedColumns:  10,
+			expectedFrames:   3639,
+		},
+		{
+			name:             "Wide tiles",
+			opt:              &ThumbnailOptions{SpriteColumns: 20},
+			duration:         600,
+			width:            4000,
+			height:           2250,
+			expectedInterval: 10,
+			expectedColumns:  16,
+			expectedFrames:   60,
+		},
+	}
+
+	for _, testCase := range cases {
+		t.Run(testCase.name, func(t *testing.T) {
+			interval, columns, frames := spriteLayout(testCase.opt, testCase.duration, testCase.width, testCase.height)
+
+			if interval != testCase.expectedInterval || columns != testCase.expectedColumns ||
+				frames != testCase.expectedFrames {
+				t.Errorf("Invalid layout, expected: %v %d %d, got: %v %d %d\n", testCase.expectedInterval,
+					testCase.expectedColumns, testCase.expectedFrames, interval, columns, frames)
+			}
+
+			rows := (frames + columns - 1) / columns
+			if columns*testCase.width > maxSpriteSize || rows*testCase.height > maxSpriteSize {
+				t.Errorf("Sprite %dx%d exceeds JPEG size limit\n", columns*testCase.width, rows*testCase.height)
+			}
+		})
+	}
+}
@@ -0,0 +1,66 @@
+package compressor
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+)
+
+// cut copies clip of originalVideo to output without re-encoding.
+// Clip starts from the keyframe before opt.Start
+func (c *Compressor) cut(ctx context.Context, opt *Request, originalVideo, output string) (string, error) {
+	_, err := c.run(ctx, cutArgs(opt, originalVideo, output)...)
+	if err != nil {
+		os.Remove(output)
+
+		return "", err
+	}
+
+	return output, nil
+}
+
+// cutArgs returns ffmpeg options for copying clip of originalVideo described by opt
+func cutArgs(opt *Request, originalVideo, output string) []string {
+	var args []string
+
+	// seeking before input jumps to keyframe, stream copy can't start from other frames
+	if opt.Start != 0 {
+		args = append(args, "-ss", formatNumber(opt.Start))
+	}
+
+	args = append(args, "-i", originalVideo)
+
+	if d := opt.clipDuration(); d != 0 {
+		args = append(args, "-t", formatNumber(d))
+	}
+
+	// data streams and attached pictures aren't copied, subtitles are copied like subtitleArgs keeps them.
+	// Clip which is re-encoded later keeps subtitles for burning them in
+	args = append(args, "-map", "0:V", "-map", "0:a?")
+	if opt.subtitleMode() != SubtitleDrop {
+		args = append(args, "-map", "0:s?")
+	}
+
+	args = append(args, "-c", "copy", "-avoid_negative_ts", "make_zero")
+
+	if flags := movFlags(opt, filepath.Ext(output)); flags != "" {
+		args = append(args, "-movflags", flags)
+	}
+
+	return append(args, output)
+}
+
+// trimArgs returns output ffmpeg options for accurate cutting clip described by opt
+func trimArgs(opt *Request) []string {
+	var args []string
+
+	if opt.Start != 0 {
+		args = append(args, "-ss", formatNumber(opt.Start))
+	}
+
+	if d := opt.clipDuration(); d != 0 {
+		args = append(args, "-t", formatNumber(d))
+	}
+
+	return args
+}
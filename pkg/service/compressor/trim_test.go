@@ -0,0 +1,129 @@
+package compressor
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCutArgs(t *testing.T) {
+	cases := []struct {
+		name     string
+		opt      *Request
+		expected []string
+	}{
+		{
+			name: "With start and end",
+			opt:  &Request{Start: 1.5, End: 11.5},
+			expected: []string{"-ss", "1.5", "-i", "in.mkv", "-t", "10",
+				"-map", "0:V", "-map", "0:a?", "-c", "copy", "-avoid_negative_ts", "make_zero", "out.mkv"},
+		},
+		{
+			name: "With duration only",
+			opt:  &Request{Duration: 30},
+			expected: []string{"-i", "in.mkv", "-t", "30",
+				"-map", "0:V", "-map", "0:a?", "-c", "copy", "-avoid_negative_ts", "make_zero", "out.mkv"},
+		},
+		{
+			name: "With kept subtitles",
+			opt:  &Request{Duration: 30, Subtitles: &SubtitleOptions{Mode: SubtitleKeep}},
+			expected: []string{"-i", "in.mkv", "-t", "30", "-map", "0:V", "-map", "0:a?", "-map", "0:s?",
+				"-c", "copy", "-avoid_negative_ts", "make_zero", "out.mkv"},
+		},
+		{
+			name: "With start only",
+			opt:  &Request{Start: 60},
+			expected: []string{"-ss", "60", "-i", "in.mkv",
+				"-map", "0:V", "-map", "0:a?", "-c", "copy", "-avoid_negative_ts", "make_zero", "out.mkv"},
+		},
+	}
+
+	for _, testCase := range cases {
+		t.Run(testCase.name, func(t *testing.T) {
+			args := cutArgs(testCase.opt, "in.mkv", "out.mkv")
+			if !reflect.DeepEqual(args, testCase.expected) {
+				t.Errorf("Invalid args, expected: %v, got: %v\n", testCase.expected, args)
+			}
+		})
+	}
+}
+
+func TestTrimArgs(t *testing.T) {
+	cases := []struct {
+		name     string
+		opt      *Request
+		expected []string
+	}{
+		{
+			name:     "Without clip",
+			opt:      &Request{},
+			expected: nil,
+		},
+		{
+			name:     "With start and duration",
+			opt:      &Request{Start: 2, Duration: 4.25},
+			expected: []string{"-ss", "2", "-t", "4.25"},
+		},
+		{
+			name:     "With end",
+			opt:      &Request{End: 8},
+			expected: []string{"-t", "8"},
+		},
+	}
+
+	for _, testCase := range cases {
+		t.Run(testCase.name, func(t *testing.T) {
+			args := trimArgs(testCase.opt)
+			if !reflect.DeepEqual(args, testCase.expected) {
+				t.Errorf("Invalid args, expected: %v, got: %v\n", testCase.expected, args)
+			}
+		})
+	}
+}
+
+func TestBuildOptionsClip(t *testing.T) {
+	srv := NewCompressor("", "")
+	opts, err := srv.buildOptions(&Request{Start: 3, End: 10.5}, nil)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s\n", err)
+	}
+
+	if opts.SeekTime == nil || *opts.SeekTime != "3" {
+		t.Errorf("Invalid seek time, expected: 3, got: %v\n", opts.SeekTime)
+	}
+
+	if opts.Duration == nil || *opts.Duration != "7.5" {
+		t.Errorf("Invalid duration, expected: 7.5, got: %v\n", opts.Duration)
+	}
+}
+
+func TestReencode(t *testing.T) {
+	cases := []struct {
+		name     string
+		opt      *Request
+		expected bool
+	}{
+		{
+			name:     "Clip only",
+			opt:      &Request{Start: 2, Duration: 4, FastCut: true},
+			expected: false,
+		},
+		{
+			name:     "Clip with resolution",
+			opt:      &Request{Start: 2, Duration: 4, FastCut: true, Resolution: "640:360"},
+			expected: true,
+		},
+		{
+			name:     "Clip with auto bitrate",
+			opt:      &Request{Start: 2, Duration: 4, FastCut: true, AutoBitrate: &AutoBitrateOptions{}},
+			expected: true,
+		},
+	}
+
+	for _, testCase := range cases {
+		t.Run(testCase.name, func(t *testing.T) {
+			if got := testCase.opt.reencode(); got != testCase.expected {
+				t.Errorf("Invalid reencode, expected: %v, got: %v\n", testCase.expected, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,178 @@
+package compressor
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/Hargeon/compressrv/pkg/ffmpeg"
+	"github.com/Hargeon/compressrv/pkg/response"
+)
+
+const (
+	// verifyDurationTolerance in seconds between expected and converted duration
+	verifyDurationTolerance = 0.5
+	// verifyDurationShare is a tolerance relative to expected duration, long videos drift more at stream ends
+	verifyDurationShare = 0.01
+)
+
+// Verify decodes convertedVideo completely and checks its duration, streams and frame size
+// against ones expected from originalVideo converted by opt. It's used for file output
+func (c *Compressor) Verify(ctx context.Context, opt *Request, originalVideo, convertedVideo string) error {
+	src, err := c.VideoInfo(originalVideo)
+	if err != nil {
+		return err
+	}
+
+	if err = c.decode(ctx, convertedVideo); err != nil {
+		return err
+	}
+
+	dst, err := c.VideoInfo(convertedVideo)
+	if err != nil {
+		return fmt.Errorf("converted video: %w", err)
+	}
+
+	if err = verifyDuration(opt, src, dst); err != nil {
+		return err
+	}
+
+	if err = verifyStreams(opt, src, dst); err != nil {
+		return err
+	}
+
+	return verifyFrame(opt, src, dst)
+}
+
+// decodeErrorMessages are parts of ffmpeg messages about broken frames or packets,
+// other messages printed with error level like timestamp warnings don't fail verification
+var decodeErrorMessages = []string{"error while decoding", "invalid data found when processing input",
+	"corrupt", "concealing", "error submitting packet to decoder", "missing reference picture",
+	"invalid nal unit size", "no frame!", "decode_slice_header error", "packet mismatch", "truncated"}
+
+// decode decodes all streams of path by null muxer, -xerror makes ffmpeg exit on the first decoding error
+func (c *Compressor) decode(ctx context.Context, path string) error {
+	stderr, err := c.ffmpegCnf.Ffmpeg("-v", "error", "-xerror").
+		Input(path).
+		Output("-", "-map", "0", "-f", "null").
+		Run(ctx)
+	if err != nil {
+		return fmt.Errorf("converted video has decoding errors: %w", err)
+	}
+
+	// decoders conceal some errors without failing
+	if errs := decodeErrors(stderr); errs != "" {
+		return fmt.Errorf("converted video has decoding errors: %s", errs)
+	}
+
+	return nil
+}
+
+// decodeErrors returns last lines of stderr which report decoding errors
+func decodeErrors(stderr string) string {
+	var lines []string
+
+	for _, line := range strings.Split(stderr, "\n") {
+		l := strings.ToLower(line)
+
+		for _, message := range decodeErrorMessages {
+			if strings.Contains(l, message) {
+				lines = append(lines, strings.TrimSpace(line))
+
+				break
+			}
+		}
+	}
+
+	return ffmpeg.Tail(strings.Join(lines, "\n"), ffmpeg.TailLines)
+}
+
+// verifyDuration compares duration of dst with duration of clip of src,
+// clip cut on keyframes may start earlier, so it's only checked to be not shorter
+func verifyDuration(opt *Request, src, dst *response.Video) error {
+	if src.Duration == 0 {
+		return nil
+	}
+
+	expected := outputDuration(opt, src)
+	tolerance := math.Max(verifyDurationTolerance, expected*verifyDurationShare)
+	diff := dst.Duration - expected
+
+	if diff < -tolerance || (diff > tolerance && !opt.FastCut) {
+		return fmt.Errorf("converted video lasts %s seconds instead of %s",
+			formatNumber(dst.Duration), formatNumber(expected))
+	}
+
+	return nil
+}
+
+// verifyStreams compares number of audio and subtitle streams of dst with streams kept from src.
+// Re-encoded video keeps the first audio stream, stream copy keeps all of them and all kept subtitles
+func verifyStreams(opt *Request, src, dst *response.Video) error {
+	copied := opt.FastCut && opt.trimmed() && !opt.reencode()
+
+	audio := len(src.Audio)
+	if !copied && audio > 1 {
+		audio = 1
+	}
+
+	if len(dst.Audio) != audio {
+		return fmt.Errorf("converted video has %d audio streams instead of %d", len(dst.Audio), audio)
+	}
+
+	switch {
+	case copied && opt.subtitleMode() == SubtitleKeep:
+		if len(dst.Subtitles) != len(src.Subtitles) {
+			return fmt.Errorf("converted video has %d subtitle streams instead of %d",
+				len(dst.Subtitles), len(src.Subtitles))
+		}
+	case opt.subtitleMode() == SubtitleKeep:
+		// subtitles which aren't supported by container are dropped
+		if len(dst.Subtitles) > len(src.Subtitles) {
+			return fmt.Errorf("converted video has %d subtitle streams, original has %d",
+				len(dst.Subtitles), len(src.Subtitles))
+		}
+	default:
+		if len(dst.Subtitles) != 0 {
+			return fmt.Errorf("converted video has %d subtitle streams instead of 0", len(dst.Subtitles))
+		}
+	}
+
+	return nil
+}
+
+// verifyFrame compares frame size of dst with frame size expected from src and opt
+func verifyFrame(opt *Request, src, dst *response.Video) error {
+	w, h, known, err := outputFrame(opt, src)
+	if err != nil || !known {
+		return err
+	}
+
+	if dst.ResolutionX != w || dst.ResolutionY != h {
+		return fmt.Errorf("converted video has %dx%d frame instead of %dx%d",
+			dst.ResolutionX, dst.ResolutionY, w, h)
+	}
+
+	return nil
+}
+
+// outputFrame returns size of frame of src converted by opt like buildOptions does.
+// Size of cropped frame depends on detection, so it isn't known for opt.AutoCrop
+func outputFrame(opt *Request, src *response.Video) (int, int, bool, error) {
+	switch {
+	case opt.AutoCrop:
+		return 0, 0, false, nil
+	case opt.fitMode() != "":
+		w, h, err := fitFrame(opt, src)
+
+		return w, h, err == nil, err
+	case opt.Resolution != "":
+		srcW, srcH := displaySize(src)
+		w, h, err := resolveResolution(opt.Resolution, srcW, srcH, opt.NoUpscale)
+
+		return w, h, err == nil, err
+	default:
+		return src.ResolutionX, src.ResolutionY, true, nil
+	}
+}
@@ -0,0 +1,183 @@
+package compressor
+
+import (
+	"testing"
+
+	"github.com/Hargeon/compressrv/pkg/response"
+)
+
+func TestVerifyOutput(t *testing.T) {
+	src := &response.Video{
+		ResolutionX: 1280,
+		ResolutionY: 720,
+		RatioX:      16,
+		RatioY:      9,
+		Duration:    60,
+		Audio:       []response.Audio{{Index: 1}, {Index: 2}},
+		Subtitles:   []response.Subtitle{{Index: 3, Codec: "subrip"}},
+	}
+
+	converted := func(w, h int, duration float64, audio, subtitles int) *response.Video {
+		return &response.Video{
+			ResolutionX: w,
+			ResolutionY: h,
+			Duration:    duration,
+			Audio:       make([]response.Audio, audio),
+			Subtitles:   make([]response.Subtitle, subtitles),
+		}
+	}
+
+	cases := []struct {
+		name         string
+		opt          *Request
+		dst          *response.Video
+		errorPresent bool
+	}{
+		{
+			name: "Same video",
+			opt:  &Request{},
+			dst:  converted(1280, 720, 60.04, 1, 0),
+		},
+		{
+			name:         "Truncated video",
+			opt:          &Request{},
+			dst:          converted(1280, 720, 31.5, 1, 0),
+			errorPresent: true,
+		},
+		{
+			name: "Clip",
+			opt:  &Request{Start: 10, Duration: 20},
+			dst:  converted(1280, 720, 20, 1, 0),
+		},
+		{
+			name:         "Too long clip",
+			opt:          &Request{Start: 10, Duration: 20},
+			dst:          converted(1280, 720, 50, 1, 0),
+			errorPresent: true,
+		},
+		{
+			name: "Fast cut clip starting on earlier keyframe",
+			opt:  &Request{Start: 10, Duration: 20, FastCut: true},
+			dst:  converted(1280, 720, 23, 2, 0),
+		},
+		{
+			name: "Fast cut clip with kept subtitles",
+			opt:  &Request{Start: 10, Duration: 20, FastCut: true, Subtitles: &SubtitleOptions{Mode: SubtitleKeep}},
+			dst:  converted(1280, 720, 20, 2, 1),
+		},
+		{
+			name:         "Fast cut clip with copied subtitles",
+			opt:          &Request{Start: 10, Duration: 20, FastCut: true},
+			dst:          converted(1280, 720, 20, 2, 1),
+			errorPresent: true,
+		},
+		{
+			name:         "Fast cut clip without copied streams",
+			opt:          &Request{Start: 10, Duration: 20, FastCut: true},
+			dst:          converted(1280, 720, 20, 1, 0),
+			errorPresent: true,
+		},
+		{
+			name: "Fast cut clip re-encoded with auto bitrate",
+			opt:  &Request{Start: 10, Duration: 20, FastCut: true, AutoBitrate: &AutoBitrateOptions{}},
+			dst:  converted(1280, 720, 21, 1, 0),
+		},
+		{
+			name:         "Lost audio",
+			opt:          &Request{},
+			dst:          converted(1280, 720, 60, 0, 0),
+			errorPresent: true,
+		},
+		{
+			name: "Kept subtitles",
+			opt:  &Request{Subtitles: &SubtitleOptions{Mode: SubtitleKeep}},
+			dst:  converted(1280, 720, 60, 1, 1),
+		},
+		{
+			name:         "Unexpected subtitles",
+			opt:          &Request{},
+			dst:          converted(1280, 720, 60, 1, 1),
+			errorPresent: true,
+		},
+		{
+			name: "Exact resolution",
+			opt:  &Request{Resolution: "800:600"},
+			dst:  converted(800, 600, 60, 1, 0),
+		},
+		{
+			name:         "Invalid resolution",
+			opt:          &Request{Resolution: "800:600"},
+			dst:          converted(1280, 720, 60, 1, 0),
+			errorPresent: true,
+		},
+		{
+			name: "Short side resolution",
+			opt:  &Request{Resolution: "360p"},
+			dst:  converted(640, 360, 60, 1, 0),
+		},
+		{
+			name: "Fit with pad",
+			opt:  &Request{Ratio: "4:3", Fit: FitPad},
+			dst:  converted(1280, 960, 60, 1, 0),
+		},
+		{
+			name: "Unknown cropped frame",
+			opt:  &Request{AutoCrop: true},
+			dst:  converted(1280, 536, 60, 1, 0),
+		},
+	}
+
+	for _, testCase := range cases {
+		t.Run(testCase.name, func(t *testing.T) {
+			err := verifyDuration(testCase.opt, src, testCase.dst)
+			if err == nil {
+				err = verifyStreams(testCase.opt, src, testCase.dst)
+			}
+
+			if err == nil {
+				err = verifyFrame(testCase.opt, src, testCase.dst)
+			}
+
+			if err != nil && !testCase.errorPresent {
+				t.Fatalf("Unexpected error: %s\n", err)
+			}
+
+			if err == nil && testCase.errorPresent {
+				t.Fatalf("Should be error\n")
+			}
+		})
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	cases := []struct {
+		name     string
+		stderr   string
+		expected string
+	}{
+		{
+			name:     "Without errors",
+			stderr:   "",
+			expected: "",
+		},
+		{
+			name:     "Timestamp warning",
+			stderr:   "[null @ 0x55] Application provided invalid, non monotonically increasing dts to muxer\n",
+			expected: "",
+		},
+		{
+			name: "Broken frame",
+			stderr: "[null @ 0x55] Application provided invalid, non monotonically increasing dts to muxer\n" +
+				"[h264 @ 0x56] concealing 120 DC, 120 AC, 120 MV errors in P frame\n",
+			expected: "[h264 @ 0x56] concealing 120 DC, 120 AC, 120 MV errors in P frame",
+		},
+	}
+
+	for _, testCase := range cases {
+		t.Run(testCase.name, func(t *testing.T) {
+			if errs := decodeErrors(testCase.stderr); errs != testCase.expected {
+				t.Errorf("Invalid decode errors, expected: %q, got: %q\n", testCase.expected, errs)
+			}
+		})
+	}
+}
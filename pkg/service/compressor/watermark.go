@@ -0,0 +1,61 @@
+package compressor
+
+import (
+	"fmt"
+
+	"github.com/Hargeon/compressrv/pkg/ffmpeg"
+)
+
+// watermarkGraph returns filtergraph which applies chain of filters to video and overlays watermark on the result
+func watermarkGraph(chain string, w *Watermark) string {
+	if chain == "" {
+		chain = "null"
+	}
+
+	image := ffmpeg.Chain(ffmpeg.Filter("movie", ffmpeg.EscapeValue(w.ImagePath)), ffmpeg.Filter("format", "rgba"))
+	if w.Opacity != 0 && w.Opacity != 1 {
+		image = ffmpeg.Chain(image, ffmpeg.Filter("colorchannelmixer", "aa="+formatNumber(w.Opacity)))
+	}
+
+	chains := []string{ffmpeg.Link([]string{"in"}, chain, "base"), ffmpeg.Link(nil, image, "logo")}
+	main, wm := "base", "logo"
+
+	if w.Scale != 0 {
+		// scale2ref makes width of watermark relative to the main video, a is an aspect ratio of watermark
+		scale := ffmpeg.Filter("scale2ref", "w=main_w*"+formatNumber(w.Scale), "h=ow/a")
+		chains = append(chains, ffmpeg.Link([]string{"logo", "base"}, scale, "wm", "main"))
+		main, wm = "main", "wm"
+	}
+
+	options := []string{overlayPosition(w.Position, w.Margin)}
+
+	if w.Start != 0 || w.End != 0 {
+		enable := fmt.Sprintf("gte(t,%s)", formatNumber(w.Start))
+		if w.End != 0 {
+			enable = fmt.Sprintf("between(t,%s,%s)", formatNumber(w.Start), formatNumber(w.End))
+		}
+
+		options = append(options, fmt.Sprintf("enable='%s'", enable))
+	}
+
+	overlay := ffmpeg.Filter("overlay", options...)
+
+	return ffmpeg.Graph(append(chains, ffmpeg.Link([]string{main, wm}, overlay, "out"))...)
+}
+
+// overlayPosition returns x and y options of overlay filter for position.
+// W and H are the size of the main video, w and h are the size of watermark
+func overlayPosition(position string, margin int) string {
+	switch position {
+	case PositionTopLeft:
+		return fmt.Sprintf("x=%d:y=%d", margin, margin)
+	case PositionTopRight:
+		return fmt.Sprintf("x=W-w-%d:y=%d", margin, margin)
+	case PositionBottomLeft:
+		return fmt.Sprintf("x=%d:y=H-h-%d", margin, margin)
+	case PositionCenter:
+		return "x=(W-w)/2:y=(H-h)/2"
+	default:
+		return fmt.Sprintf("x=W-w-%d:y=H-h-%d", margin, margin)
+	}
+}
@@ -0,0 +1,68 @@
+package compressor
+
+import "testing"
+
+func TestWatermarkGraph(t *testing.T) {
+	cases := []struct {
+		name      string
+		chain     string
+		watermark *Watermark
+		expected  string
+	}{
+		{
+			name:      "Default position",
+			watermark: &Watermark{ImagePath: "/tmp/logo.png"},
+			expected:  "[in]null[base];movie=/tmp/logo.png,format=rgba[logo];[base][logo]overlay=x=W-w-0:y=H-h-0[out]",
+		},
+		{
+			name:  "Scaled and transparent with time range",
+			chain: "scale=800:600",
+			watermark: &Watermark{
+				ImagePath: "/tmp/logo.png",
+				Position:  PositionTopLeft,
+				Margin:    10,
+				Scale:     0.2,
+				Opacity:   0.5,
+				Start:     1,
+				End:       5.5,
+			},
+			expected: "[in]scale=800:600[base];movie=/tmp/logo.png,format=rgba,colorchannelmixer=aa=0.5[logo];" +
+				"[logo][base]scale2ref=w=main_w*0.2:h=ow/a[wm][main];" +
+				"[main][wm]overlay=x=10:y=10:enable='between(t,1,5.5)'[out]",
+		},
+		{
+			name:      "Centered from start time",
+			watermark: &Watermark{ImagePath: "/tmp/logo.png", Position: PositionCenter, Start: 3},
+			expected:  "[in]null[base];movie=/tmp/logo.png,format=rgba[logo];[base][logo]overlay=x=(W-w)/2:y=(H-h)/2:enable='gte(t,3)'[out]",
+		},
+	}
+
+	for _, testCase := range cases {
+		t.Run(testCase.name, func(t *testing.T) {
+			graph := watermarkGraph(testCase.chain, testCase.watermark)
+			if graph != testCase.expected {
+				t.Errorf("Invalid filtergraph, expected: %s, got: %s\n", testCase.expected, graph)
+			}
+		})
+	}
+}
+
+func TestBuildOptionsWatermark(t *testing.T) {
+	srv := NewCompressor("", "")
+	opts, err := srv.buildOptions(&Request{
+		Resolution: "800x600",
+		Watermark:  &Watermark{ImagePath: "/tmp/logo.png"},
+	}, nil)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s\n", err)
+	}
+
+	if opts.Resolution != nil {
+		t.Errorf("Resolution should be applied by filter, got: %s\n", *opts.Resolution)
+	}
+
+	expected := "[in]scale=800:600[base];movie=/tmp/logo.png,format=rgba[logo];[base][logo]overlay=x=W-w-0:y=H-h-0[out]"
+	if opts.VideoFilter == nil || *opts.VideoFilter != expected {
+		t.Errorf("Invalid video filter, expected: %s, got: %v\n", expected, opts.VideoFilter)
+	}
+}
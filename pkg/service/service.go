@@ -4,28 +4,49 @@ import (
 	"context"
 	"io"
 
+	"github.com/Hargeon/compressrv/pkg/ffmpeg"
 	"github.com/Hargeon/compressrv/pkg/response"
 	"github.com/Hargeon/compressrv/pkg/service/compressor"
 )
 
 type VideoStorage interface {
-	Download(ctx context.Context, id string) (string, error)
+	// Download saves file to dir and returns its path
+	Download(ctx context.Context, id, dir string) (string, error)
 	Upload(ctx context.Context, fileName string, file io.Reader) (string, error)
+	UploadDir(ctx context.Context, name, dir string) (string, error)
 }
 
 type Compressor interface {
 	Convert(ctx context.Context, opt *compressor.Request, originalVideo string) (string, error)
+	Decide(opt *compressor.Request, originalVideo string) (string, error)
+	Remux(ctx context.Context, opt *compressor.Request, originalVideo string) (string, error)
+	Verify(ctx context.Context, opt *compressor.Request, originalVideo, convertedVideo string) error
 	VideoInfo(path string) (*response.Video, error)
+	Package(ctx context.Context, opt *compressor.Request, originalVideo string) (string, *response.Package, error)
+	Thumbnails(ctx context.Context, opt *compressor.Request, originalVideo string) (string, *response.Thumbnails, error)
+	Preview(ctx context.Context, opt *compressor.Request, originalVideo string) (string, *response.Preview, error)
+	MeasureLoudness(ctx context.Context, opt *compressor.Request, path string) (*response.LoudnessLevel, error)
+	SelectBitrate(ctx context.Context, opt *compressor.Request, originalVideo string) (*response.AutoBitrate, error)
+	Subtitles(ctx context.Context, opt *compressor.Request, originalVideo string) (string, []response.SubtitleFile, error)
 }
 
 type Service struct {
 	VideoStorage
 	Compressor
+	Presets   compressor.Presets
+	Admission compressor.Admission
 }
 
-func NewService(storage VideoStorage, ffmpegPath, ffprobePath string) *Service {
+func NewService(storage VideoStorage, ffmpegPath, ffprobePath string, presets compressor.Presets,
+	admission compressor.Admission, limits ffmpeg.Limits, chunking compressor.Chunking) *Service {
+	c := compressor.NewCompressor(ffmpegPath, ffprobePath)
+	c.SetLimits(limits)
+	c.SetChunking(chunking)
+
 	return &Service{
 		VideoStorage: storage,
-		Compressor:   compressor.NewCompressor(ffmpegPath, ffprobePath),
+		Compressor:   c,
+		Presets:      presets,
+		Admission:    admission,
 	}
 }
@@ -4,7 +4,9 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"mime"
 	"os"
+	"path/filepath"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/credentials"
@@ -15,8 +17,6 @@ import (
 	"go.uber.org/zap"
 )
 
-const originalFileFolder = "/tmp/original_video/"
-
 type AWSS3 struct {
 	logger     *zap.Logger
 	bucketName string
@@ -36,13 +36,15 @@ func NewAWSS3(logger *zap.Logger, bucketName, region, accessKey, secretKey strin
 	}
 }
 
-func (s *AWSS3) Download(ctx context.Context, id string) (string, error) {
-	fileName := fmt.Sprintf("%s%s%s", os.Getenv("ROOT"), originalFileFolder, id)
+// Download file from aws s3 to dir
+func (s *AWSS3) Download(ctx context.Context, id, dir string) (string, error) {
+	fileName := filepath.Join(dir, filepath.Base(id))
 	file, err := os.Create(fileName)
 
 	if err != nil {
 		return "", err
 	}
+	defer file.Close()
 
 	sess, err := s.session()
 
@@ -88,6 +90,50 @@ func (s *AWSS3) Upload(ctx context.Context, fileName string, file io.Reader) (st
 	return newFileName, nil
 }
 
+// UploadDir uploads all files of dir to aws s3 under one prefix and returns the prefix.
+// Keys of files are relative to the prefix, so references between files (e.g. playlists) keep working.
+func (s *AWSS3) UploadDir(ctx context.Context, name, dir string) (string, error) {
+	sess, err := s.session()
+	if err != nil {
+		return "", err
+	}
+
+	uploader := s3manager.NewUploader(sess)
+	prefix := fmt.Sprintf("converted_%s_%s", uuid.New().String(), name)
+
+	err = filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+
+		_, err = uploader.UploadWithContext(ctx, &s3manager.UploadInput{
+			Body:        file,
+			Bucket:      aws.String(s.bucketName),
+			Key:         aws.String(prefix + "/" + filepath.ToSlash(rel)),
+			ContentType: aws.String(contentType(path)),
+		})
+
+		return err
+	})
+
+	if err != nil {
+		return "", err
+	}
+
+	return prefix, nil
+}
+
 func (s *AWSS3) session() (*session.Session, error) {
 	sess, err := session.NewSession(
 		&aws.Config{
@@ -100,3 +146,25 @@ func (s *AWSS3) session() (*session.Session, error) {
 
 	return sess, err
 }
+
+// contentType returns MIME type of file by extension
+func contentType(path string) string {
+	ext := filepath.Ext(path)
+
+	switch ext {
+	case ".m3u8":
+		return "application/vnd.apple.mpegurl"
+	case ".ts":
+		return "video/mp2t"
+	case ".mpd":
+		return "application/dash+xml"
+	case ".m4s":
+		return "video/iso.segment"
+	}
+
+	if t := mime.TypeByExtension(ext); t != "" {
+		return t
+	}
+
+	return "application/octet-stream"
+}
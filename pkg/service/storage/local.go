@@ -14,8 +14,8 @@ type LocalStorage struct {
 	logger *zap.Logger
 }
 
-// Download function returns video from local machine
-func (s *LocalStorage) Download(ctx context.Context, id string) (string, error) {
+// Download function returns video from local machine, video is used in place, so dir isn't used
+func (s *LocalStorage) Download(ctx context.Context, id, dir string) (string, error) {
 	root := os.Getenv("ROOT")
 	videoPath := root + fmt.Sprintf("/tmp/original_video/%s", id)
 
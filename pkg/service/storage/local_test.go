@@ -53,7 +53,7 @@ func TestDownload(t *testing.T) {
 	storage := NewLocalStorage(logger)
 	for _, testCase := range cases {
 		t.Run(testCase.name, func(t *testing.T) {
-			path, err := storage.Download(context.Background(), testCase.input)
+			path, err := storage.Download(context.Background(), testCase.input, t.TempDir())
 			if err != nil && !testCase.errorExist {
 				t.Errorf("Unexpected error: %v\n", err)
 			}